@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/config"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/threatfeed"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+
+	"github.com/rs/zerolog"
+)
+
+const usage = `Usage: blocklist import -source <name> -format <%s> [-allow-empty] <file>
+
+Imports a local threat feed into the domain block list. Domains previously
+imported under the same source but missing from the file are removed, and live
+links that the new list covers are quarantined for review.
+
+Files that look empty or in another format are refused. Pass -allow-empty with
+an empty file to remove every domain from the source.
+`
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] != "import" {
+		fmt.Fprintf(os.Stderr, usage, strings.Join(threatfeed.Formats, "|"))
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	source := flags.String("source", "", "name of the feed, used to tag and diff its domains")
+	format := flags.String("format", threatfeed.FormatHosts, "feed format: "+strings.Join(threatfeed.Formats, ", "))
+	allowEmpty := flags.Bool("allow-empty", false, "let an empty file remove every domain from the source")
+	flags.Parse(os.Args[2:])
+
+	if *source == "" || flags.NArg() != 1 {
+		fmt.Fprintf(os.Stderr, usage, strings.Join(threatfeed.Formats, "|"))
+		os.Exit(2)
+	}
+
+	cfg := config.Config{}
+
+	config.LoadConfig(&cfg)
+
+	logger := zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).
+		With().
+		Timestamp().
+		Logger()
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		logger.Err(err).Msg("Error opening feed")
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	ctx := usercontext.ContextSetUser(context.Background(), usercontext.SystemUser)
+
+	db, err := pgxpool.New(ctx, cfg.DB.DSN)
+	if err != nil {
+		logger.Err(err).Msg("Error opening database connection")
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	wg := sync.WaitGroup{}
+
+	repos := repositories.NewRepositories(db, &cfg, &logger, &wg)
+	svcs := services.NewServices(&repos, &cfg, &logger, &wg)
+
+	result, err := svcs.BlockListService.ImportFeed(ctx, services.ImportFeedArgs{
+		Source:     *source,
+		Format:     *format,
+		Feed:       file,
+		AllowEmpty: *allowEmpty,
+	})
+	if err != nil {
+		logger.Err(err).Msg("Error importing feed")
+		os.Exit(1)
+	}
+
+	wg.Wait()
+
+	fmt.Printf("%s: %d added, %d updated, %d removed, %d skipped, %d links quarantined\n",
+		result.Source, result.Added, result.Updated, result.Removed, result.Skipped, result.Quarantined)
+}
@@ -1,12 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
 	"github.com/mcorrigan89/url_shortener/internal/services"
 	"github.com/mcorrigan89/url_shortener/internal/usercontext"
 	"github.com/mcorrigan89/url_shortener/internal/validator"
@@ -41,14 +42,35 @@ func (app *application) linksPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	linkEntities, err := app.services.LinkService.GetLinksByUserID(ctx, user.ID)
+	workspaces, err := app.services.WorkspaceService.GetWorkspacesByUserID(ctx, user.ID)
 	if err != nil {
-		app.logger.Err(err).Ctx(ctx).Msg("Error getting links by user ID")
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting workspaces by user ID")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	links := ui.Base("My Links", "Links page", ui.Links(app.config, linkEntities))
+	current := app.currentWorkspace(r, user.ID)
+
+	var linkEntities []*entities.LinkEntity
+	if current != nil {
+		linkEntities, err = app.services.LinkService.GetLinksByWorkspaceID(ctx, current.WorkspaceID, user.ID)
+	} else {
+		linkEntities, err = app.services.LinkService.GetLinksByUserID(ctx, user.ID)
+	}
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting links")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	notifications, err := app.services.NotificationService.GetUnreadNotifications(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting notifications")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	links := ui.Base("My Links", "Links page", ui.Links(app.config, linkEntities, notifications, workspaces, current, user.EmailVerified))
 
 	links.Render(ctx, w)
 }
@@ -130,40 +152,6 @@ func (app *application) qrCodeHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(code)
 }
 
-func (app *application) loginGoogle(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
-	queryParams := r.URL.Query()
-
-	codeParam := queryParams.Get("code")
-
-	if codeParam == "" {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
-		return
-	}
-
-	session, err := app.services.OAuthService.LoginWithGoogleCode(ctx, codeParam)
-
-	if err != nil {
-		app.logger.Err(err).Ctx(ctx).Msg("Error logging in with Google")
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		return
-	}
-
-	cookie := http.Cookie{
-		Name:     "x-session-token",
-		Value:    session.Token,
-		Secure:   true,
-		Path:     "/",
-		Domain:   app.config.ClientURL,
-		MaxAge:   int(time.Until(session.ExpiresAt).Seconds()),
-		SameSite: http.SameSiteLaxMode,
-	}
-	http.SetCookie(w, &cookie)
-
-	http.Redirect(w, r, "/links", http.StatusSeeOther)
-}
-
 func (app *application) createLink(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
@@ -202,12 +190,42 @@ func (app *application) createLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var workspaceID *uuid.UUID
+	if current := app.currentWorkspace(r, user.ID); current != nil {
+		workspaceID = &current.WorkspaceID
+	}
+
 	_, err = app.services.LinkService.CreateLink(ctx, services.CreateLinkArgs{
-		UserID:  user.ID,
-		LinkURL: form.LinkUrl,
+		UserID:      user.ID,
+		LinkURL:     form.LinkUrl,
+		WorkspaceID: workspaceID,
 	})
 
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidURL) {
+			form.AddFieldError("link_url", "This field must be a valid HTTPS URL")
+			createLink := ui.Base("Create link", "Create link page", ui.CreateLink(form))
+			createLink.Render(ctx, w)
+			return
+		}
+		if errors.Is(err, services.ErrBlockedDomain) {
+			form.AddFieldError("link_url", "This destination is not allowed")
+			createLink := ui.Base("Create link", "Create link page", ui.CreateLink(form))
+			createLink.Render(ctx, w)
+			return
+		}
+		if errors.Is(err, services.ErrLinkQuotaExceeded) {
+			form.AddFieldError("link_url", fmt.Sprintf("Verify your email address to create more than %d links", app.config.EmailVerification.UnverifiedLinkQuota))
+			createLink := ui.Base("Create link", "Create link page", ui.CreateLink(form))
+			createLink.Render(ctx, w)
+			return
+		}
+		if errors.Is(err, entities.ErrForbidden) {
+			form.AddFieldError("link_url", "Viewers cannot create links in this workspace")
+			createLink := ui.Base("Create link", "Create link page", ui.CreateLink(form))
+			createLink.Render(ctx, w)
+			return
+		}
 		app.logger.Err(err).Ctx(ctx).Msg("Error creating link")
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -215,3 +233,31 @@ func (app *application) createLink(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, "/links", http.StatusSeeOther)
 }
+
+func (app *application) markNotificationRead(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	notificationUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing notification ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	err = app.services.NotificationService.MarkRead(ctx, notificationUUID, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error marking notification read")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/links", http.StatusSeeOther)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) accountsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if usercontext.ContextGetUser(ctx) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	app.renderAccounts(w, r, http.StatusOK, "")
+}
+
+// renderAccounts shows the signed in user's logins, with message as an error
+// when it isn't empty.
+func (app *application) renderAccounts(w http.ResponseWriter, r *http.Request, status int, message string) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// The user on the context was loaded before any change this request made.
+	user, err := app.services.UserService.GetUserByID(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting user")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	page := ui.Base("Logins", "How you log in", ui.Accounts(app.config, user, message))
+
+	w.WriteHeader(status)
+	page.Render(ctx, w)
+}
+
+func (app *application) connectProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	start, err := app.services.OAuthService.StartLink(ctx, r.PathValue("provider"), user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownProvider) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error starting link")
+		app.renderAccounts(w, r, http.StatusBadGateway, "We couldn't reach the provider. Please try again in a moment.")
+		return
+	}
+
+	app.setCookie(w, loginAttemptCookieName, start.Attempt, start.ExpiresAt)
+
+	http.Redirect(w, r, start.AuthURL, http.StatusFound)
+}
+
+func (app *application) disconnectProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	identityID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	err = app.services.OAuthService.Disconnect(ctx, user.ID, identityID)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrIdentityNotFound):
+			http.Error(w, "Not Found", http.StatusNotFound)
+		case errors.Is(err, entities.ErrLastIdentity):
+			app.renderAccounts(w, r, http.StatusConflict, "This is your only way to log in, so it can't be disconnected.")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error disconnecting provider")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/settings/accounts", http.StatusSeeOther)
+}
+
+// linkAccountPage asks someone who logged in with a provider whether to join
+// it to the existing account with the same email.
+func (app *application) linkAccountPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cookie, err := r.Cookie(pendingLinkCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	pending, err := app.services.OAuthService.GetPendingLink(ctx, cookie.Value)
+	if err != nil {
+		app.clearCookie(w, pendingLinkCookieName)
+		app.renderLoginError(w, r, http.StatusBadRequest, "This sign in has expired. Please try again.")
+		return
+	}
+
+	page := ui.Base("Link accounts", "Link accounts", ui.ConfirmLink(app.config, pending.Email, pending.Provider))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) confirmLinkAccount(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	cookie, err := r.Cookie(pendingLinkCookieName)
+	app.clearCookie(w, pendingLinkCookieName)
+	if err != nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	session, returnTo, err := app.services.OAuthService.ConfirmLink(ctx, cookie.Value)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLoginAttempt), errors.Is(err, entities.ErrUserNotFound):
+			app.renderLoginError(w, r, http.StatusBadRequest, "This sign in has expired. Please try again.")
+		case errors.Is(err, entities.ErrIdentityInUse), errors.Is(err, entities.ErrProviderAlreadyLinked):
+			app.renderLoginError(w, r, http.StatusConflict, "That account can no longer be linked. Login with your password instead.")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error linking accounts")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.completeLogin(w, r, session, returnTo)
+}
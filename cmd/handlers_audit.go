@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) auditLogPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	events, form, err := app.auditEvents(r)
+	if err != nil {
+		app.auditError(w, r, err)
+		return
+	}
+
+	auditLog := ui.Base("Audit log", "Security-relevant actions", ui.AdminAuditLog(events, form))
+
+	auditLog.Render(ctx, w)
+}
+
+func (app *application) exportAuditLog(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	events, form, err := app.auditEvents(r)
+	if err != nil {
+		app.auditError(w, r, err)
+		return
+	}
+
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusBadRequest, map[string]any{"errors": form.FieldErrors})
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.json", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	err = app.writeJSON(w, http.StatusOK, map[string]any{"events": events})
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error writing audit export")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// auditEvents reads the filter from the query string and returns the matching
+// events. An invalid filter returns no events and the form with its errors.
+func (app *application) auditEvents(r *http.Request) ([]*entities.AuditEvent, dto.AuditFilterForm, error) {
+	ctx := r.Context()
+
+	var form dto.AuditFilterForm
+
+	err := app.formDecoder.Decode(&form, r.URL.Query())
+	if err != nil {
+		form.AddFieldError("limit", "Enter a number")
+		return nil, form, nil
+	}
+
+	if validator.NotBlank(form.Action) {
+		form.CheckField(validator.PermittedValue(form.Action, entities.AuditActions...), "action", "Choose an action")
+	}
+
+	from, ok := parseDay(form.From)
+	form.CheckField(ok, "from", "Enter a valid date")
+
+	to, ok := parseDay(form.To)
+	form.CheckField(ok, "to", "Enter a valid date")
+
+	if !form.Valid() {
+		return nil, form, nil
+	}
+
+	// The to date is inclusive, so the filter ends at the start of the next day.
+	if to != nil {
+		end := to.AddDate(0, 0, 1)
+		to = &end
+	}
+
+	events, err := app.services.AuditService.GetEvents(ctx, services.GetAuditEventsArgs{
+		Action:        form.Action,
+		ActorEmail:    form.Actor,
+		CreatedAfter:  from,
+		CreatedBefore: to,
+		Limit:         form.Limit,
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrUserNotFound) {
+			form.AddFieldError("actor", "No user with this email")
+			return nil, form, nil
+		}
+		return nil, form, err
+	}
+
+	return events, form, nil
+}
+
+func (app *application) auditError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, entities.ErrForbidden) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	app.logger.Err(err).Ctx(r.Context()).Msg("Error getting audit events")
+	http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+}
+
+// parseDay reads an optional date from an HTML date input.
+func parseDay(value string) (*time.Time, bool) {
+	if !validator.NotBlank(value) {
+		return nil, true
+	}
+
+	day, err := time.Parse(expiryLayout, value)
+	if err != nil {
+		return nil, false
+	}
+
+	return &day, true
+}
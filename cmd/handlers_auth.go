@@ -0,0 +1,386 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/oidc"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+const (
+	minPasswordLength = 10
+	// bcrypt ignores everything after 72 bytes.
+	maxPasswordLength = 72
+)
+
+func (app *application) signupPage(w http.ResponseWriter, r *http.Request) {
+	if usercontext.ContextGetUser(r.Context()) != nil {
+		http.Redirect(w, r, "/links", http.StatusSeeOther)
+		return
+	}
+
+	app.renderSignup(w, r, dto.SignupForm{})
+}
+
+func (app *application) renderSignup(w http.ResponseWriter, r *http.Request, form dto.SignupForm) {
+	signup := ui.Base("Sign up", "Create an account", ui.Signup(app.config, form))
+
+	signup.Render(r.Context(), w)
+}
+
+func (app *application) loginPage(w http.ResponseWriter, r *http.Request) {
+	if usercontext.ContextGetUser(r.Context()) != nil {
+		http.Redirect(w, r, "/links", http.StatusSeeOther)
+		return
+	}
+
+	form := dto.LoginForm{ReturnTo: app.safeReturnTo(r.URL.Query().Get("return_to"))}
+	if r.URL.Query().Get("reset") == "done" {
+		form.Notice = "Your password has been changed. Login with your new password."
+	}
+
+	app.renderLogin(w, r, form)
+}
+
+func (app *application) renderLogin(w http.ResponseWriter, r *http.Request, form dto.LoginForm) {
+	login := ui.Base("Login", "Sign in to your account", ui.Login(app.config, form))
+
+	login.Render(r.Context(), w)
+}
+
+// checkNewPassword applies the password rules to a password being set for the
+// account with email.
+func checkNewPassword(v *validator.Validator, field, password, email string) {
+	v.CheckField(validator.MinChars(password, minPasswordLength), field, fmt.Sprintf("This field must be at least %d characters long", minPasswordLength))
+	v.CheckField(len(password) <= maxPasswordLength, field, fmt.Sprintf("This field cannot be more than %d bytes long", maxPasswordLength))
+	v.CheckField(validator.StrongPassword(password), field, "Use at least three of lower case letters, upper case letters, digits and symbols")
+	v.CheckField(!strings.EqualFold(password, email), field, "Your password cannot be your email address")
+}
+
+func (app *application) signup(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.SignupForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Enter a valid email address")
+	form.CheckField(validator.MaxChars(form.GivenName, 100), "given_name", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.MaxChars(form.FamilyName, 100), "family_name", "This field cannot be more than 100 characters long")
+	checkNewPassword(&form.Validator, "password", form.Password, form.Email)
+
+	if !form.Valid() {
+		form.Password = ""
+		app.renderSignup(w, r, form)
+		return
+	}
+
+	user, err := app.services.UserService.CreateUser(ctx, services.CreateUserArgs{
+		GivenName:  optionalString(form.GivenName),
+		FamilyName: optionalString(form.FamilyName),
+		Email:      form.Email,
+		Password:   form.Password,
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrDuplicateEmail) {
+			form.Password = ""
+			form.AddFieldError("email", "An account with this email already exists")
+			app.renderSignup(w, r, form)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error creating user")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// The account works without verifying, so a mail failure only means the
+	// user has to ask for another link.
+	err = app.services.UserService.SendVerificationEmail(ctx, user)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error sending verification email")
+	}
+
+	session, err := app.services.UserService.AuthenticateWithPassword(ctx, form.Email, form.Password)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error signing in new user")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.completeLogin(w, r, session, "")
+}
+
+func (app *application) login(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.LoginForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if !form.Valid() {
+		form.Password = ""
+		app.renderLogin(w, r, form)
+		return
+	}
+
+	// Attempts are counted per email on top of the per client limit on the
+	// route, so one account cannot be guessed at from many addresses.
+	throttleKey := strings.ToLower(form.Email)
+	if !app.loginLimiter.Allow(throttleKey) {
+		app.logger.Warn().Ctx(ctx).Str("email", form.Email).Msg("Login rate limit exceeded")
+		form.Password = ""
+		form.AddNonFieldError("Too many attempts. Try again later.")
+		w.WriteHeader(http.StatusTooManyRequests)
+		app.renderLogin(w, r, form)
+		return
+	}
+
+	session, err := app.services.UserService.AuthenticateWithPassword(ctx, form.Email, form.Password)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidCredentials) {
+			form.Password = ""
+			form.AddNonFieldError("Email or password is incorrect")
+			app.renderLogin(w, r, form)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error logging in with password")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.loginLimiter.Reset(throttleKey)
+
+	app.completeLogin(w, r, session, form.ReturnTo)
+}
+
+func (app *application) loginProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	returnTo := app.safeReturnTo(r.URL.Query().Get("return_to"))
+
+	start, err := app.services.OAuthService.StartLogin(ctx, r.PathValue("provider"), returnTo)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownProvider) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error starting login")
+		app.renderLoginError(w, r, http.StatusBadGateway, "We couldn't reach the sign in provider. Please try again in a moment.")
+		return
+	}
+
+	app.setCookie(w, loginAttemptCookieName, start.Attempt, start.ExpiresAt)
+
+	http.Redirect(w, r, start.AuthURL, http.StatusFound)
+}
+
+// providerErrors explains the errors a provider may send back instead of a
+// code.
+var providerErrors = map[string]string{
+	"access_denied":           "Sign in was cancelled or access was denied.",
+	"login_required":          "You need to sign in with the provider first.",
+	"consent_required":        "You need to allow access at the provider to sign in.",
+	"interaction_required":    "The provider needs you to finish signing in there first.",
+	"temporarily_unavailable": "The sign in provider is temporarily unavailable. Please try again in a moment.",
+}
+
+func (app *application) callbackProvider(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	providerName := r.PathValue("provider")
+
+	queryParams := r.URL.Query()
+
+	cookie, cookieErr := r.Cookie(loginAttemptCookieName)
+	app.clearCookie(w, loginAttemptCookieName)
+
+	if providerError := queryParams.Get("error"); providerError != "" {
+		app.logger.Warn().Ctx(ctx).Str("provider", providerName).Str("error", providerError).Str("description", queryParams.Get("error_description")).Msg("Provider returned an error")
+		message, ok := providerErrors[providerError]
+		if !ok {
+			message = "The sign in provider couldn't sign you in. Please try again."
+		}
+		app.renderLoginError(w, r, http.StatusBadRequest, message)
+		return
+	}
+
+	codeParam := queryParams.Get("code")
+
+	if codeParam == "" || cookieErr != nil {
+		app.renderLoginError(w, r, http.StatusBadRequest, "This sign in has expired or was started in another browser. Please try again.")
+		return
+	}
+
+	userID := uuid.Nil
+	if user := usercontext.ContextGetUser(ctx); user != nil {
+		userID = user.ID
+	}
+
+	result, err := app.services.OAuthService.LoginWithCode(ctx, services.LoginWithCodeArgs{
+		Provider: providerName,
+		Code:     codeParam,
+		State:    queryParams.Get("state"),
+		Attempt:  cookie.Value,
+		UserID:   userID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			http.Error(w, "Not Found", http.StatusNotFound)
+		case errors.Is(err, services.ErrLoginAttempt):
+			app.renderLoginError(w, r, http.StatusBadRequest, "This sign in has expired or was started in another browser. Please try again.")
+		case errors.Is(err, oidc.ErrNoEmail):
+			app.renderLoginError(w, r, http.StatusBadRequest, "Your account with the provider has no verified email address. Verify one there and try again.")
+		case errors.Is(err, oidc.ErrNonce), errors.Is(err, oidc.ErrInvalidIDToken):
+			app.logger.Warn().Ctx(ctx).Err(err).Str("provider", providerName).Msg("Rejected ID token")
+			app.renderLoginError(w, r, http.StatusBadRequest, "We couldn't verify the sign in response. Please try again.")
+		case errors.Is(err, entities.ErrIdentityInUse):
+			app.renderAccounts(w, r, http.StatusConflict, "That account is already connected to a different user.")
+		case errors.Is(err, entities.ErrProviderAlreadyLinked) && userID != uuid.Nil:
+			app.renderAccounts(w, r, http.StatusConflict, "You already have a different account with this provider connected. Disconnect it first.")
+		case errors.Is(err, entities.ErrProviderAlreadyLinked):
+			form := dto.LoginForm{}
+			form.AddNonFieldError("An account with this email already uses a different login with this provider.")
+			app.renderLogin(w, r, form)
+		case errors.Is(err, entities.ErrDuplicateEmail):
+			form := dto.LoginForm{}
+			form.AddNonFieldError("An account with this email already exists. Login to it, then connect this provider from your account settings.")
+			app.renderLogin(w, r, form)
+		default:
+			app.logger.Err(err).Ctx(ctx).Str("provider", providerName).Msg("Error logging in with provider")
+			app.renderLoginError(w, r, http.StatusBadGateway, "We couldn't complete sign in with the provider. Please try again.")
+		}
+		return
+	}
+
+	switch {
+	case result.Linked:
+		http.Redirect(w, r, "/settings/accounts", http.StatusSeeOther)
+	case result.PendingLink != nil:
+		app.setCookie(w, pendingLinkCookieName, result.PendingLink.Token, result.PendingLink.ExpiresAt)
+		http.Redirect(w, r, "/login/link", http.StatusSeeOther)
+	default:
+		app.completeLogin(w, r, result.Session, result.ReturnTo)
+	}
+}
+
+func (app *application) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	_, err := app.services.UserService.VerifyEmail(ctx, r.PathValue("token"))
+	if err != nil {
+		if errors.Is(err, services.ErrVerificationLink) {
+			page := ui.Base("Verify email", "Verify email", ui.VerifyEmail(false))
+			w.WriteHeader(http.StatusBadRequest)
+			page.Render(ctx, w)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error verifying email")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	page := ui.Base("Verify email", "Verify email", ui.VerifyEmail(true))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) resendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := app.services.UserService.SendVerificationEmail(ctx, user)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error resending verification email")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/links", http.StatusSeeOther)
+}
+
+func (app *application) renderLoginError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	page := ui.Base("Sign in failed", "Sign in failed", ui.LoginError(app.config, message))
+
+	w.WriteHeader(status)
+	page.Render(r.Context(), w)
+}
+
+// completeLogin sets the session cookie and sends the user on: to the
+// two-factor page if the session still needs a code, back to a workspace
+// invite they opened before signing in if there is one, otherwise to returnTo
+// when it is allowed.
+func (app *application) completeLogin(w http.ResponseWriter, r *http.Request, session *entities.UserSession, returnTo string) {
+	app.setSessionCookie(w, session)
+
+	if session.TwoFactorPending {
+		target := "/login/two-factor"
+		if returnTo = app.safeReturnTo(returnTo); returnTo != "" {
+			target += "?return_to=" + url.QueryEscape(returnTo)
+		}
+		http.Redirect(w, r, target, http.StatusSeeOther)
+		return
+	}
+
+	if cookie, err := r.Cookie(inviteCookieName); err == nil && cookie.Value != "" {
+		app.clearCookie(w, inviteCookieName)
+		http.Redirect(w, r, fmt.Sprintf("/invites/%s", cookie.Value), http.StatusSeeOther)
+		return
+	}
+
+	if returnTo = app.safeReturnTo(returnTo); returnTo != "" {
+		http.Redirect(w, r, returnTo, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/links", http.StatusSeeOther)
+}
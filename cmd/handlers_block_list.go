@@ -0,0 +1,296 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/blocklist"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/threatfeed"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+const maxFeedUploadSize = 64 << 20
+
+// expiryLayout matches the value of an HTML date input.
+const expiryLayout = "2006-01-02"
+
+func (app *application) blockListPage(w http.ResponseWriter, r *http.Request) {
+	app.renderBlockList(w, r, dto.BlockUserForm{}, dto.BlockDomainForm{}, "")
+}
+
+func (app *application) renderBlockList(w http.ResponseWriter, r *http.Request, userForm dto.BlockUserForm, domainForm dto.BlockDomainForm, message string) {
+	ctx := r.Context()
+
+	list, err := app.services.BlockListService.GetBlockList(ctx)
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting block list")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	blockList := ui.Base("Block list", "Blocked users and domains", ui.AdminBlockList(list, userForm, domainForm, message))
+
+	blockList.Render(ctx, w)
+}
+
+func (app *application) blockUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	var form dto.BlockUserForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+	expiresAt, ok := parseExpiry(form.ExpiresAt)
+	form.CheckField(ok, "expires_at", "This field must be a date in the future")
+
+	if !form.Valid() {
+		app.renderBlockList(w, r, form, dto.BlockDomainForm{}, "")
+		return
+	}
+
+	deactivated, err := app.services.BlockListService.BlockUser(ctx, services.BlockUserArgs{
+		Email:           form.Email,
+		Reason:          optionalString(form.Reason),
+		ExpiresAt:       expiresAt,
+		AdminID:         user.ID,
+		DeactivateLinks: form.DeactivateLinks,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrUserNotFound):
+			form.AddFieldError("email", "No user with this email")
+		case errors.Is(err, services.ErrCannotBlockSelf):
+			form.AddFieldError("email", "You cannot block yourself")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error blocking user")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		app.renderBlockList(w, r, form, dto.BlockDomainForm{}, "")
+		return
+	}
+
+	message := fmt.Sprintf("Blocked %s", form.Email)
+	if form.DeactivateLinks {
+		message = fmt.Sprintf("Blocked %s and deactivated %d links", form.Email, deactivated)
+	}
+
+	app.renderBlockList(w, r, dto.BlockUserForm{}, dto.BlockDomainForm{}, message)
+}
+
+func (app *application) unblockUser(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing user ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	err = app.services.BlockListService.UnblockUser(ctx, userUUID)
+	if err != nil {
+		if errors.Is(err, entities.ErrNotBlocked) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error unblocking user")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/blocklist", http.StatusSeeOther)
+}
+
+func (app *application) blockDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	var form dto.BlockDomainForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Domain), "domain", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Reason, 500), "reason", "This field cannot be more than 500 characters long")
+	expiresAt, ok := parseExpiry(form.ExpiresAt)
+	form.CheckField(ok, "expires_at", "This field must be a date in the future")
+
+	if !form.Valid() {
+		app.renderBlockList(w, r, dto.BlockUserForm{}, form, "")
+		return
+	}
+
+	quarantined, err := app.services.BlockListService.BlockDomain(ctx, services.BlockDomainArgs{
+		Domain:    form.Domain,
+		Reason:    optionalString(form.Reason),
+		ExpiresAt: expiresAt,
+		AdminID:   user.ID,
+	})
+	if err != nil {
+		if errors.Is(err, blocklist.ErrInvalidHost) {
+			form.AddFieldError("domain", "This field must be a valid domain")
+			app.renderBlockList(w, r, dto.BlockUserForm{}, form, "")
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error blocking domain")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.renderBlockList(w, r, dto.BlockUserForm{}, dto.BlockDomainForm{}, fmt.Sprintf("Blocked %s and quarantined %d links", form.Domain, quarantined))
+}
+
+func (app *application) unblockDomain(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := app.services.BlockListService.UnblockDomain(ctx, r.PathValue("domain"))
+	if err != nil {
+		if errors.Is(err, entities.ErrNotBlocked) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error unblocking domain")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/blocklist", http.StatusSeeOther)
+}
+
+// parseExpiry reads an optional expiry date. A blank value means the entry
+// never expires.
+func parseExpiry(value string) (*time.Time, bool) {
+	if !validator.NotBlank(value) {
+		return nil, true
+	}
+
+	expiresAt, err := time.Parse(expiryLayout, value)
+	if err != nil || !expiresAt.After(time.Now()) {
+		return nil, false
+	}
+
+	return &expiresAt, true
+}
+
+func optionalString(value string) *string {
+	if !validator.NotBlank(value) {
+		return nil
+	}
+	return &value
+}
+
+func (app *application) importBlockListPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	blockList := ui.Base("Block list", "Threat feed import", ui.BlockListImport(dto.ImportBlockListForm{}, nil))
+
+	blockList.Render(ctx, w)
+}
+
+func (app *application) importBlockList(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFeedUploadSize)
+
+	err := r.ParseMultipartForm(maxFeedUploadSize)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	var form dto.ImportBlockListForm
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Source), "source", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Source, 64), "source", "This field cannot be more than 64 characters long")
+	form.CheckField(validator.PermittedValue(form.Format, threatfeed.Formats...), "format", "Choose a format")
+
+	file, _, err := r.FormFile("feed")
+	if err != nil {
+		form.AddFieldError("feed", "Choose a file to import")
+	} else {
+		defer file.Close()
+	}
+
+	if !form.Valid() {
+		blockList := ui.Base("Block list", "Threat feed import", ui.BlockListImport(form, nil))
+		blockList.Render(ctx, w)
+		return
+	}
+
+	result, err := app.services.BlockListService.ImportFeed(ctx, services.ImportFeedArgs{
+		Source:     form.Source,
+		Format:     form.Format,
+		Feed:       file,
+		AllowEmpty: form.AllowEmpty,
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrReservedSource) {
+			form.AddFieldError("source", "This source name is reserved")
+			blockList := ui.Base("Block list", "Threat feed import", ui.BlockListImport(form, nil))
+			blockList.Render(ctx, w)
+			return
+		}
+		if errors.Is(err, threatfeed.ErrEmptyFeed) || errors.Is(err, threatfeed.ErrMostlySkipped) {
+			form.AddFieldError("feed", "This file has no usable entries for the chosen format")
+			blockList := ui.Base("Block list", "Threat feed import", ui.BlockListImport(form, nil))
+			blockList.Render(ctx, w)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error importing threat feed")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	blockList := ui.Base("Block list", "Threat feed import", ui.BlockListImport(dto.ImportBlockListForm{}, result))
+
+	blockList.Render(ctx, w)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+)
+
+func (app *application) startImpersonation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.ImpersonateForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if !validator.NotBlank(form.Email) {
+		app.renderRoles(w, r, dto.SetRoleForm{}, "Enter the email of the user to impersonate")
+		return
+	}
+
+	session, err := app.services.UserService.StartImpersonation(ctx, form.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrUserNotFound):
+			app.renderRoles(w, r, dto.SetRoleForm{}, "No user with this email")
+		case errors.Is(err, services.ErrCannotImpersonateSelf):
+			app.renderRoles(w, r, dto.SetRoleForm{}, "You cannot impersonate yourself")
+		case errors.Is(err, services.ErrAlreadyImpersonating), errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error starting impersonation")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Keep the admin's own session so stopping can hand it back.
+	adminSessionToken, err := r.Cookie(sessionCookieName)
+	if err == nil {
+		app.setCookie(w, impersonatorCookieName, adminSessionToken.Value, session.ExpiresAt)
+	}
+	app.setSessionCookie(w, session)
+
+	http.Redirect(w, r, "/links", http.StatusSeeOther)
+}
+
+func (app *application) stopImpersonation(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := app.services.UserService.StopImpersonation(ctx)
+	if err != nil && !errors.Is(err, services.ErrNotImpersonating) {
+		app.logger.Err(err).Ctx(ctx).Msg("Error stopping impersonation")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	adminSessionToken, err := r.Cookie(impersonatorCookieName)
+	if err != nil {
+		app.clearCookie(w, sessionCookieName)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	app.clearCookie(w, impersonatorCookieName)
+
+	_, adminSession, err := app.services.UserService.GetUserBySessionToken(ctx, adminSessionToken.Value)
+	if err != nil || adminSession.IsExpired() {
+		app.clearCookie(w, sessionCookieName)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	// Only the hash of a token is stored, so the admin's cookie is restored from
+	// the copy kept while impersonating.
+	app.setCookie(w, sessionCookieName, adminSessionToken.Value, adminSession.AbsoluteExpiresAt)
+
+	http.Redirect(w, r, "/admin/roles", http.StatusSeeOther)
+}
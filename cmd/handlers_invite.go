@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) inviteWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	var form dto.WorkspaceMemberForm
+
+	err = r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "This field must be a valid email address")
+	form.CheckField(validator.PermittedValue(form.Role, entities.WorkspaceRoles...), "role", "Choose a role")
+
+	if !form.Valid() {
+		app.renderWorkspace(w, r, form, "")
+		return
+	}
+
+	invite, err := app.services.WorkspaceService.InviteMember(ctx, services.InviteMemberArgs{
+		WorkspaceID: workspaceUUID,
+		ActorID:     user.ID,
+		Email:       form.Email,
+		Role:        form.Role,
+	})
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error inviting workspace member")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.renderWorkspace(w, r, dto.WorkspaceMemberForm{}, fmt.Sprintf("Invite sent to %s", invite.Email))
+}
+
+func (app *application) revokeWorkspaceInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	inviteUUID, err := uuid.Parse(r.PathValue("inviteID"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing invite ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	err = app.services.WorkspaceService.RevokeInvite(ctx, services.RevokeInviteArgs{
+		WorkspaceID: workspaceUUID,
+		ActorID:     user.ID,
+		InviteID:    inviteUUID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case errors.Is(err, repositories.ErrNotFound):
+			http.Error(w, "Not Found", http.StatusNotFound)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error revoking workspace invite")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/%s", workspaceUUID), http.StatusSeeOther)
+}
+
+// invitePage shows who the invite is from and lets the invitee accept it.
+// Visitors who aren't signed in are asked to sign in first, and the invite is
+// remembered in a cookie so the login callback can bring them back here.
+func (app *application) invitePage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token := r.PathValue("token")
+
+	invite, err := app.services.WorkspaceService.GetInvite(ctx, token)
+	if err != nil {
+		if errors.Is(err, entities.ErrInviteUnavailable) {
+			w.WriteHeader(http.StatusGone)
+			page := ui.Base("Workspace invite", "Workspace invite", ui.InviteUnavailable())
+			page.Render(ctx, w)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting workspace invite")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.setCookie(w, inviteCookieName, token, invite.ExpiresAt)
+	}
+
+	page := ui.Base("Workspace invite", "Workspace invite", ui.AcceptInvite(app.config, invite, token, user, ""))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) acceptInvite(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.PathValue("token")
+
+	member, err := app.services.WorkspaceService.AcceptInvite(ctx, token, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInviteUnavailable):
+			w.WriteHeader(http.StatusGone)
+			page := ui.Base("Workspace invite", "Workspace invite", ui.InviteUnavailable())
+			page.Render(ctx, w)
+		case errors.Is(err, entities.ErrInviteEmailMismatch):
+			invite, err := app.services.WorkspaceService.GetInvite(ctx, token)
+			if err != nil {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			w.WriteHeader(http.StatusForbidden)
+			message := fmt.Sprintf("This invite was sent to %s. Sign in with that account to accept it.", invite.Email)
+			page := ui.Base("Workspace invite", "Workspace invite", ui.AcceptInvite(app.config, invite, token, user, message))
+			page.Render(ctx, w)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error accepting workspace invite")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.setCookie(w, workspaceCookieName, member.WorkspaceID.String(), time.Now().Add(workspaceCookieLength))
+
+	http.Redirect(w, r, "/links", http.StatusSeeOther)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) magicLinkPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if usercontext.ContextGetUser(ctx) != nil {
+		http.Redirect(w, r, "/links", http.StatusSeeOther)
+		return
+	}
+
+	page := ui.Base("Email me a link", "Login without a password", ui.MagicLink(dto.MagicLinkForm{}, false))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) requestMagicLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.MagicLinkForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Enter a valid email address")
+
+	if !form.Valid() {
+		page := ui.Base("Email me a link", "Login without a password", ui.MagicLink(form, false))
+		page.Render(ctx, w)
+		return
+	}
+
+	// As with password resets, requests are also counted per address and the
+	// page doesn't change when the limit is hit.
+	if app.magicLimiter.Allow(strings.ToLower(form.Email)) {
+		err = app.services.UserService.RequestLoginLink(ctx, form.Email)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error requesting login link")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		app.logger.Warn().Ctx(ctx).Msg("Login link rate limit exceeded")
+	}
+
+	page := ui.Base("Email me a link", "Login without a password", ui.MagicLink(form, true))
+
+	page.Render(ctx, w)
+}
+
+// magicLinkLoginPage asks the user to confirm before the link is used, so mail
+// scanners that open links don't use it up.
+func (app *application) magicLinkLoginPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	page := ui.Base("Login", "Login with your emailed link", ui.MagicLinkLogin(r.PathValue("token"), true))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) magicLinkLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	session, err := app.services.UserService.LoginWithLink(ctx, r.PathValue("token"))
+	if err != nil {
+		if errors.Is(err, entities.ErrLoginLinkInvalid) {
+			page := ui.Base("Login", "Login with your emailed link", ui.MagicLinkLogin("", false))
+			w.WriteHeader(http.StatusBadRequest)
+			page.Render(ctx, w)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error logging in with login link")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.completeLogin(w, r, session, "")
+}
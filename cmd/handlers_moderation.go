@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) moderationPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	reviews, err := app.services.ModerationService.GetQuarantineQueue(ctx)
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting quarantine queue")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	moderation := ui.Base("Moderation", "Quarantine review queue", ui.ModerationQueue(reviews))
+
+	moderation.Render(ctx, w)
+}
+
+func (app *application) approveQuarantine(w http.ResponseWriter, r *http.Request) {
+	app.decideQuarantine(w, r, app.services.ModerationService.ApproveQuarantine)
+}
+
+func (app *application) rejectQuarantine(w http.ResponseWriter, r *http.Request) {
+	app.decideQuarantine(w, r, app.services.ModerationService.RejectQuarantine)
+}
+
+func (app *application) blockQuarantinedDomain(w http.ResponseWriter, r *http.Request) {
+	app.decideQuarantine(w, r, app.services.ModerationService.BlockQuarantinedDomain)
+}
+
+func (app *application) decideQuarantine(w http.ResponseWriter, r *http.Request, decide func(ctx context.Context, quarantineID uuid.UUID, moderatorID uuid.UUID) error) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	quarantineUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing quarantine ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	err = decide(ctx, quarantineUUID, user.ID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error deciding quarantine")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/moderation", http.StatusSeeOther)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) forgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	page := ui.Base("Forgot password", "Reset your password", ui.ForgotPassword(dto.ForgotPasswordForm{}, false))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.ForgotPasswordForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.Email = strings.TrimSpace(form.Email)
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.Matches(form.Email, validator.EmailRX), "email", "Enter a valid email address")
+
+	if !form.Valid() {
+		page := ui.Base("Forgot password", "Reset your password", ui.ForgotPassword(form, false))
+		page.Render(ctx, w)
+		return
+	}
+
+	// Requests are counted per address on top of the per client limit on the
+	// route, so nobody can flood one inbox. The page looks the same either
+	// way so it doesn't reveal which addresses have accounts.
+	if app.resetLimiter.Allow(strings.ToLower(form.Email)) {
+		err = app.services.UserService.RequestPasswordReset(ctx, form.Email)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error requesting password reset")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		app.logger.Warn().Ctx(ctx).Msg("Password reset rate limit exceeded")
+	}
+
+	page := ui.Base("Forgot password", "Reset your password", ui.ForgotPassword(form, true))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) resetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token := r.PathValue("token")
+
+	// The token is in the URL, so don't hand it to other sites.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	err := app.services.UserService.CheckPasswordReset(ctx, token)
+	if err != nil {
+		if errors.Is(err, entities.ErrPasswordResetInvalid) {
+			app.renderResetPassword(w, r, http.StatusBadRequest, token, dto.ResetPasswordForm{}, false)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error checking password reset")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.renderResetPassword(w, r, http.StatusOK, token, dto.ResetPasswordForm{}, true)
+}
+
+func (app *application) renderResetPassword(w http.ResponseWriter, r *http.Request, status int, token string, form dto.ResetPasswordForm, valid bool) {
+	form.Password = ""
+	form.ConfirmPassword = ""
+
+	page := ui.Base("Reset password", "Choose a new password", ui.ResetPassword(token, form, valid))
+
+	w.WriteHeader(status)
+	page.Render(r.Context(), w)
+}
+
+func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	token := r.PathValue("token")
+
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	var form dto.ResetPasswordForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	checkNewPassword(&form.Validator, "password", form.Password, "")
+	form.CheckField(form.Password == form.ConfirmPassword, "confirm_password", "Passwords do not match")
+
+	if !form.Valid() {
+		app.renderResetPassword(w, r, http.StatusUnprocessableEntity, token, form, true)
+		return
+	}
+
+	err = app.services.UserService.ResetPassword(ctx, token, form.Password)
+	if err != nil {
+		if errors.Is(err, entities.ErrPasswordResetInvalid) || errors.Is(err, entities.ErrNoPasswordSet) {
+			app.renderResetPassword(w, r, http.StatusBadRequest, token, form, false)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error resetting password")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Every session was signed out, including any in this browser.
+	app.clearCookie(w, sessionCookieName)
+
+	http.Redirect(w, r, "/login?reset=done", http.StatusSeeOther)
+}
+
+func (app *application) changePasswordPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if usercontext.ContextGetUser(ctx) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	app.renderChangePassword(w, r, http.StatusOK, dto.ChangePasswordForm{}, false)
+}
+
+func (app *application) renderChangePassword(w http.ResponseWriter, r *http.Request, status int, form dto.ChangePasswordForm, changed bool) {
+	user := usercontext.ContextGetUser(r.Context())
+
+	form.CurrentPassword = ""
+	form.NewPassword = ""
+	form.ConfirmPassword = ""
+
+	hasPassword := user.Identity(entities.ProviderPassword) != nil
+
+	page := ui.Base("Change password", "Change your password", ui.ChangePassword(form, hasPassword, changed))
+
+	w.WriteHeader(status)
+	page.Render(r.Context(), w)
+}
+
+func (app *application) changePassword(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.ChangePasswordForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.CurrentPassword), "current_password", "This field cannot be blank")
+	checkNewPassword(&form.Validator, "new_password", form.NewPassword, user.Email)
+	form.CheckField(form.NewPassword != form.CurrentPassword, "new_password", "Choose a password you haven't been using")
+	form.CheckField(form.NewPassword == form.ConfirmPassword, "confirm_password", "Passwords do not match")
+
+	if !form.Valid() {
+		app.renderChangePassword(w, r, http.StatusUnprocessableEntity, form, false)
+		return
+	}
+
+	// Guesses at the current password count against the same per account
+	// limit as logins.
+	throttleKey := strings.ToLower(user.Email)
+	if !app.loginLimiter.Allow(throttleKey) {
+		app.logger.Warn().Ctx(ctx).Str("userID", user.ID.String()).Msg("Change password rate limit exceeded")
+		form.AddNonFieldError("Too many attempts. Try again later.")
+		app.renderChangePassword(w, r, http.StatusTooManyRequests, form, false)
+		return
+	}
+
+	err = app.services.UserService.ChangePassword(ctx, services.ChangePasswordArgs{
+		UserID:          user.ID,
+		CurrentPassword: form.CurrentPassword,
+		NewPassword:     form.NewPassword,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidCredentials):
+			form.AddFieldError("current_password", "Your current password is incorrect")
+			app.renderChangePassword(w, r, http.StatusUnprocessableEntity, form, false)
+		case errors.Is(err, entities.ErrNoPasswordSet):
+			app.renderChangePassword(w, r, http.StatusConflict, form, false)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error changing password")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.loginLimiter.Reset(throttleKey)
+
+	app.renderChangePassword(w, r, http.StatusOK, dto.ChangePasswordForm{}, true)
+}
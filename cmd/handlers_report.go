@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) previewPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	slugParam := r.PathValue("slug")
+
+	linkEntity, err := app.services.LinkService.GetLinkByShortenedURL(ctx, slugParam)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting link by shortened URL")
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	preview := ui.Base("Link preview", "Link preview page", ui.Preview(linkEntity, dto.ReportLinkForm{}, false))
+
+	preview.Render(ctx, w)
+}
+
+func (app *application) reportLink(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	slugParam := r.PathValue("slug")
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+
+	linkEntity, err := app.services.LinkService.GetLinkByShortenedURL(ctx, slugParam)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting link by shortened URL")
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	var form dto.ReportLinkForm
+
+	if isJSON {
+		err = app.readJSON(w, r, &form)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error decoding report")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	} else {
+		err = r.ParseForm()
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		err = app.formDecoder.Decode(&form, r.PostForm)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// The website field is hidden from people, so anything in it came from a bot.
+	// Answer as if the report was accepted so the bot learns nothing.
+	if form.Website != "" {
+		app.logger.Warn().Ctx(ctx).Str("slug", slugParam).Msg("Report honeypot triggered")
+		app.reportAccepted(w, r, isJSON, linkEntity)
+		return
+	}
+
+	form.CheckField(validator.PermittedValue(form.Category, entities.ReportCategories...), "category", "Choose a category")
+	form.CheckField(validator.MaxChars(form.Comment, 1000), "comment", "This field cannot be more than 1000 characters long")
+
+	if !form.Valid() {
+		if isJSON {
+			app.writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": form.FieldErrors})
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		preview := ui.Base("Link preview", "Link preview page", ui.Preview(linkEntity, form, false))
+		preview.Render(ctx, w)
+		return
+	}
+
+	var reporterID *uuid.UUID
+	if user := usercontext.ContextGetUser(ctx); user != nil {
+		reporterID = &user.ID
+	}
+
+	var comment *string
+	if validator.NotBlank(form.Comment) {
+		comment = &form.Comment
+	}
+
+	_, err = app.services.ReportService.ReportLink(ctx, services.ReportLinkArgs{
+		Slug:       slugParam,
+		ReporterID: reporterID,
+		IPAddress:  clientIP(r),
+		Category:   form.Category,
+		Comment:    comment,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrDuplicateReport):
+			app.logger.Info().Ctx(ctx).Str("slug", slugParam).Msg("Duplicate report ignored")
+		case errors.Is(err, repositories.ErrNotFound):
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error reporting link")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	app.reportAccepted(w, r, isJSON, linkEntity)
+}
+
+func (app *application) reportAccepted(w http.ResponseWriter, r *http.Request, isJSON bool, linkEntity *entities.LinkEntity) {
+	if isJSON {
+		app.writeJSON(w, http.StatusAccepted, map[string]any{"reported": true})
+		return
+	}
+
+	preview := ui.Base("Link preview", "Link preview page", ui.Preview(linkEntity, dto.ReportLinkForm{}, true))
+	preview.Render(r.Context(), w)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) rolesPage(w http.ResponseWriter, r *http.Request) {
+	app.renderRoles(w, r, dto.SetRoleForm{}, "")
+}
+
+func (app *application) renderRoles(w http.ResponseWriter, r *http.Request, form dto.SetRoleForm, message string) {
+	ctx := r.Context()
+
+	staff, err := app.services.UserService.GetStaff(ctx)
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting staff")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	policies, err := app.services.TwoFactorService.GetRolePolicies(ctx)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting role policies")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	roles := ui.Base("Roles", "Moderators and admins", ui.AdminRoles(staff, policies, form, message))
+
+	roles.Render(ctx, w)
+}
+
+func (app *application) setUserRole(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.SetRoleForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Role, entities.Roles...), "role", "Choose a role")
+
+	if !form.Valid() {
+		app.renderRoles(w, r, form, "")
+		return
+	}
+
+	user, err := app.services.UserService.SetUserRole(ctx, services.SetUserRoleArgs{
+		Email: form.Email,
+		Role:  form.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrUserNotFound):
+			form.AddFieldError("email", "No user with this email")
+		case errors.Is(err, entities.ErrForbidden):
+			form.AddFieldError("role", "You cannot remove your own admin role")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error setting user role")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		app.renderRoles(w, r, form, "")
+		return
+	}
+
+	app.renderRoles(w, r, dto.SetRoleForm{}, fmt.Sprintf("%s is now a %s", user.Email, user.Role))
+}
+
+func (app *application) setRolePolicy(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var form dto.RolePolicyForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	policy, err := app.services.TwoFactorService.SetRequireTwoFactor(ctx, form.Role, form.RequireTwoFactor)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidRole):
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error setting role policy")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	message := fmt.Sprintf("Two-factor is now optional for %ss", policy.Role)
+	if policy.RequireTwoFactor {
+		message = fmt.Sprintf("Two-factor is now required for %ss", policy.Role)
+	}
+
+	app.renderRoles(w, r, dto.SetRoleForm{}, message)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) logout(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	err := app.services.UserService.Logout(ctx)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error logging out")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// Logging out during an impersonation also ends the admin's own session,
+	// which would otherwise be left behind in the browser.
+	if cookie, err := r.Cookie(impersonatorCookieName); err == nil {
+		admin, adminSession, err := app.services.UserService.GetUserBySessionToken(ctx, cookie.Value)
+		if err == nil && !adminSession.IsExpired() {
+			err = app.services.UserService.RevokeSession(ctx, admin.ID, adminSession.ID)
+			if err != nil && !errors.Is(err, entities.ErrSessionNotFound) {
+				app.logger.Err(err).Ctx(ctx).Msg("Error expiring impersonator session")
+			}
+		}
+		app.clearCookie(w, impersonatorCookieName)
+	}
+
+	app.clearCookie(w, sessionCookieName)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (app *application) sessionsPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	sessions, err := app.services.UserService.GetSessions(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting sessions")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	page := ui.Base("Sessions", "Where you are signed in", ui.Sessions(sessions, usercontext.ContextGetSession(ctx)))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) revokeSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	err = app.services.UserService.RevokeSession(ctx, user.ID, sessionID)
+	if err != nil {
+		if errors.Is(err, entities.ErrSessionNotFound) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error revoking session")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if current := usercontext.ContextGetSession(ctx); current != nil && current.ID == sessionID {
+		app.clearCookie(w, sessionCookieName)
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/settings/sessions", http.StatusSeeOther)
+}
+
+func (app *application) signOutEverywhere(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	expired, err := app.services.UserService.SignOutEverywhere(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error signing out everywhere")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.logger.Info().Ctx(ctx).Str("userID", user.ID.String()).Int("sessions", expired).Msg("Signed out everywhere")
+
+	app.clearCookie(w, sessionCookieName)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+func (app *application) transfersPage(w http.ResponseWriter, r *http.Request) {
+	app.renderTransfers(w, r, dto.TransferLinksForm{}, "")
+}
+
+func (app *application) renderTransfers(w http.ResponseWriter, r *http.Request, form dto.TransferLinksForm, message string) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transfers, err := app.services.TransferService.GetTransfers(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting link transfers")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	page := ui.Base("Transfers", "Link transfers", ui.Transfers(transfers, form, message))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) requestTransfer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.TransferLinksForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	linkIDs := []uuid.UUID{}
+	for _, rawID := range form.LinkIDs {
+		linkID, err := uuid.Parse(rawID)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error parsing link ID")
+			http.Error(w, "Malformed UUID", http.StatusBadRequest)
+			return
+		}
+		linkIDs = append(linkIDs, linkID)
+	}
+
+	var workspaceID *uuid.UUID
+	if form.WorkspaceID != "" {
+		workspaceUUID, err := uuid.Parse(form.WorkspaceID)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+			http.Error(w, "Malformed UUID", http.StatusBadRequest)
+			return
+		}
+		workspaceID = &workspaceUUID
+	}
+
+	form.CheckField(len(linkIDs) > 0, "link_id", "Select at least one link to transfer")
+	form.CheckField(validator.NotBlank(form.Email) != (workspaceID != nil), "email", "Choose either a user or a workspace to transfer to")
+
+	if !form.Valid() {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		app.renderTransfers(w, r, form, "")
+		return
+	}
+
+	transfers, err := app.services.TransferService.RequestTransfer(ctx, services.RequestTransferArgs{
+		UserID:        user.ID,
+		LinkIDs:       linkIDs,
+		ToEmail:       form.Email,
+		ToWorkspaceID: workspaceID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		case errors.Is(err, repositories.ErrNotFound):
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		case errors.Is(err, entities.ErrUserNotFound):
+			form.AddFieldError("email", "No user with this email")
+		case errors.Is(err, entities.ErrTransferToOwner):
+			form.AddFieldError("email", "A selected link already belongs to the recipient")
+		case errors.Is(err, entities.ErrTransferPending):
+			form.AddFieldError("link_id", "A selected link already has a pending transfer")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error requesting link transfer")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		app.renderTransfers(w, r, form, "")
+		return
+	}
+
+	app.renderTransfers(w, r, dto.TransferLinksForm{}, fmt.Sprintf("Offered %d link(s)", len(transfers)))
+}
+
+func (app *application) acceptTransfer(w http.ResponseWriter, r *http.Request) {
+	app.decideTransfer(w, r, func(transferID, userID uuid.UUID) error {
+		_, err := app.services.TransferService.AcceptTransfer(r.Context(), transferID, userID)
+		return err
+	})
+}
+
+func (app *application) declineTransfer(w http.ResponseWriter, r *http.Request) {
+	app.decideTransfer(w, r, func(transferID, userID uuid.UUID) error {
+		return app.services.TransferService.DeclineTransfer(r.Context(), transferID, userID)
+	})
+}
+
+func (app *application) cancelTransfer(w http.ResponseWriter, r *http.Request) {
+	app.decideTransfer(w, r, func(transferID, userID uuid.UUID) error {
+		return app.services.TransferService.CancelTransfer(r.Context(), transferID, userID)
+	})
+}
+
+func (app *application) decideTransfer(w http.ResponseWriter, r *http.Request, decide func(transferID, userID uuid.UUID) error) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	transferUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing transfer ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	err = decide(transferUUID, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case errors.Is(err, repositories.ErrNotFound):
+			http.Error(w, "Not Found", http.StatusNotFound)
+		case errors.Is(err, entities.ErrTransferUnavailable):
+			app.renderTransfers(w, r, dto.TransferLinksForm{}, "This transfer has already been decided")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error deciding link transfer")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.Redirect(w, r, "/transfers", http.StatusSeeOther)
+}
+
+func (app *application) reassignLinks(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	var form dto.ReassignLinksForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if !validator.NotBlank(form.FromEmail) || !validator.NotBlank(form.ToEmail) {
+		app.renderRoles(w, r, dto.SetRoleForm{}, "Enter both emails to reassign links")
+		return
+	}
+
+	reassigned, err := app.services.TransferService.ReassignLinks(ctx, services.ReassignLinksArgs{
+		FromEmail: form.FromEmail,
+		ToEmail:   form.ToEmail,
+		AdminID:   user.ID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case errors.Is(err, entities.ErrUserNotFound):
+			app.renderRoles(w, r, dto.SetRoleForm{}, "No user with this email")
+		case errors.Is(err, entities.ErrTransferToOwner):
+			app.renderRoles(w, r, dto.SetRoleForm{}, "Choose two different users")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error reassigning links")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.renderRoles(w, r, dto.SetRoleForm{}, fmt.Sprintf("Reassigned %d link(s) from %s to %s", reassigned, form.FromEmail, form.ToEmail))
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+	"github.com/skip2/go-qrcode"
+)
+
+// twoFactorThrottleKey counts guesses at a user's codes against the login
+// limiter, wherever the code is entered.
+func twoFactorThrottleKey(userID uuid.UUID) string {
+	return "two-factor:" + userID.String()
+}
+
+func (app *application) twoFactorLoginPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	session := usercontext.ContextGetSession(ctx)
+	if session == nil || !session.TwoFactorPending || session.IsExpired() {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	form := dto.TwoFactorForm{ReturnTo: app.safeReturnTo(r.URL.Query().Get("return_to"))}
+
+	page := ui.Base("Two-factor", "Enter your code", ui.TwoFactorLogin(form))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) twoFactorLogin(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	pending := usercontext.ContextGetSession(ctx)
+	if pending == nil || !pending.TwoFactorPending || pending.IsExpired() {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	var form dto.TwoFactorForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if !form.Valid() {
+		app.renderTwoFactorLogin(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	throttleKey := twoFactorThrottleKey(pending.UserID)
+	if !app.loginLimiter.Allow(throttleKey) {
+		app.logger.Warn().Ctx(ctx).Str("userID", pending.UserID.String()).Msg("Two-factor rate limit exceeded")
+		form.AddNonFieldError("Too many attempts. Try again later.")
+		app.renderTwoFactorLogin(w, r, http.StatusTooManyRequests, form)
+		return
+	}
+
+	session, err := app.services.TwoFactorService.CompleteLogin(ctx, form.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidTwoFactorCode):
+			form.AddFieldError("code", "That code didn't work. Try the next one from your app.")
+			app.renderTwoFactorLogin(w, r, http.StatusUnprocessableEntity, form)
+		case errors.Is(err, entities.ErrSessionNotFound), errors.Is(err, entities.ErrTwoFactorNotEnabled):
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error completing two-factor login")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.loginLimiter.Reset(throttleKey)
+
+	app.completeLogin(w, r, session, form.ReturnTo)
+}
+
+func (app *application) renderTwoFactorLogin(w http.ResponseWriter, r *http.Request, status int, form dto.TwoFactorForm) {
+	form.Code = ""
+
+	page := ui.Base("Two-factor", "Enter your code", ui.TwoFactorLogin(form))
+
+	w.WriteHeader(status)
+	page.Render(r.Context(), w)
+}
+
+func (app *application) twoFactorPage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if usercontext.ContextGetUser(ctx) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	app.renderTwoFactor(w, r, http.StatusOK, dto.TwoFactorForm{}, "")
+}
+
+// renderTwoFactor shows the signed in user's two-factor settings, with
+// message as an error when it isn't empty.
+func (app *application) renderTwoFactor(w http.ResponseWriter, r *http.Request, status int, form dto.TwoFactorForm, message string) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	twoFactor, err := app.services.TwoFactorService.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting two-factor")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	required, err := app.services.TwoFactorService.Required(ctx, user)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error checking two-factor requirement")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	form.Code = ""
+
+	page := ui.Base("Two-factor", "Two-factor authentication", ui.TwoFactorSettings(twoFactor, required, form, message))
+
+	w.WriteHeader(status)
+	page.Render(ctx, w)
+}
+
+func (app *application) startTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enrolment, err := app.services.TwoFactorService.StartEnrolment(ctx, user)
+	if err != nil {
+		if errors.Is(err, entities.ErrTwoFactorAlreadyEnabled) {
+			http.Redirect(w, r, "/settings/two-factor", http.StatusSeeOther)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error starting two-factor enrolment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.renderTwoFactorSetup(w, r, http.StatusOK, enrolment.Secret, dto.TwoFactorForm{})
+}
+
+func (app *application) renderTwoFactorSetup(w http.ResponseWriter, r *http.Request, status int, secret string, form dto.TwoFactorForm) {
+	form.Code = ""
+
+	// The page carries the secret, so it mustn't be cached or leak through a
+	// referrer.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	page := ui.Base("Two-factor", "Set up two-factor authentication", ui.TwoFactorSetup(secret, form))
+
+	w.WriteHeader(status)
+	page.Render(r.Context(), w)
+}
+
+// twoFactorQRCode draws the unconfirmed enrolment's otpauth URL for the user
+// to scan.
+func (app *application) twoFactorQRCode(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enrolment, err := app.services.TwoFactorService.GetEnrolment(ctx, user)
+	if err != nil {
+		if errors.Is(err, entities.ErrTwoFactorNotStarted) || errors.Is(err, entities.ErrTwoFactorAlreadyEnabled) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting two-factor enrolment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	code, err := qrcode.Encode(enrolment.URL, qrcode.Medium, 256)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error generating QR code")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(code)
+}
+
+// decodeTwoFactorForm reads a form with a code in it and counts the attempt
+// against the user's limit. It writes the response and returns false when the
+// handler should stop.
+func (app *application) decodeTwoFactorForm(w http.ResponseWriter, r *http.Request, user *entities.User, form *dto.TwoFactorForm) bool {
+	ctx := r.Context()
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return false
+	}
+
+	err = app.formDecoder.Decode(form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return false
+	}
+
+	form.CheckField(validator.NotBlank(form.Code), "code", "This field cannot be blank")
+
+	if form.Valid() && !app.loginLimiter.Allow(twoFactorThrottleKey(user.ID)) {
+		app.logger.Warn().Ctx(ctx).Str("userID", user.ID.String()).Msg("Two-factor rate limit exceeded")
+		form.AddNonFieldError("Too many attempts. Try again later.")
+	}
+
+	return true
+}
+
+func (app *application) confirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.TwoFactorForm
+
+	if !app.decodeTwoFactorForm(w, r, user, &form) {
+		return
+	}
+
+	enrolment, err := app.services.TwoFactorService.GetEnrolment(ctx, user)
+	if err != nil {
+		if errors.Is(err, entities.ErrTwoFactorNotStarted) || errors.Is(err, entities.ErrTwoFactorAlreadyEnabled) {
+			http.Redirect(w, r, "/settings/two-factor", http.StatusSeeOther)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting two-factor enrolment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if !form.Valid() {
+		app.renderTwoFactorSetup(w, r, http.StatusUnprocessableEntity, enrolment.Secret, form)
+		return
+	}
+
+	codes, err := app.services.TwoFactorService.ConfirmEnrolment(ctx, user.ID, form.Code)
+	if err != nil {
+		if errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			form.AddFieldError("code", "That code didn't work. Check your app's clock and try the next one.")
+			app.renderTwoFactorSetup(w, r, http.StatusUnprocessableEntity, enrolment.Secret, form)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error confirming two-factor enrolment")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.loginLimiter.Reset(twoFactorThrottleKey(user.ID))
+
+	app.renderRecoveryCodes(w, r, codes)
+}
+
+func (app *application) renderRecoveryCodes(w http.ResponseWriter, r *http.Request, codes []string) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	page := ui.Base("Recovery codes", "Your recovery codes", ui.RecoveryCodes(codes))
+
+	page.Render(r.Context(), w)
+}
+
+func (app *application) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.TwoFactorForm
+
+	if !app.decodeTwoFactorForm(w, r, user, &form) {
+		return
+	}
+
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	err := app.services.TwoFactorService.Disable(ctx, user, form.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidTwoFactorCode):
+			form.AddFieldError("code", "That code didn't work")
+			app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form, "")
+		case errors.Is(err, entities.ErrTwoFactorRequired):
+			app.renderTwoFactor(w, r, http.StatusConflict, form, "Your role requires two-factor authentication, so it can't be turned off.")
+		case errors.Is(err, entities.ErrTwoFactorNotEnabled):
+			http.Redirect(w, r, "/settings/two-factor", http.StatusSeeOther)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error disabling two-factor")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.loginLimiter.Reset(twoFactorThrottleKey(user.ID))
+
+	http.Redirect(w, r, "/settings/two-factor", http.StatusSeeOther)
+}
+
+func (app *application) replaceRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.TwoFactorForm
+
+	if !app.decodeTwoFactorForm(w, r, user, &form) {
+		return
+	}
+
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	codes, err := app.services.TwoFactorService.ReplaceRecoveryCodes(ctx, user.ID, form.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrInvalidTwoFactorCode):
+			form.AddFieldError("code", "That code didn't work")
+			app.renderTwoFactor(w, r, http.StatusUnprocessableEntity, form, "")
+		case errors.Is(err, entities.ErrTwoFactorNotEnabled):
+			http.Redirect(w, r, "/settings/two-factor", http.StatusSeeOther)
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error replacing recovery codes")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	app.loginLimiter.Reset(twoFactorThrottleKey(user.ID))
+
+	app.renderRecoveryCodes(w, r, codes)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/dto"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/mcorrigan89/url_shortener/internal/validator"
+	"github.com/mcorrigan89/url_shortener/ui"
+)
+
+// workspaceCookieLength is how long the workspace switcher remembers the
+// user's choice.
+const workspaceCookieLength = 365 * 24 * time.Hour
+
+func (app *application) workspacesPage(w http.ResponseWriter, r *http.Request) {
+	app.renderWorkspaces(w, r, dto.CreateWorkspaceForm{})
+}
+
+func (app *application) renderWorkspaces(w http.ResponseWriter, r *http.Request, form dto.CreateWorkspaceForm) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaces, err := app.services.WorkspaceService.GetWorkspacesByUserID(ctx, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting workspaces by user ID")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	page := ui.Base("Workspaces", "Workspaces page", ui.Workspaces(workspaces, app.currentWorkspace(r, user.ID), form))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) createWorkspace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.CreateWorkspaceForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		app.renderWorkspaces(w, r, form)
+		return
+	}
+
+	member, err := app.services.WorkspaceService.CreateWorkspace(ctx, services.CreateWorkspaceArgs{
+		UserID: user.ID,
+		Name:   form.Name,
+	})
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error creating workspace")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.setCookie(w, workspaceCookieName, member.WorkspaceID.String(), time.Now().Add(workspaceCookieLength))
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/%s", member.WorkspaceID), http.StatusSeeOther)
+}
+
+// switchWorkspace changes which workspace the links pages work in. An empty
+// workspace id switches back to the user's personal links.
+func (app *application) switchWorkspace(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var form dto.SwitchWorkspaceForm
+
+	err := r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if form.WorkspaceID == "" {
+		app.clearCookie(w, workspaceCookieName)
+		http.Redirect(w, r, "/links", http.StatusSeeOther)
+		return
+	}
+
+	workspaceUUID, err := uuid.Parse(form.WorkspaceID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	_, err = app.services.WorkspaceService.GetMembership(ctx, workspaceUUID, user.ID)
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting workspace membership")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	app.setCookie(w, workspaceCookieName, workspaceUUID.String(), time.Now().Add(workspaceCookieLength))
+
+	http.Redirect(w, r, "/links", http.StatusSeeOther)
+}
+
+func (app *application) workspacePage(w http.ResponseWriter, r *http.Request) {
+	app.renderWorkspace(w, r, dto.WorkspaceMemberForm{}, "")
+}
+
+func (app *application) renderWorkspace(w http.ResponseWriter, r *http.Request, form dto.WorkspaceMemberForm, message string) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	membership, err := app.services.WorkspaceService.GetMembership(ctx, workspaceUUID, user.ID)
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting workspace membership")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	members, err := app.services.WorkspaceService.GetMembers(ctx, workspaceUUID, user.ID)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error getting workspace members")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var invites []*entities.WorkspaceInvite
+	if membership.CanManageMembers() {
+		invites, err = app.services.WorkspaceService.GetPendingInvites(ctx, workspaceUUID, user.ID)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error getting workspace invites")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	page := ui.Base(membership.Workspace.Name, "Workspace members", ui.WorkspaceMembers(membership, members, invites, form, message))
+
+	page.Render(ctx, w)
+}
+
+func (app *application) setWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	var form dto.WorkspaceMemberForm
+
+	err = r.ParseForm()
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	err = app.formDecoder.Decode(&form, r.PostForm)
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error decoding form")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Email), "email", "This field cannot be blank")
+	form.CheckField(validator.PermittedValue(form.Role, entities.WorkspaceRoles...), "role", "Choose a role")
+
+	if !form.Valid() {
+		app.renderWorkspace(w, r, form, "")
+		return
+	}
+
+	member, err := app.services.WorkspaceService.SetMember(ctx, services.SetMemberArgs{
+		WorkspaceID: workspaceUUID,
+		ActorID:     user.ID,
+		Email:       form.Email,
+		Role:        form.Role,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		case errors.Is(err, entities.ErrUserNotFound):
+			form.AddFieldError("email", "No user with this email")
+		case errors.Is(err, entities.ErrLastWorkspaceOwner):
+			form.AddFieldError("role", "The workspace must keep at least one owner")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error setting workspace member")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		app.renderWorkspace(w, r, form, "")
+		return
+	}
+
+	app.renderWorkspace(w, r, dto.WorkspaceMemberForm{}, fmt.Sprintf("%s is now a workspace %s", member.User.Email, member.Role))
+}
+
+func (app *application) removeWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	user := usercontext.ContextGetUser(ctx)
+
+	if user == nil {
+		app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workspaceUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing workspace ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	memberUUID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		app.logger.Err(err).Ctx(ctx).Msg("Error parsing member ID")
+		http.Error(w, "Malformed UUID", http.StatusBadRequest)
+		return
+	}
+
+	err = app.services.WorkspaceService.RemoveMember(ctx, services.RemoveMemberArgs{
+		WorkspaceID: workspaceUUID,
+		ActorID:     user.ID,
+		UserID:      memberUUID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, entities.ErrForbidden):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case errors.Is(err, repositories.ErrNotFound):
+			http.Error(w, "Not Found", http.StatusNotFound)
+		case errors.Is(err, entities.ErrLastWorkspaceOwner):
+			app.renderWorkspace(w, r, dto.WorkspaceMemberForm{}, "The workspace must keep at least one owner")
+		default:
+			app.logger.Err(err).Ctx(ctx).Msg("Error removing workspace member")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if memberUUID == user.ID {
+		app.clearCookie(w, workspaceCookieName)
+		http.Redirect(w, r, "/workspaces", http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/workspaces/%s", workspaceUUID), http.StatusSeeOther)
+}
@@ -5,8 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
 )
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
@@ -56,3 +63,118 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dst any
 
 	return nil
 }
+
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) error {
+	js, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(js)
+
+	return nil
+}
+
+// clientIP returns the address of the client without its port.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+const (
+	sessionCookieName      = "x-session-token"
+	impersonatorCookieName = "x-impersonator-session-token"
+	workspaceCookieName    = "x-workspace-id"
+	inviteCookieName       = "x-workspace-invite"
+	loginAttemptCookieName = "x-login-attempt"
+	pendingLinkCookieName  = "x-pending-link"
+	csrfCookieName         = "x-csrf-token"
+)
+
+func (app *application) setCookie(w http.ResponseWriter, name, value string, expiresAt time.Time) {
+	cookie := http.Cookie{
+		Name:     name,
+		Value:    value,
+		Secure:   true,
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   app.config.ClientURL,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// setSessionCookie stores a newly created session's token. The cookie lasts
+// until the session's absolute expiry; idle expiry is enforced server side.
+func (app *application) setSessionCookie(w http.ResponseWriter, session *entities.UserSession) {
+	app.setCookie(w, sessionCookieName, session.Token, session.AbsoluteExpiresAt)
+}
+
+func (app *application) clearCookie(w http.ResponseWriter, name string) {
+	cookie := http.Cookie{
+		Name:     name,
+		Value:    "",
+		Secure:   true,
+		Path:     "/",
+		Domain:   app.config.ClientURL,
+		MaxAge:   -1,
+		SameSite: http.SameSiteLaxMode,
+	}
+	http.SetCookie(w, &cookie)
+}
+
+// safeReturnTo returns target if it is a path on this site or a URL on one of
+// the allowed origins, and "" otherwise, so a login link can't be used to send
+// people to another site.
+func (app *application) safeReturnTo(target string) string {
+	if target == "" {
+		return ""
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		// Browsers read "//host" and "/\host" as another site.
+		if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+			return ""
+		}
+		return target
+	}
+
+	if slices.Contains(app.config.Login.ReturnToOrigins, u.Scheme+"://"+u.Host) {
+		return target
+	}
+
+	return ""
+}
+
+// currentWorkspace returns the user's membership of the workspace picked with
+// the workspace switcher, or nil when they are working in their personal space
+// or are no longer a member of the one they picked.
+func (app *application) currentWorkspace(r *http.Request, userID uuid.UUID) *entities.WorkspaceMember {
+	cookie, err := r.Cookie(workspaceCookieName)
+	if err != nil {
+		return nil
+	}
+
+	workspaceID, err := uuid.Parse(cookie.Value)
+	if err != nil {
+		return nil
+	}
+
+	member, err := app.services.WorkspaceService.GetMembership(r.Context(), workspaceID, userID)
+	if err != nil {
+		return nil
+	}
+
+	return member
+}
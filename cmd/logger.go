@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/mcorrigan89/url_shortener/internal/config"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
 
 	"github.com/rs/zerolog"
 )
@@ -18,14 +19,6 @@ func getCorrelationIdFromContext(ctx context.Context) string {
 	return correlationId
 }
 
-func getSessionTokenFromContext(ctx context.Context) string {
-	sessionToken, ok := ctx.Value(sessionTokenKey).(string)
-	if !ok {
-		return ""
-	}
-	return sessionToken
-}
-
 func getIPFromContext(ctx context.Context) string {
 	ip, ok := ctx.Value(ipKey).(string)
 	if !ok {
@@ -46,6 +39,10 @@ func (h TracingHook) Run(e *zerolog.Event, level zerolog.Level, msg string) {
 	if ip != "" {
 		e.Str("ip_address", ip)
 	}
+	session := usercontext.ContextGetSession(ctx)
+	if session != nil && session.IsImpersonation() {
+		e.Str("impersonator_id", session.ImpersonatorID.String())
+	}
 }
 
 func getLogger(cfg config.Config) zerolog.Logger {
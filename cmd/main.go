@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-playground/form/v4"
 	"github.com/mcorrigan89/url_shortener/internal/config"
+	"github.com/mcorrigan89/url_shortener/internal/ratelimit"
 	"github.com/mcorrigan89/url_shortener/internal/repositories"
 	"github.com/mcorrigan89/url_shortener/internal/services"
 
@@ -13,11 +14,16 @@ import (
 )
 
 type application struct {
-	config      *config.Config
-	wg          *sync.WaitGroup
-	logger      *zerolog.Logger
-	services    *services.Services
-	formDecoder *form.Decoder
+	config        *config.Config
+	wg            *sync.WaitGroup
+	logger        *zerolog.Logger
+	services      *services.Services
+	formDecoder   *form.Decoder
+	reportLimiter *ratelimit.Limiter
+	loginLimiter  *ratelimit.Limiter
+	authLimiter   *ratelimit.Limiter
+	resetLimiter  *ratelimit.Limiter
+	magicLimiter  *ratelimit.Limiter
 }
 
 func main() {
@@ -43,11 +49,16 @@ func main() {
 	formDecoder := form.NewDecoder()
 
 	app := &application{
-		wg:          &wg,
-		config:      &cfg,
-		logger:      &logger,
-		services:    &services,
-		formDecoder: formDecoder,
+		wg:            &wg,
+		config:        &cfg,
+		logger:        &logger,
+		services:      &services,
+		formDecoder:   formDecoder,
+		reportLimiter: ratelimit.New(cfg.Reports.RateLimit, cfg.Reports.RateLimitWindow),
+		loginLimiter:  ratelimit.New(cfg.Login.RateLimit, cfg.Login.RateLimitWindow),
+		authLimiter:   ratelimit.New(cfg.Login.IPRateLimit, cfg.Login.RateLimitWindow),
+		resetLimiter:  ratelimit.New(cfg.PasswordReset.RateLimit, cfg.PasswordReset.RateLimitWindow),
+		magicLimiter:  ratelimit.New(cfg.MagicLink.RateLimit, cfg.MagicLink.RateLimitWindow),
 	}
 
 	err = app.serve()
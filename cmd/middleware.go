@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"log"
+	"mime"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/ratelimit"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
 	"github.com/mcorrigan89/url_shortener/internal/usercontext"
 	"github.com/rs/xid"
 )
@@ -14,7 +22,6 @@ type contextKey string
 const (
 	ipKey            contextKey = "ip"
 	correlationIDKey contextKey = "correlation_id"
-	sessionTokenKey  contextKey = "session_token_key"
 )
 
 func (app *application) contextBuilder(next http.Handler) http.Handler {
@@ -26,20 +33,29 @@ func (app *application) contextBuilder(next http.Handler) http.Handler {
 		ctx = context.WithValue(ctx, ipKey, r.RemoteAddr)
 		correlationID := xid.New().String()
 		ctx = context.WithValue(ctx, correlationIDKey, correlationID)
+		ctx = usercontext.ContextSetRequestInfo(ctx, &usercontext.RequestInfo{
+			IPAddress:     clientIP(r),
+			UserAgent:     r.UserAgent(),
+			CorrelationID: correlationID,
+		})
 
 		ctx = app.logger.WithContext(ctx)
 
-		sessionToken, err := r.Cookie("x-session-token")
+		sessionToken, err := r.Cookie(sessionCookieName)
 
 		if err == nil {
-			ctx = context.WithValue(ctx, sessionTokenKey, sessionToken.Value)
-			ctx = app.logger.WithContext(ctx)
-
 			user, session, err := app.services.UserService.GetUserBySessionToken(ctx, sessionToken.Value)
 
 			ctx = usercontext.ContextSetSession(ctx, session)
-			if err == nil && !session.IsExpired() {
+			// A session still waiting for its second factor doesn't sign
+			// anyone in; only the two-factor page looks at it.
+			if err == nil && !session.IsExpired() && !session.TwoFactorPending {
 				ctx = usercontext.ContextSetUser(ctx, user)
+
+				err = app.services.UserService.TouchSession(ctx, session)
+				if err != nil {
+					app.logger.Err(err).Ctx(ctx).Msg("Error recording session activity")
+				}
 			}
 		} else {
 			app.logger.Warn().Ctx(ctx).Msg("session cookie no present")
@@ -51,6 +67,139 @@ func (app *application) contextBuilder(next http.Handler) http.Handler {
 	})
 }
 
+const (
+	csrfFormField     = "csrf_token"
+	csrfHeader        = "X-CSRF-Token"
+	csrfCookieLength  = 365 * 24 * time.Hour
+	csrfTokenMaxBytes = 128
+)
+
+// csrfProtect rejects state-changing requests that don't echo the token in
+// the CSRF cookie back as a form field or header. Another site can make the
+// browser send the cookie but can't read it, so it can't forge the match.
+// Forms pick the token up from the request context with ui.CSRFField.
+//
+// JSON requests without a session cookie, like abuse reports posted by
+// scripts, are exempt: they aren't acting as anyone, and browsers won't send
+// a JSON body cross-site without a preflight, which enabledCORS only answers
+// for trusted origins.
+func (app *application) csrfProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		var token string
+		cookie, err := r.Cookie(csrfCookieName)
+		if err == nil && cookie.Value != "" && len(cookie.Value) <= csrfTokenMaxBytes {
+			token = cookie.Value
+		} else {
+			token, err = tokens.Generate()
+			if err != nil {
+				app.logger.Err(err).Ctx(ctx).Msg("Error generating CSRF token")
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			app.setCookie(w, csrfCookieName, token, time.Now().Add(csrfCookieLength))
+		}
+
+		ctx = usercontext.ContextSetCSRFToken(ctx, token)
+		r = r.WithContext(ctx)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if isJSONRequest(r) && !hasSessionCookie(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A freshly issued token can't match anything the client sent.
+		if cookie == nil || cookie.Value != token {
+			app.logger.Warn().Ctx(ctx).Str("path", r.URL.Path).Msg("CSRF cookie missing")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		sent := r.Header.Get(csrfHeader)
+		if sent == "" {
+			var err error
+			sent, err = app.csrfFormToken(w, r)
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				app.logger.Warn().Ctx(ctx).Str("path", r.URL.Path).Int64("limit", maxBytesErr.Limit).Msg("Request body too large")
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+		}
+
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			app.logger.Warn().Ctx(ctx).Str("path", r.URL.Path).Msg("CSRF token mismatch")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isJSONRequest reports whether the request body is JSON.
+func isJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// hasSessionCookie reports whether the request carries a session, including
+// an impersonator's.
+func hasSessionCookie(r *http.Request) bool {
+	for _, name := range []string{sessionCookieName, impersonatorCookieName} {
+		if cookie, err := r.Cookie(name); err == nil && cookie.Value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultMaxUploadSize caps multipart bodies on routes that don't take large
+// uploads.
+const defaultMaxUploadSize = 1 << 20
+
+// maxUploadSizes are the routes allowed larger multipart bodies.
+var maxUploadSizes = map[string]int64{
+	"POST /admin/blocklist/import": maxFeedUploadSize,
+}
+
+// maxUploadSize returns the largest multipart body the route accepts.
+func maxUploadSize(r *http.Request) int64 {
+	if size, ok := maxUploadSizes[r.Method+" "+r.URL.Path]; ok {
+		return size
+	}
+	return defaultMaxUploadSize
+}
+
+// csrfFormToken reads the token from the posted form. Multipart bodies are
+// capped at the route's upload limit before they are parsed, since the
+// handler's own limit would come too late. A body over the limit returns an
+// *http.MaxBytesError.
+func (app *application) csrfFormToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		maxBytes := maxUploadSize(r)
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		err := r.ParseMultipartForm(maxBytes)
+		if err != nil {
+			return "", err
+		}
+		return r.PostFormValue(csrfFormField), nil
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		return "", err
+	}
+	return r.PostForm.Get(csrfFormField), nil
+}
+
 func (app *application) recoverPanic(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
@@ -92,3 +241,58 @@ func (app *application) enabledCORS(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// requirePermission wraps a route so only users whose role grants permission
+// reach it. Services check the same permission again before acting. Users
+// whose role requires two-factor are sent to turn it on first.
+func (app *application) requirePermission(permission entities.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		user := usercontext.ContextGetUser(ctx)
+
+		if user == nil {
+			app.logger.Warn().Ctx(ctx).Msg("Unauthenticated user")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !user.Can(permission) {
+			app.logger.Warn().Ctx(ctx).Str("userID", user.ID.String()).Str("permission", string(permission)).Msg("User lacks permission")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		missing, err := app.services.TwoFactorService.MissingRequired(ctx, user)
+		if err != nil {
+			app.logger.Err(err).Ctx(ctx).Msg("Error checking two-factor requirement")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if missing {
+			app.logger.Warn().Ctx(ctx).Str("userID", user.ID.String()).Msg("User needs two-factor for their role")
+			if r.Method == http.MethodGet {
+				http.Redirect(w, r, "/settings/two-factor", http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) rateLimit(limiter *ratelimit.Limiter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		if !limiter.Allow(clientIP(r)) {
+			app.logger.Warn().Ctx(ctx).Str("path", r.URL.Path).Msg("Rate limit exceeded")
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
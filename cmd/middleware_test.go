@@ -0,0 +1,475 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-playground/form/v4"
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/config"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/ratelimit"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/services"
+	"github.com/mcorrigan89/url_shortener/internal/testdb"
+	"github.com/mcorrigan89/url_shortener/internal/totp"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+	"github.com/rs/zerolog"
+)
+
+const testCSRFToken = "test-csrf-token"
+
+func newTestConfig() *config.Config {
+	cfg := &config.Config{Env: "test"}
+	cfg.Tokens.Secret = "test-secret"
+	cfg.TwoFactor.Issuer = "URL Shortener"
+	cfg.Sessions.IdleTimeout = time.Hour
+	cfg.Sessions.MaxLifetime = 24 * time.Hour
+	return cfg
+}
+
+// newTestApplication returns an application without a database. It can
+// serve requests that are turned away before any service is used.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	logger := zerolog.Nop()
+
+	return &application{
+		config:        newTestConfig(),
+		wg:            &sync.WaitGroup{},
+		logger:        &logger,
+		services:      &services.Services{},
+		formDecoder:   form.NewDecoder(),
+		reportLimiter: ratelimit.New(1000, time.Minute),
+		loginLimiter:  ratelimit.New(1000, time.Minute),
+		authLimiter:   ratelimit.New(1000, time.Minute),
+		resetLimiter:  ratelimit.New(1000, time.Minute),
+		magicLimiter:  ratelimit.New(1000, time.Minute),
+	}
+}
+
+// newTestApplicationWithDB returns an application backed by a migrated test
+// database, skipping the test when none is configured.
+func newTestApplicationWithDB(t *testing.T) (*application, *repositories.Repositories) {
+	t.Helper()
+
+	db := testdb.New(t)
+
+	app := newTestApplication(t)
+	repos := repositories.NewRepositories(db, app.config, app.logger, app.wg)
+	svcs := services.NewServices(&repos, app.config, app.logger, app.wg)
+	app.services = &svcs
+	t.Cleanup(app.wg.Wait)
+
+	return app, &repos
+}
+
+// newTestRequest builds a request carrying user, as contextBuilder would for
+// a signed in session, with a matching CSRF cookie and header.
+func newTestRequest(method, target string, user *entities.User) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: testCSRFToken})
+	r.Header.Set(csrfHeader, testCSRFToken)
+
+	if user != nil {
+		r = r.WithContext(usercontext.ContextSetUser(r.Context(), user))
+	}
+
+	return r
+}
+
+type adminRoute struct {
+	method     string
+	path       string
+	permission entities.Permission
+}
+
+// adminRoutes lists every route registered behind requirePermission.
+var adminRoutes = []adminRoute{
+	{http.MethodGet, "/moderation", entities.PermissionModerateLinks},
+	{http.MethodPost, "/moderation/" + uuid.NewString() + "/approve", entities.PermissionModerateLinks},
+	{http.MethodPost, "/moderation/" + uuid.NewString() + "/reject", entities.PermissionModerateLinks},
+	{http.MethodPost, "/moderation/" + uuid.NewString() + "/block-domain", entities.PermissionModerateLinks},
+	{http.MethodGet, "/admin/blocklist", entities.PermissionManageBlockList},
+	{http.MethodGet, "/admin/blocklist/import", entities.PermissionManageBlockList},
+	{http.MethodPost, "/admin/blocklist/import", entities.PermissionManageBlockList},
+	{http.MethodPost, "/admin/blocklist/users", entities.PermissionManageBlockList},
+	{http.MethodPost, "/admin/blocklist/users/" + uuid.NewString() + "/delete", entities.PermissionManageBlockList},
+	{http.MethodPost, "/admin/blocklist/domains", entities.PermissionManageBlockList},
+	{http.MethodPost, "/admin/blocklist/domains/evil.com/delete", entities.PermissionManageBlockList},
+	{http.MethodGet, "/admin/roles", entities.PermissionManageRoles},
+	{http.MethodPost, "/admin/roles", entities.PermissionManageRoles},
+	{http.MethodPost, "/admin/roles/two-factor", entities.PermissionManageRoles},
+	{http.MethodPost, "/admin/impersonate", entities.PermissionImpersonate},
+	{http.MethodPost, "/admin/links/reassign", entities.PermissionReassignLinks},
+	{http.MethodGet, "/admin/audit", entities.PermissionViewAuditLog},
+	{http.MethodGet, "/admin/audit/export", entities.PermissionViewAuditLog},
+}
+
+func TestAdminRoutesRejectAnonymousUsers(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.routes()
+
+	for _, route := range adminRoutes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newTestRequest(route.method, route.path, nil))
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesRejectUsersWithoutPermission(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.routes()
+
+	for _, role := range entities.Roles {
+		user := &entities.User{ID: uuid.New(), Email: role + "@example.com", Role: role}
+
+		for _, route := range adminRoutes {
+			if user.Can(route.permission) {
+				continue
+			}
+
+			t.Run(role+" "+route.method+" "+route.path, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, newTestRequest(route.method, route.path, user))
+
+				if w.Code != http.StatusForbidden {
+					t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+				}
+			})
+		}
+	}
+}
+
+func TestAdminRoutesRequireTwoFactorForRole(t *testing.T) {
+	app, repos := newTestApplicationWithDB(t)
+	handler := app.routes()
+	ctx := context.Background()
+
+	newAdmin := func(email string) *entities.User {
+		user, err := repos.UserRepository.CreateUserPassword(ctx, repositories.CreateUserPasswordArgs{
+			Email:    email,
+			Password: "correct horse battery staple",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		user, err = repos.UserRepository.UpdateUserRole(ctx, user.ID, entities.RoleAdmin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	withoutTwoFactor := newAdmin("admin-without-2fa@example.com")
+
+	withTwoFactor := newAdmin("admin-with-2fa@example.com")
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repos.UserRepository.StartTwoFactor(ctx, withTwoFactor.ID, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = repos.UserRepository.EnableTwoFactor(ctx, withTwoFactor.ID, totp.Step(time.Now()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repos.UserRepository.SetRolePolicy(ctx, entities.RoleAdmin, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range adminRoutes {
+		t.Run("without two-factor "+route.method+" "+route.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newTestRequest(route.method, route.path, withoutTwoFactor))
+
+			if route.method == http.MethodGet {
+				if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/settings/two-factor" {
+					t.Errorf("status = %d, location = %q, want redirect to /settings/two-factor", w.Code, w.Header().Get("Location"))
+				}
+				return
+			}
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+
+		t.Run("with two-factor "+route.method+" "+route.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, newTestRequest(route.method, route.path, withTwoFactor))
+
+			if w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+				t.Errorf("status = %d, want the request to reach the handler", w.Code)
+			}
+			if w.Header().Get("Location") == "/settings/two-factor" {
+				t.Error("admin with two-factor was sent to set it up")
+			}
+		})
+	}
+}
+
+// newCSRFRequest builds a POST with the given body and content type and no
+// cookies or CSRF header.
+func newCSRFRequest(target, contentType string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, body)
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// multipartBody returns a multipart form with a csrf_token field after a file
+// of fileSize bytes, so the token can only be read by parsing the whole body.
+func multipartBody(t *testing.T, token string, fileSize int) (io.Reader, string) {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	file, err := writer.CreateFormFile("feed", "feed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.Write(bytes.Repeat([]byte("a"), fileSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.WriteField(csrfFormField, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &body, writer.FormDataContentType()
+}
+
+func TestCSRFProtect(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.csrfProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	csrfCookie := &http.Cookie{Name: csrfCookieName, Value: testCSRFToken}
+	sessionCookie := &http.Cookie{Name: sessionCookieName, Value: "session-token"}
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    int
+	}{
+		{
+			name: "form without cookie",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "application/x-www-form-urlencoded", strings.NewReader(csrfFormField+"="+testCSRFToken))
+				r.AddCookie(sessionCookie)
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "form without token",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "application/x-www-form-urlencoded", strings.NewReader("name=value"))
+				r.AddCookie(sessionCookie)
+				r.AddCookie(csrfCookie)
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "form with another token",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "application/x-www-form-urlencoded", strings.NewReader(csrfFormField+"=forged"))
+				r.AddCookie(sessionCookie)
+				r.AddCookie(csrfCookie)
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "header with another token",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "", nil)
+				r.AddCookie(sessionCookie)
+				r.AddCookie(csrfCookie)
+				r.Header.Set(csrfHeader, "forged")
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "form with token",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "application/x-www-form-urlencoded", strings.NewReader(csrfFormField+"="+testCSRFToken))
+				r.AddCookie(sessionCookie)
+				r.AddCookie(csrfCookie)
+				return r
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "header with token",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "", nil)
+				r.AddCookie(sessionCookie)
+				r.AddCookie(csrfCookie)
+				r.Header.Set(csrfHeader, testCSRFToken)
+				return r
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "bearer header with session cookie",
+			request: func() *http.Request {
+				r := newCSRFRequest("/logout", "", nil)
+				r.AddCookie(sessionCookie)
+				r.AddCookie(csrfCookie)
+				r.Header.Set("Authorization", "Bearer anything")
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "json without session",
+			request: func() *http.Request {
+				return newCSRFRequest("/report/abc", "application/json; charset=utf-8", strings.NewReader(`{"reason":"phishing"}`))
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "json with session",
+			request: func() *http.Request {
+				r := newCSRFRequest("/report/abc", "application/json", strings.NewReader(`{"reason":"phishing"}`))
+				r.AddCookie(sessionCookie)
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "json while impersonating",
+			request: func() *http.Request {
+				r := newCSRFRequest("/report/abc", "application/json", strings.NewReader(`{"reason":"phishing"}`))
+				r.AddCookie(&http.Cookie{Name: impersonatorCookieName, Value: "admin-session-token"})
+				return r
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "form without session",
+			request: func() *http.Request {
+				return newCSRFRequest("/login", "application/x-www-form-urlencoded", strings.NewReader("email=someone%40example.com"))
+			},
+			want: http.StatusForbidden,
+		},
+		{
+			name: "small multipart upload",
+			request: func() *http.Request {
+				body, contentType := multipartBody(t, testCSRFToken, 1024)
+				r := newCSRFRequest("/settings/profile", contentType, body)
+				r.AddCookie(csrfCookie)
+				return r
+			},
+			want: http.StatusOK,
+		},
+		{
+			name: "large multipart upload on an ordinary route",
+			request: func() *http.Request {
+				body, contentType := multipartBody(t, testCSRFToken, 2<<20)
+				r := newCSRFRequest("/settings/profile", contentType, body)
+				r.AddCookie(csrfCookie)
+				return r
+			},
+			want: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "large multipart upload to the feed import",
+			request: func() *http.Request {
+				body, contentType := multipartBody(t, testCSRFToken, 2<<20)
+				r := newCSRFRequest("/admin/blocklist/import", contentType, body)
+				r.AddCookie(csrfCookie)
+				return r
+			},
+			want: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tt.request())
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFProtectIssuesCookie(t *testing.T) {
+	app := newTestApplication(t)
+
+	var token string
+	handler := app.csrfProtect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = usercontext.ContextGetCSRFToken(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == "" || cookie.Value != token {
+		t.Errorf("cookie = %v, want one holding the context token %q", cookie, token)
+	}
+}
+
+func TestRoutesRejectCrossSitePosts(t *testing.T) {
+	app := newTestApplication(t)
+	handler := app.routes()
+
+	for _, route := range adminRoutes {
+		if route.method != http.MethodPost {
+			continue
+		}
+
+		t.Run(route.path, func(t *testing.T) {
+			admin := &entities.User{ID: uuid.New(), Email: "admin@example.com", Role: entities.RoleAdmin}
+			r := newTestRequest(route.method, route.path, admin)
+			r.Header.Del(csrfHeader)
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
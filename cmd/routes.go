@@ -3,6 +3,7 @@ package main
 import (
 	"net/http"
 
+	"github.com/mcorrigan89/url_shortener/internal/entities"
 	"github.com/mcorrigan89/url_shortener/public"
 )
 
@@ -22,15 +23,86 @@ func (app *application) routes() http.Handler {
 	mux.HandleFunc("/", app.homePage)
 	mux.HandleFunc("/links", app.linksPage)
 	mux.HandleFunc("/create", app.createLinkPage)
+	mux.HandleFunc("GET /signup", app.signupPage)
+	mux.HandleFunc("GET /login", app.loginPage)
+	mux.HandleFunc("GET /settings/sessions", app.sessionsPage)
+	mux.HandleFunc("GET /settings/accounts", app.accountsPage)
+	mux.HandleFunc("GET /login/link", app.linkAccountPage)
+	mux.HandleFunc("GET /login/email", app.magicLinkPage)
+	mux.HandleFunc("GET /login/email/{token}", app.magicLinkLoginPage)
+	mux.HandleFunc("GET /verify-email/{token}", app.verifyEmail)
+	mux.HandleFunc("GET /forgot-password", app.forgotPasswordPage)
+	mux.HandleFunc("GET /reset-password/{token}", app.resetPasswordPage)
+	mux.HandleFunc("GET /settings/password", app.changePasswordPage)
+	mux.HandleFunc("GET /settings/two-factor", app.twoFactorPage)
+	mux.HandleFunc("GET /settings/two-factor/qr", app.twoFactorQRCode)
+	mux.HandleFunc("GET /login/two-factor", app.twoFactorLoginPage)
+	mux.HandleFunc("GET /moderation", app.requirePermission(entities.PermissionModerateLinks, app.moderationPage))
+	mux.HandleFunc("GET /preview/{slug}", app.previewPage)
+	mux.HandleFunc("GET /workspaces", app.workspacesPage)
+	mux.HandleFunc("GET /workspaces/{id}", app.workspacePage)
+	mux.HandleFunc("GET /invites/{token}", app.invitePage)
+	mux.HandleFunc("GET /transfers", app.transfersPage)
+	mux.HandleFunc("GET /admin/blocklist", app.requirePermission(entities.PermissionManageBlockList, app.blockListPage))
+	mux.HandleFunc("GET /admin/blocklist/import", app.requirePermission(entities.PermissionManageBlockList, app.importBlockListPage))
+	mux.HandleFunc("GET /admin/roles", app.requirePermission(entities.PermissionManageRoles, app.rolesPage))
+	mux.HandleFunc("GET /admin/audit", app.requirePermission(entities.PermissionViewAuditLog, app.auditLogPage))
+	mux.HandleFunc("GET /admin/audit/export", app.requirePermission(entities.PermissionViewAuditLog, app.exportAuditLog))
 
 	// Operations
-	mux.HandleFunc("GET /callback/google", app.loginGoogle)
+	mux.HandleFunc("GET /login/{provider}", app.loginProvider)
+	mux.HandleFunc("GET /callback/{provider}", app.callbackProvider)
+	mux.HandleFunc("GET /settings/accounts/connect/{provider}", app.connectProvider)
+	mux.HandleFunc("POST /settings/accounts/{id}/disconnect", app.disconnectProvider)
+	mux.HandleFunc("POST /login/link", app.confirmLinkAccount)
+	mux.HandleFunc("POST /settings/verify-email", app.rateLimit(app.authLimiter, app.resendVerificationEmail))
+	mux.HandleFunc("POST /login/email", app.rateLimit(app.authLimiter, app.requestMagicLink))
+	mux.HandleFunc("POST /login/email/{token}", app.rateLimit(app.authLimiter, app.magicLinkLogin))
+	mux.HandleFunc("POST /login/two-factor", app.rateLimit(app.authLimiter, app.twoFactorLogin))
+	mux.HandleFunc("POST /settings/two-factor/setup", app.startTwoFactor)
+	mux.HandleFunc("POST /settings/two-factor/confirm", app.rateLimit(app.authLimiter, app.confirmTwoFactor))
+	mux.HandleFunc("POST /settings/two-factor/disable", app.rateLimit(app.authLimiter, app.disableTwoFactor))
+	mux.HandleFunc("POST /settings/two-factor/recovery-codes", app.rateLimit(app.authLimiter, app.replaceRecoveryCodes))
+	mux.HandleFunc("POST /forgot-password", app.rateLimit(app.authLimiter, app.forgotPassword))
+	mux.HandleFunc("POST /reset-password/{token}", app.rateLimit(app.authLimiter, app.resetPassword))
+	mux.HandleFunc("POST /settings/password", app.rateLimit(app.authLimiter, app.changePassword))
+	mux.HandleFunc("POST /signup", app.rateLimit(app.authLimiter, app.signup))
+	mux.HandleFunc("POST /login", app.rateLimit(app.authLimiter, app.login))
+	mux.HandleFunc("POST /logout", app.logout)
+	mux.HandleFunc("POST /settings/sessions/revoke-all", app.signOutEverywhere)
+	mux.HandleFunc("POST /settings/sessions/{id}/revoke", app.revokeSession)
 	mux.HandleFunc("POST /create", app.createLink)
+	mux.HandleFunc("POST /notifications/{id}/read", app.markNotificationRead)
+	mux.HandleFunc("POST /report/{slug}", app.rateLimit(app.reportLimiter, app.reportLink))
+	mux.HandleFunc("POST /moderation/{id}/approve", app.requirePermission(entities.PermissionModerateLinks, app.approveQuarantine))
+	mux.HandleFunc("POST /moderation/{id}/reject", app.requirePermission(entities.PermissionModerateLinks, app.rejectQuarantine))
+	mux.HandleFunc("POST /moderation/{id}/block-domain", app.requirePermission(entities.PermissionModerateLinks, app.blockQuarantinedDomain))
+	mux.HandleFunc("POST /admin/blocklist/import", app.requirePermission(entities.PermissionManageBlockList, app.importBlockList))
+	mux.HandleFunc("POST /admin/blocklist/users", app.requirePermission(entities.PermissionManageBlockList, app.blockUser))
+	mux.HandleFunc("POST /admin/blocklist/users/{id}/delete", app.requirePermission(entities.PermissionManageBlockList, app.unblockUser))
+	mux.HandleFunc("POST /admin/blocklist/domains", app.requirePermission(entities.PermissionManageBlockList, app.blockDomain))
+	mux.HandleFunc("POST /admin/blocklist/domains/{domain}/delete", app.requirePermission(entities.PermissionManageBlockList, app.unblockDomain))
+	mux.HandleFunc("POST /admin/roles", app.requirePermission(entities.PermissionManageRoles, app.setUserRole))
+	mux.HandleFunc("POST /admin/roles/two-factor", app.requirePermission(entities.PermissionManageRoles, app.setRolePolicy))
+	mux.HandleFunc("POST /admin/impersonate", app.requirePermission(entities.PermissionImpersonate, app.startImpersonation))
+	mux.HandleFunc("POST /impersonation/stop", app.stopImpersonation)
+	mux.HandleFunc("POST /workspaces", app.createWorkspace)
+	mux.HandleFunc("POST /workspaces/switch", app.switchWorkspace)
+	mux.HandleFunc("POST /workspaces/{id}/members", app.setWorkspaceMember)
+	mux.HandleFunc("POST /workspaces/{id}/members/{userID}/delete", app.removeWorkspaceMember)
+	mux.HandleFunc("POST /workspaces/{id}/invites", app.inviteWorkspaceMember)
+	mux.HandleFunc("POST /workspaces/{id}/invites/{inviteID}/revoke", app.revokeWorkspaceInvite)
+	mux.HandleFunc("POST /invites/{token}/accept", app.acceptInvite)
+	mux.HandleFunc("POST /transfers", app.requestTransfer)
+	mux.HandleFunc("POST /transfers/{id}/accept", app.acceptTransfer)
+	mux.HandleFunc("POST /transfers/{id}/decline", app.declineTransfer)
+	mux.HandleFunc("POST /transfers/{id}/cancel", app.cancelTransfer)
+	mux.HandleFunc("POST /admin/links/reassign", app.requirePermission(entities.PermissionReassignLinks, app.reassignLinks))
 
 	// Redirects
 	mux.HandleFunc("GET /go/{slug}", app.redirectHandler)
 	mux.HandleFunc("GET /link/{slug}", app.redirectHandler)
 	mux.HandleFunc("GET /qr/{id}", app.qrCodeHandler)
 
-	return app.recoverPanic(app.enabledCORS(app.contextBuilder(mux)))
+	return app.recoverPanic(app.enabledCORS(app.contextBuilder(app.csrfProtect(mux))))
 }
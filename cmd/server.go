@@ -32,6 +32,9 @@ func (app *application) serve() error {
 		TLSConfig:    tlsConfig,
 	}
 
+	backgroundCtx, cancelBackground := context.WithCancel(context.Background())
+	app.services.LinkHealthService.Start(backgroundCtx)
+
 	shutdownError := make(chan error)
 
 	go func() {
@@ -53,6 +56,8 @@ func (app *application) serve() error {
 
 		app.logger.Info().Str("addr", srv.Addr).Msg("completing background tasks")
 
+		cancelBackground()
+
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
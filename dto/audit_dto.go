@@ -0,0 +1,12 @@
+package dto
+
+import "github.com/mcorrigan89/url_shortener/internal/validator"
+
+type AuditFilterForm struct {
+	Action              string `form:"action"`
+	Actor               string `form:"actor"`
+	From                string `form:"from"`
+	To                  string `form:"to"`
+	Limit               int    `form:"limit"`
+	validator.Validator `form:"-"`
+}
@@ -0,0 +1,48 @@
+package dto
+
+import "github.com/mcorrigan89/url_shortener/internal/validator"
+
+type SignupForm struct {
+	GivenName           string `form:"given_name"`
+	FamilyName          string `form:"family_name"`
+	Email               string `form:"email"`
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+type LoginForm struct {
+	Email               string `form:"email"`
+	Password            string `form:"password"`
+	ReturnTo            string `form:"return_to"`
+	Notice              string `form:"-"`
+	validator.Validator `form:"-"`
+}
+
+type ForgotPasswordForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type TwoFactorForm struct {
+	Code                string `form:"code"`
+	ReturnTo            string `form:"return_to"`
+	validator.Validator `form:"-"`
+}
+
+type MagicLinkForm struct {
+	Email               string `form:"email"`
+	validator.Validator `form:"-"`
+}
+
+type ResetPasswordForm struct {
+	Password            string `form:"password"`
+	ConfirmPassword     string `form:"confirm_password"`
+	validator.Validator `form:"-"`
+}
+
+type ChangePasswordForm struct {
+	CurrentPassword     string `form:"current_password"`
+	NewPassword         string `form:"new_password"`
+	ConfirmPassword     string `form:"confirm_password"`
+	validator.Validator `form:"-"`
+}
@@ -0,0 +1,25 @@
+package dto
+
+import "github.com/mcorrigan89/url_shortener/internal/validator"
+
+type ImportBlockListForm struct {
+	Source              string `form:"source"`
+	Format              string `form:"format"`
+	AllowEmpty          bool   `form:"allow_empty"`
+	validator.Validator `form:"-"`
+}
+
+type BlockUserForm struct {
+	Email               string `form:"email"`
+	Reason              string `form:"reason"`
+	ExpiresAt           string `form:"expires_at"`
+	DeactivateLinks     bool   `form:"deactivate_links"`
+	validator.Validator `form:"-"`
+}
+
+type BlockDomainForm struct {
+	Domain              string `form:"domain"`
+	Reason              string `form:"reason"`
+	ExpiresAt           string `form:"expires_at"`
+	validator.Validator `form:"-"`
+}
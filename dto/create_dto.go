@@ -6,3 +6,10 @@ type CreateLinkForm struct {
 	LinkUrl             string `form:"link_url"`
 	validator.Validator `form:"-"`
 }
+
+type TransferLinksForm struct {
+	LinkIDs             []string `form:"link_id"`
+	Email               string   `form:"email"`
+	WorkspaceID         string   `form:"workspace_id"`
+	validator.Validator `form:"-"`
+}
@@ -0,0 +1,10 @@
+package dto
+
+import "github.com/mcorrigan89/url_shortener/internal/validator"
+
+type ReportLinkForm struct {
+	Category            string `form:"category" json:"category"`
+	Comment             string `form:"comment" json:"comment"`
+	Website             string `form:"website" json:"website"`
+	validator.Validator `form:"-" json:"-"`
+}
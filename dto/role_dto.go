@@ -0,0 +1,23 @@
+package dto
+
+import "github.com/mcorrigan89/url_shortener/internal/validator"
+
+type SetRoleForm struct {
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
+
+type RolePolicyForm struct {
+	Role             string `form:"role"`
+	RequireTwoFactor bool   `form:"require_two_factor"`
+}
+
+type ImpersonateForm struct {
+	Email string `form:"email"`
+}
+
+type ReassignLinksForm struct {
+	FromEmail string `form:"from_email"`
+	ToEmail   string `form:"to_email"`
+}
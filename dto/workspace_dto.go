@@ -0,0 +1,18 @@
+package dto
+
+import "github.com/mcorrigan89/url_shortener/internal/validator"
+
+type CreateWorkspaceForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
+type SwitchWorkspaceForm struct {
+	WorkspaceID string `form:"workspace_id"`
+}
+
+type WorkspaceMemberForm struct {
+	Email               string `form:"email"`
+	Role                string `form:"role"`
+	validator.Validator `form:"-"`
+}
@@ -0,0 +1,168 @@
+package blocklist
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	ErrInvalidHost    = errors.New("invalid host")
+	ErrInvalidPattern = errors.New("invalid pattern")
+)
+
+const (
+	RuleRegex      = "regex"
+	RulePathPrefix = "path_prefix"
+)
+
+// hostProfile maps hosts the way browsers look them up, but without the STD3
+// rules, which refuse the underscores real hostnames carry. validHostLabel
+// checks the characters instead.
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.StrictDomainName(false),
+	idna.Transitional(false),
+	idna.BidiRule(),
+)
+
+// validHostLabel is a punycode label of letters, digits, hyphens and
+// underscores.
+var validHostLabel = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// NormalizeHost lowercases host, strips any port and trailing dot, and
+// converts internationalised names to punycode so every spelling of a domain
+// compares equal.
+func NormalizeHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimPrefix(strings.TrimSuffix(host, "]"), "[")
+	host = strings.TrimSuffix(host, ".")
+
+	if host == "" {
+		return "", ErrInvalidHost
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String(), nil
+	}
+
+	ascii, err := hostProfile.ToASCII(host)
+	if err != nil {
+		return "", ErrInvalidHost
+	}
+	ascii = strings.ToLower(ascii)
+
+	for _, label := range strings.Split(ascii, ".") {
+		if !validHostLabel.MatchString(label) {
+			return "", ErrInvalidHost
+		}
+	}
+
+	return ascii, nil
+}
+
+// Candidates returns the block list entries that would match host: the host
+// itself, each parent domain down to the registrable domain, and a "*." wildcard
+// entry for each of those parents. An entry for "evil.com" therefore blocks
+// "cdn.evil.com", while "*.evil.com" blocks subdomains but not the apex.
+func Candidates(host string) ([]string, error) {
+	host, err := NormalizeHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return []string{host}, nil
+	}
+
+	candidates := []string{host}
+
+	current := host
+	for current != registrable {
+		_, parent, found := strings.Cut(current, ".")
+		if !found {
+			break
+		}
+		candidates = append(candidates, parent, "*."+parent)
+		current = parent
+	}
+
+	return candidates, nil
+}
+
+// Rule is a regex or path prefix rule from the block list. Build rules with
+// NewRule so regexes are compiled once rather than on every match.
+type Rule struct {
+	Kind    string
+	Pattern string
+	rx      *regexp.Regexp
+}
+
+// NewRule checks that the rule can be evaluated and prepares it for matching.
+func NewRule(kind, pattern string) (Rule, error) {
+	rule := Rule{Kind: kind, Pattern: pattern}
+
+	switch kind {
+	case RuleRegex:
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return Rule{}, ErrInvalidPattern
+		}
+		rule.rx = rx
+		return rule, nil
+	case RulePathPrefix:
+		if pattern == "" || strings.Contains(pattern, "://") {
+			return Rule{}, ErrInvalidPattern
+		}
+		rule.Pattern = strings.ToLower(pattern)
+		return rule, nil
+	}
+
+	return Rule{}, ErrInvalidPattern
+}
+
+// Matches reports whether u is covered by the rule. Path prefix rules are
+// written as host and path without a scheme, e.g. "sites.google.com/view/scam",
+// and only match whole host and path segments, so "evil.com" doesn't cover
+// "evil.community". Regex rules are matched against the URL with its host
+// normalised.
+func (r Rule) Matches(u *url.URL) bool {
+	host, err := NormalizeHost(u.Host)
+	if err != nil {
+		return false
+	}
+
+	switch r.Kind {
+	case RulePathPrefix:
+		target := strings.ToLower(host + u.EscapedPath())
+		if !strings.HasPrefix(target, r.Pattern) {
+			return false
+		}
+		return len(target) == len(r.Pattern) ||
+			strings.HasSuffix(r.Pattern, "/") ||
+			target[len(r.Pattern)] == '/'
+	case RuleRegex:
+		if r.rx == nil {
+			return false
+		}
+		normalized := *u
+		normalized.Host = host
+		return r.rx.MatchString(normalized.String())
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+package blocklist
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		want    string
+		wantErr error
+	}{
+		{host: "evil.com", want: "evil.com"},
+		{host: "EVIL.com", want: "evil.com"},
+		{host: "evil.com:443", want: "evil.com"},
+		{host: "evil.com.", want: "evil.com"},
+		{host: "  evil.com  ", want: "evil.com"},
+		{host: "bücher.example", want: "xn--bcher-kva.example"},
+		{host: "127.0.0.1:8080", want: "127.0.0.1"},
+		{host: "[::1]:8080", want: "::1"},
+		{host: "[2001:DB8::1]", want: "2001:db8::1"},
+		{host: "a_b.example.com", want: "a_b.example.com"},
+		{host: "_dmarc.Example.com", want: "_dmarc.example.com"},
+		{host: "", wantErr: ErrInvalidHost},
+		{host: ":443", wantErr: ErrInvalidHost},
+		{host: "evil,com", wantErr: ErrInvalidHost},
+		{host: "phish_id,url,phish_detail_url", wantErr: ErrInvalidHost},
+		{host: "evil.com/path", wantErr: ErrInvalidHost},
+		{host: "a..b.com", wantErr: ErrInvalidHost},
+		{host: "*.evil.com", wantErr: ErrInvalidHost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := NormalizeHost(tt.host)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeHost(%q) err = %v, want %v", tt.host, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		host string
+		want []string
+	}{
+		{host: "evil.com", want: []string{"evil.com"}},
+		{host: "CDN.Evil.com:443", want: []string{"cdn.evil.com", "evil.com", "*.evil.com"}},
+		{host: "a.b.evil.co.uk", want: []string{"a.b.evil.co.uk", "b.evil.co.uk", "*.b.evil.co.uk", "evil.co.uk", "*.evil.co.uk"}},
+		{host: "10.0.0.1", want: []string{"10.0.0.1"}},
+		{host: "a_b.evil.com", want: []string{"a_b.evil.com", "evil.com", "*.evil.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			got, err := Candidates(tt.host)
+			if err != nil {
+				t.Fatalf("Candidates(%q) err = %v", tt.host, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Candidates(%q) = %v, want %v", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		pattern string
+		wantErr bool
+	}{
+		{name: "regex", kind: RuleRegex, pattern: `^https://[^/]+/login\.php`},
+		{name: "bad regex", kind: RuleRegex, pattern: `(`, wantErr: true},
+		{name: "path prefix", kind: RulePathPrefix, pattern: "sites.google.com/view/scam"},
+		{name: "empty path prefix", kind: RulePathPrefix, pattern: "", wantErr: true},
+		{name: "path prefix with scheme", kind: RulePathPrefix, pattern: "https://evil.com", wantErr: true},
+		{name: "unknown kind", kind: "glob", pattern: "*", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRule(tt.kind, tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRule(%q, %q) err = %v, want error %t", tt.kind, tt.pattern, err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPattern) {
+				t.Errorf("err = %v, want ErrInvalidPattern", err)
+			}
+		})
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		pattern string
+		url     string
+		want    bool
+	}{
+		{name: "prefix exact host", kind: RulePathPrefix, pattern: "evil.com", url: "https://evil.com", want: true},
+		{name: "prefix host with path", kind: RulePathPrefix, pattern: "evil.com", url: "https://evil.com/login", want: true},
+		{name: "prefix longer host", kind: RulePathPrefix, pattern: "evil.com", url: "https://evil.community/", want: false},
+		{name: "prefix port and case", kind: RulePathPrefix, pattern: "evil.com", url: "https://EVIL.com:8443/x", want: true},
+		{name: "prefix path segment", kind: RulePathPrefix, pattern: "sites.google.com/view/scam", url: "https://sites.google.com/view/scam/page", want: true},
+		{name: "prefix longer path segment", kind: RulePathPrefix, pattern: "sites.google.com/view/scam", url: "https://sites.google.com/view/scammer", want: false},
+		{name: "prefix trailing slash", kind: RulePathPrefix, pattern: "sites.google.com/view/", url: "https://sites.google.com/view/anything", want: true},
+		{name: "prefix other site", kind: RulePathPrefix, pattern: "sites.google.com/view/scam", url: "https://sites.google.com/view/ok", want: false},
+		{name: "regex match", kind: RuleRegex, pattern: `^https://[^/]+/wp-admin/.*\.php$`, url: "https://Blog.Example.com/wp-admin/x.php", want: true},
+		{name: "regex normalises host", kind: RuleRegex, pattern: `^https://blog\.example\.com/`, url: "https://BLOG.example.com/", want: true},
+		{name: "prefix underscore host", kind: RulePathPrefix, pattern: "a_b.example.com/x", url: "https://a_b.example.com/x/y", want: true},
+		{name: "regex no match", kind: RuleRegex, pattern: `/wp-admin/`, url: "https://example.com/", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := NewRule(tt.kind, tt.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := rule.Matches(u)
+			if got != tt.want {
+				t.Errorf("Matches(%q) = %t, want %t", tt.url, got, tt.want)
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package config
 import (
 	"log"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,11 +23,77 @@ type Config struct {
 		TrustedOrigins []string
 	}
 	OAuth struct {
-		Google struct {
+		Providers []OIDCProvider
+		// GitHub is configured when ClientID is set.
+		GitHub struct {
 			ClientID     string
 			ClientSecret string
 		}
 	}
+	HealthCheck struct {
+		Interval           time.Duration
+		Timeout            time.Duration
+		FailureThreshold   int
+		MaxRedirects       int
+		Concurrency        int
+		PerHostConcurrency int
+	}
+	RedirectCheck struct {
+		MaxHops int
+		Timeout time.Duration
+	}
+	Reports struct {
+		QuarantineThreshold int
+		RateLimit           int
+		RateLimitWindow     time.Duration
+	}
+	Login struct {
+		RateLimit       int
+		IPRateLimit     int
+		RateLimitWindow time.Duration
+		// ReturnToOrigins are the origins besides our own that a login may
+		// send the user back to.
+		ReturnToOrigins []string
+	}
+	Mail struct {
+		Host     string
+		Port     int
+		Username string
+		Password string
+		From     string
+	}
+	Tokens struct {
+		Secret string
+	}
+	Invites struct {
+		TTL time.Duration
+	}
+	Sessions struct {
+		IdleTimeout time.Duration
+		MaxLifetime time.Duration
+	}
+	PasswordReset struct {
+		TTL time.Duration
+		// RateLimit caps reset emails per address in each RateLimitWindow.
+		RateLimit       int
+		RateLimitWindow time.Duration
+	}
+	MagicLink struct {
+		TTL time.Duration
+		// RateLimit caps login links per address in each RateLimitWindow.
+		RateLimit       int
+		RateLimitWindow time.Duration
+	}
+	TwoFactor struct {
+		// Issuer names the site in authenticator apps.
+		Issuer string
+	}
+	EmailVerification struct {
+		TTL time.Duration
+		// UnverifiedLinkQuota caps the active links a user can create until
+		// they verify their email.
+		UnverifiedLinkQuota int
+	}
 }
 
 func LoadConfig(cfg *Config) {
@@ -67,16 +135,212 @@ func LoadConfig(cfg *Config) {
 	cfg.Cors.TrustedOrigins = []string{"http://localhost:3000", "https://url.corrigan.io"}
 
 	// Load OAuth
-	google_client_id := os.Getenv("GOOGLE_CLIENT_ID")
-	if google_client_id == "" {
-		log.Fatalf("GOOGLE_CLIENT_ID not available in .env")
+	cfg.OAuth.Providers = loadOIDCProviders()
+	cfg.OAuth.GitHub.ClientID = os.Getenv("GITHUB_CLIENT_ID")
+	cfg.OAuth.GitHub.ClientSecret = os.Getenv("GITHUB_CLIENT_SECRET")
+	if cfg.OAuth.GitHub.ClientID != "" && cfg.OAuth.GitHub.ClientSecret == "" {
+		log.Fatalf("GITHUB_CLIENT_SECRET not available in .env")
+	}
+
+	// Load link health checks
+	cfg.HealthCheck.Interval = getEnvDuration("HEALTH_CHECK_INTERVAL", time.Hour)
+	cfg.HealthCheck.Timeout = getEnvDuration("HEALTH_CHECK_TIMEOUT", 10*time.Second)
+	cfg.HealthCheck.FailureThreshold = getEnvInt("HEALTH_CHECK_FAILURE_THRESHOLD", 3)
+	cfg.HealthCheck.MaxRedirects = getEnvInt("HEALTH_CHECK_MAX_REDIRECTS", 10)
+	cfg.HealthCheck.Concurrency = getEnvInt("HEALTH_CHECK_CONCURRENCY", 8)
+	cfg.HealthCheck.PerHostConcurrency = getEnvInt("HEALTH_CHECK_PER_HOST_CONCURRENCY", 2)
+
+	// Load redirect chain checks for new links
+	cfg.RedirectCheck.MaxHops = getEnvInt("REDIRECT_CHECK_MAX_HOPS", 5)
+	cfg.RedirectCheck.Timeout = getEnvDuration("REDIRECT_CHECK_TIMEOUT", 5*time.Second)
+
+	// Load abuse reports
+	cfg.Reports.QuarantineThreshold = getEnvInt("REPORT_QUARANTINE_THRESHOLD", 3)
+	cfg.Reports.RateLimit = getEnvInt("REPORT_RATE_LIMIT", 10)
+	cfg.Reports.RateLimitWindow = getEnvDuration("REPORT_RATE_LIMIT_WINDOW", time.Hour)
+
+	// Load login throttling. RateLimit applies per email, IPRateLimit per client
+	// across sign ups and logins.
+	cfg.Login.RateLimit = getEnvInt("LOGIN_RATE_LIMIT", 5)
+	cfg.Login.IPRateLimit = getEnvInt("LOGIN_IP_RATE_LIMIT", 30)
+	cfg.Login.RateLimitWindow = getEnvDuration("LOGIN_RATE_LIMIT_WINDOW", 15*time.Minute)
+	cfg.Login.ReturnToOrigins = []string{strings.TrimSuffix(cfg.ClientURL, "/")}
+	for _, origin := range strings.Split(os.Getenv("LOGIN_RETURN_TO_ORIGINS"), ",") {
+		if origin = strings.TrimSuffix(strings.TrimSpace(origin), "/"); origin != "" {
+			cfg.Login.ReturnToOrigins = append(cfg.Login.ReturnToOrigins, origin)
+		}
 	}
 
-	google_client_secret := os.Getenv("GOOGLE_CLIENT_SECRET")
-	if google_client_secret == "" {
-		log.Fatalf("GOOGLE_CLIENT_SECRET not available in .env")
+	// Load mail. SMTP_HOST is only optional in development, where mail is kept
+	// in memory and never sent.
+	cfg.Mail.Host = os.Getenv("SMTP_HOST")
+	if cfg.Mail.Host == "" && !cfg.IsDevelopment() {
+		log.Fatalf("SMTP_HOST not available in .env")
+	}
+	cfg.Mail.Port = getEnvInt("SMTP_PORT", 587)
+	cfg.Mail.Username = os.Getenv("SMTP_USERNAME")
+	cfg.Mail.Password = os.Getenv("SMTP_PASSWORD")
+	cfg.Mail.From = os.Getenv("SMTP_FROM")
+	if cfg.Mail.Host != "" && cfg.Mail.From == "" {
+		log.Fatalf("SMTP_FROM not available in .env")
 	}
 
-	cfg.OAuth.Google.ClientID = google_client_id
-	cfg.OAuth.Google.ClientSecret = google_client_secret
+	// Load signed tokens
+	token_secret := os.Getenv("TOKEN_SECRET")
+	if token_secret == "" {
+		log.Fatalf("TOKEN_SECRET not available in .env")
+	}
+	cfg.Tokens.Secret = token_secret
+
+	// Load workspace invites
+	cfg.Invites.TTL = getEnvDuration("INVITE_TTL", 7*24*time.Hour)
+
+	// Load sessions. Each use pushes expiry out by IdleTimeout, but never past
+	// MaxLifetime from sign in.
+	cfg.Sessions.IdleTimeout = getEnvDuration("SESSION_IDLE_TIMEOUT", 7*24*time.Hour)
+	cfg.Sessions.MaxLifetime = getEnvDuration("SESSION_MAX_LIFETIME", 30*24*time.Hour)
+
+	// Load password resets
+	cfg.PasswordReset.TTL = getEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+	cfg.PasswordReset.RateLimit = getEnvInt("PASSWORD_RESET_RATE_LIMIT", 3)
+	cfg.PasswordReset.RateLimitWindow = getEnvDuration("PASSWORD_RESET_RATE_LIMIT_WINDOW", time.Hour)
+
+	// Load login links
+	cfg.MagicLink.TTL = getEnvDuration("MAGIC_LINK_TTL", 15*time.Minute)
+	cfg.MagicLink.RateLimit = getEnvInt("MAGIC_LINK_RATE_LIMIT", 3)
+	cfg.MagicLink.RateLimitWindow = getEnvDuration("MAGIC_LINK_RATE_LIMIT_WINDOW", time.Hour)
+
+	// Load two-factor
+	cfg.TwoFactor.Issuer = getEnvString("TWO_FACTOR_ISSUER", "URL Shortener")
+
+	// Load email verification
+	cfg.EmailVerification.TTL = getEnvDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour)
+	cfg.EmailVerification.UnverifiedLinkQuota = getEnvInt("UNVERIFIED_LINK_QUOTA", 10)
+}
+
+// IsDevelopment reports whether the app is running locally or under test,
+// where it may do without services production needs, like SMTP.
+func (cfg *Config) IsDevelopment() bool {
+	return slices.Contains([]string{"local", "development", "test"}, cfg.Env)
+}
+
+func getEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("%s must be an integer", key)
+	}
+
+	return parsed
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("%s must be a duration", key)
+	}
+
+	return parsed
+}
+
+type OIDCProvider struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	Claims       OIDCClaims
+}
+
+// OIDCClaims names the claims a user's email, names and picture are read from.
+type OIDCClaims struct {
+	Email         string
+	EmailVerified string
+	GivenName     string
+	FamilyName    string
+	Picture       string
+}
+
+var standardOIDCClaims = OIDCClaims{
+	Email:         "email",
+	EmailVerified: "email_verified",
+	GivenName:     "given_name",
+	FamilyName:    "family_name",
+	Picture:       "picture",
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS, e.g.
+// "google,okta", from OIDC_<NAME>_* variables. GOOGLE_CLIENT_ID and
+// GOOGLE_CLIENT_SECRET still configure Google without an OIDC_ entry.
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+		provider := OIDCProvider{
+			Name:         name,
+			DisplayName:  getEnvString(prefix+"DISPLAY_NAME", strings.ToUpper(name[:1])+name[1:]),
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(getEnvString(prefix+"SCOPES", "openid email profile")),
+			Claims: OIDCClaims{
+				Email:         getEnvString(prefix+"CLAIM_EMAIL", standardOIDCClaims.Email),
+				EmailVerified: getEnvString(prefix+"CLAIM_EMAIL_VERIFIED", standardOIDCClaims.EmailVerified),
+				GivenName:     getEnvString(prefix+"CLAIM_GIVEN_NAME", standardOIDCClaims.GivenName),
+				FamilyName:    getEnvString(prefix+"CLAIM_FAMILY_NAME", standardOIDCClaims.FamilyName),
+				Picture:       getEnvString(prefix+"CLAIM_PICTURE", standardOIDCClaims.Picture),
+			},
+		}
+
+		if provider.Issuer == "" || provider.ClientID == "" || provider.ClientSecret == "" {
+			log.Fatalf("%sISSUER, %sCLIENT_ID and %sCLIENT_SECRET are required", prefix, prefix, prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	googleClientID := os.Getenv("GOOGLE_CLIENT_ID")
+	if googleClientID != "" && !slices.ContainsFunc(providers, func(p OIDCProvider) bool { return p.Name == "google" }) {
+		googleClientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
+		if googleClientSecret == "" {
+			log.Fatalf("GOOGLE_CLIENT_SECRET not available in .env")
+		}
+
+		providers = append(providers, OIDCProvider{
+			Name:         "google",
+			DisplayName:  "Google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     googleClientID,
+			ClientSecret: googleClientSecret,
+			Scopes:       []string{"openid", "email", "profile"},
+			Claims:       standardOIDCClaims,
+		})
+	}
+
+	return providers
+}
+
+func getEnvString(key string, fallback string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	return value
 }
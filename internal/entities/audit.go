@@ -0,0 +1,82 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	AuditLogin                  = "auth.login"
+	AuditLogout                 = "auth.logout"
+	AuditSessionCreated         = "session.created"
+	AuditSessionExpired         = "session.expired"
+	AuditImpersonationStarted   = "impersonation.started"
+	AuditImpersonationStopped   = "impersonation.stopped"
+	AuditLinkCreated            = "link.created"
+	AuditLinkUpdated            = "link.updated"
+	AuditLinkDeactivated        = "link.deactivated"
+	AuditLinkTransferred        = "link.transferred"
+	AuditLinksReassigned        = "link.reassigned"
+	AuditUserBlocked            = "block_list.user_blocked"
+	AuditUserUnblocked          = "block_list.user_unblocked"
+	AuditDomainBlocked          = "block_list.domain_blocked"
+	AuditDomainUnblocked        = "block_list.domain_unblocked"
+	AuditFeedImported           = "block_list.feed_imported"
+	AuditRoleChanged            = "role.changed"
+	AuditIdentityLinked         = "identity.linked"
+	AuditIdentityUnlinked       = "identity.unlinked"
+	AuditEmailVerified          = "user.email_verified"
+	AuditAccountClaimed         = "user.account_claimed"
+	AuditPasswordChanged        = "password.changed"
+	AuditPasswordResetRequested = "password.reset_requested"
+	AuditPasswordReset          = "password.reset"
+	AuditTwoFactorEnabled       = "two_factor.enabled"
+	AuditTwoFactorDisabled      = "two_factor.disabled"
+	AuditTwoFactorVerified      = "two_factor.verified"
+	AuditRecoveryCodeUsed       = "two_factor.recovery_code_used"
+	AuditRecoveryCodesReplaced  = "two_factor.recovery_codes_replaced"
+	AuditRolePolicyChanged      = "role.policy_changed"
+)
+
+var AuditActions = []string{
+	AuditLogin, AuditLogout, AuditSessionCreated, AuditSessionExpired,
+	AuditImpersonationStarted, AuditImpersonationStopped,
+	AuditLinkCreated, AuditLinkUpdated, AuditLinkDeactivated, AuditLinkTransferred, AuditLinksReassigned,
+	AuditUserBlocked, AuditUserUnblocked, AuditDomainBlocked, AuditDomainUnblocked, AuditFeedImported,
+	AuditRoleChanged, AuditIdentityLinked, AuditIdentityUnlinked, AuditEmailVerified, AuditAccountClaimed,
+	AuditPasswordChanged, AuditPasswordResetRequested, AuditPasswordReset,
+	AuditTwoFactorEnabled, AuditTwoFactorDisabled, AuditTwoFactorVerified, AuditRecoveryCodeUsed,
+	AuditRecoveryCodesReplaced, AuditRolePolicyChanged,
+}
+
+var (
+	AuditTargetUser    = "user"
+	AuditTargetSession = "session"
+	AuditTargetLink    = "link"
+	AuditTargetDomain  = "domain"
+	AuditTargetFeed    = "feed"
+	AuditTargetRole    = "role"
+)
+
+type AuditEvent struct {
+	ID             uuid.UUID      `json:"id"`
+	Action         string         `json:"action"`
+	ActorID        *uuid.UUID     `json:"actor_id"`
+	ImpersonatorID *uuid.UUID     `json:"impersonator_id"`
+	TargetType     *string        `json:"target_type"`
+	TargetID       *string        `json:"target_id"`
+	IPAddress      *string        `json:"ip_address"`
+	UserAgent      *string        `json:"user_agent"`
+	CorrelationID  *string        `json:"correlation_id"`
+	Metadata       map[string]any `json:"metadata"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+type AuditFilter struct {
+	Action        *string
+	ActorID       *uuid.UUID
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+}
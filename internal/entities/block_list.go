@@ -0,0 +1,59 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrReservedSource = errors.New("block list source is reserved")
+	ErrNotBlocked     = errors.New("not on the block list")
+)
+
+// BlockListSourceManual tags domains added by hand or by moderators. Feed
+// imports never touch entries from another source.
+var BlockListSourceManual = "manual"
+
+type BlockListImport struct {
+	Source      string
+	Added       int
+	Updated     int
+	Removed     int
+	Skipped     int
+	Quarantined int
+}
+
+type BlockedUser struct {
+	UserID    uuid.UUID
+	User      *User
+	Reason    *string
+	ExpiresAt *time.Time
+	CreatedBy *uuid.UUID
+	CreatedAt time.Time
+}
+
+type BlockedDomain struct {
+	Domain    string
+	Source    string
+	Reason    *string
+	ExpiresAt *time.Time
+	CreatedBy *uuid.UUID
+	FirstSeen *time.Time
+	LastSeen  *time.Time
+	CreatedAt time.Time
+}
+
+type BlockedDomainSource struct {
+	Source  string
+	Domains int
+}
+
+// BlockList is what the admin console shows: every blocked user, the domains
+// added by hand, and a count for each imported feed.
+type BlockList struct {
+	Users   []*BlockedUser
+	Domains []*BlockedDomain
+	Sources []*BlockedDomainSource
+}
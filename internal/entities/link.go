@@ -8,6 +8,9 @@ type LinkEntity struct {
 	ShortenedURLSlug string
 	LinkURL          string
 	CreatedBy        uuid.UUID
+	OwnerID          uuid.UUID
+	WorkspaceID      *uuid.UUID
 	Active           bool
 	Quarantined      bool
+	Broken           bool
 }
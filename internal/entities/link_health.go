@@ -0,0 +1,19 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LinkHealthCheck struct {
+	ID            uuid.UUID
+	LinkID        uuid.UUID
+	StatusCode    *int
+	Latency       time.Duration
+	RedirectChain []string
+	TLSValid      bool
+	Healthy       bool
+	Error         *string
+	CheckedAt     time.Time
+}
@@ -0,0 +1,24 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	NotificationLinkBroken      = "link_broken"
+	NotificationLinkQuarantined = "link_quarantined"
+	NotificationLinkRestored    = "link_restored"
+	NotificationLinkRejected    = "link_rejected"
+)
+
+type Notification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	LinkID    *uuid.UUID
+	Kind      string
+	Message   string
+	Read      bool
+	CreatedAt time.Time
+}
@@ -0,0 +1,42 @@
+package entities
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	QuarantineSourceRedirectCheck = "redirect_check"
+	QuarantineSourceReport        = "report"
+	QuarantineSourceBlockList     = "block_list"
+	QuarantineSourceModerator     = "moderator"
+)
+
+var (
+	QuarantineStatusPending  = "pending"
+	QuarantineStatusApproved = "approved"
+	QuarantineStatusRejected = "rejected"
+)
+
+type LinkQuarantine struct {
+	ID         uuid.UUID
+	LinkID     uuid.UUID
+	Source     string
+	Reason     string
+	ReporterID *uuid.UUID
+	Status     string
+	DecidedBy  *uuid.UUID
+	DecidedAt  *time.Time
+	CreatedAt  time.Time
+}
+
+// QuarantineReview is a pending quarantine with everything a moderator needs
+// to decide on it.
+type QuarantineReview struct {
+	Quarantine  *LinkQuarantine
+	Link        *LinkEntity
+	Owner       *User
+	Reporter    *User
+	LatestCheck *LinkHealthCheck
+}
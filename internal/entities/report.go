@@ -0,0 +1,31 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrDuplicateReport = errors.New("link already reported from this address")
+)
+
+var (
+	ReportCategoryPhishing = "phishing"
+	ReportCategoryMalware  = "malware"
+	ReportCategorySpam     = "spam"
+	ReportCategoryOther    = "other"
+)
+
+var ReportCategories = []string{ReportCategoryPhishing, ReportCategoryMalware, ReportCategorySpam, ReportCategoryOther}
+
+type LinkReport struct {
+	ID         uuid.UUID
+	LinkID     uuid.UUID
+	ReporterID *uuid.UUID
+	IPAddress  string
+	Category   string
+	Comment    *string
+	CreatedAt  time.Time
+}
@@ -0,0 +1,45 @@
+package entities
+
+import (
+	"errors"
+	"slices"
+)
+
+var (
+	ErrForbidden   = errors.New("forbidden")
+	ErrInvalidRole = errors.New("invalid role")
+)
+
+var (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+type Permission string
+
+var (
+	PermissionModerateLinks   Permission = "moderate_links"
+	PermissionManageBlockList Permission = "manage_block_list"
+	PermissionManageRoles     Permission = "manage_roles"
+	PermissionImpersonate     Permission = "impersonate"
+	PermissionReassignLinks   Permission = "reassign_links"
+	PermissionViewAuditLog    Permission = "view_audit_log"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleUser:      {},
+	RoleModerator: {PermissionModerateLinks},
+	RoleAdmin:     {PermissionModerateLinks, PermissionManageBlockList, PermissionManageRoles, PermissionImpersonate, PermissionReassignLinks, PermissionViewAuditLog},
+}
+
+// Can reports whether the user's role grants permission. Unknown roles grant
+// nothing.
+func (u *User) Can(permission Permission) bool {
+	if u == nil {
+		return false
+	}
+	return slices.Contains(rolePermissions[u.Role], permission)
+}
@@ -1,34 +1,62 @@
 package entities
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+var ErrSessionNotFound = errors.New("session not found")
+
 type UserSession struct {
-	ID            uuid.UUID
-	UserID        uuid.UUID
-	Token         string
-	ExpiresAt     time.Time
-	ExpiredByUser bool
+	ID             uuid.UUID
+	UserID         uuid.UUID
+	ImpersonatorID *uuid.UUID
+	// Token is only set on a session that was just created. The database keeps
+	// nothing but its hash.
+	Token string
+	// ExpiresAt slides forward as the session is used, up to AbsoluteExpiresAt.
+	ExpiresAt         time.Time
+	AbsoluteExpiresAt time.Time
+	ExpiredByUser     bool
+	UserAgent         *string
+	IPAddress         *string
+	CreatedAt         time.Time
+	LastSeenAt        time.Time
+	// TwoFactorPending sessions only let the user enter their second factor.
+	TwoFactorPending bool
 }
 
 type NewUserSessionArgs struct {
-	ID            uuid.UUID
-	UserID        uuid.UUID
-	Token         string
-	ExpiresAt     time.Time
-	ExpiredByUser bool
+	ID                uuid.UUID
+	UserID            uuid.UUID
+	ImpersonatorID    *uuid.UUID
+	Token             string
+	ExpiresAt         time.Time
+	AbsoluteExpiresAt time.Time
+	ExpiredByUser     bool
+	UserAgent         *string
+	IPAddress         *string
+	CreatedAt         time.Time
+	LastSeenAt        time.Time
+	TwoFactorPending  bool
 }
 
 func NewUserSession(args NewUserSessionArgs) *UserSession {
 	return &UserSession{
-		ID:            args.ID,
-		UserID:        args.UserID,
-		Token:         args.Token,
-		ExpiresAt:     args.ExpiresAt,
-		ExpiredByUser: args.ExpiredByUser,
+		ID:                args.ID,
+		UserID:            args.UserID,
+		ImpersonatorID:    args.ImpersonatorID,
+		Token:             args.Token,
+		ExpiresAt:         args.ExpiresAt,
+		AbsoluteExpiresAt: args.AbsoluteExpiresAt,
+		ExpiredByUser:     args.ExpiredByUser,
+		UserAgent:         args.UserAgent,
+		IPAddress:         args.IPAddress,
+		CreatedAt:         args.CreatedAt,
+		LastSeenAt:        args.LastSeenAt,
+		TwoFactorPending:  args.TwoFactorPending,
 	}
 }
 
@@ -36,5 +64,12 @@ func (t *UserSession) IsExpired() bool {
 	if t.ExpiredByUser {
 		return true
 	}
-	return t.ExpiresAt.Before(time.Now())
+	now := time.Now()
+	return t.ExpiresAt.Before(now) || t.AbsoluteExpiresAt.Before(now)
+}
+
+// IsImpersonation reports whether an admin is using this session to act as
+// another user.
+func (t *UserSession) IsImpersonation() bool {
+	return t.ImpersonatorID != nil
 }
@@ -0,0 +1,44 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTransferPending     = errors.New("link already has a pending transfer")
+	ErrTransferUnavailable = errors.New("transfer has already been decided")
+	ErrTransferToOwner     = errors.New("link already belongs to the recipient")
+)
+
+var (
+	TransferStatusPending   = "pending"
+	TransferStatusAccepted  = "accepted"
+	TransferStatusDeclined  = "declined"
+	TransferStatusCancelled = "cancelled"
+)
+
+var NotificationLinkTransfer = "link_transfer"
+
+// LinkTransfer is an offer to hand a link to another user or into a
+// workspace. Exactly one of ToUserID and ToWorkspaceID is set.
+type LinkTransfer struct {
+	ID            uuid.UUID
+	LinkID        uuid.UUID
+	Link          *LinkEntity
+	FromUserID    uuid.UUID
+	FromUser      *User
+	ToUserID      *uuid.UUID
+	ToUser        *User
+	ToWorkspaceID *uuid.UUID
+	ToWorkspace   *Workspace
+	Status        string
+	CreatedAt     time.Time
+}
+
+type LinkTransfers struct {
+	Incoming []*LinkTransfer
+	Outgoing []*LinkTransfer
+}
@@ -0,0 +1,67 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotStarted     = errors.New("two-factor enrolment has not been started")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is required for this role")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TwoFactor is a user's TOTP enrolment. It only protects logins once Enabled.
+type TwoFactor struct {
+	UserID    uuid.UUID
+	Enabled   bool
+	EnabledAt *time.Time
+	// RecoveryCodesLeft counts the unused recovery codes.
+	RecoveryCodesLeft int
+	secret            string
+	lastUsedStep      int64
+}
+
+func NewTwoFactorEntityFromModel(model models.UserTwoFactor, recoveryCodesLeft int) *TwoFactor {
+	twoFactor := &TwoFactor{
+		UserID:            model.UserID,
+		Enabled:           model.EnabledAt.Valid,
+		RecoveryCodesLeft: recoveryCodesLeft,
+		secret:            model.Secret,
+		lastUsedStep:      model.LastUsedStep,
+	}
+	if model.EnabledAt.Valid {
+		twoFactor.EnabledAt = &model.EnabledAt.Time
+	}
+	return twoFactor
+}
+
+// Secret is the shared TOTP secret.
+func (t *TwoFactor) Secret() string {
+	return t.secret
+}
+
+// LastUsedStep is the time step of the last code accepted.
+func (t *TwoFactor) LastUsedStep() int64 {
+	return t.lastUsedStep
+}
+
+// RolePolicy holds the security rules for users with a role.
+type RolePolicy struct {
+	Role             string
+	RequireTwoFactor bool
+	UpdatedAt        time.Time
+}
+
+func NewRolePolicyEntityFromModel(model models.RolePolicy) *RolePolicy {
+	return &RolePolicy{
+		Role:             model.Role,
+		RequireTwoFactor: model.RequireTwoFactor,
+		UpdatedAt:        model.UpdatedAt.Time,
+	}
+}
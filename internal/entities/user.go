@@ -2,6 +2,7 @@ package entities
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
@@ -10,27 +11,61 @@ import (
 )
 
 var (
-	ErrUserNotFound       = errors.New("user not found")
-	ErrNoPasswordSet      = errors.New("no password set")
-	ErrIncorrectProvider  = errors.New("incorrect provider")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrDuplicateEmail     = errors.New("duplicate email")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrNoPasswordSet         = errors.New("no password set")
+	ErrIncorrectProvider     = errors.New("incorrect provider")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrDuplicateEmail        = errors.New("duplicate email")
+	ErrIdentityNotFound      = errors.New("identity not found")
+	ErrIdentityInUse         = errors.New("identity is linked to another user")
+	ErrProviderAlreadyLinked = errors.New("provider is already linked")
+	ErrLastIdentity          = errors.New("cannot remove the only way to log in")
+	ErrPasswordResetInvalid  = errors.New("password reset is invalid or expired")
+	ErrLoginLinkInvalid      = errors.New("login link is invalid or expired")
 )
 
 var (
 	ProviderPassword = "password"
+	// ProviderEmail marks users who signed up with an emailed login link.
+	ProviderEmail = "email"
 )
 
-type UserAuth struct {
-	Value    string
-	Provider string
+// dummyPasswordHash is checked when there is no real hash to check, so that
+// logins for unknown emails take as long as wrong passwords. Its cost matches
+// the cost stored hashes are made with.
+const dummyPasswordHash = "$2a$12$ygUzlgIoYrU4n21T1LG95ed7kBYnB4ZdoQ6mI7HILBvD9Bk9cNGFi"
+
+// CompareDummyPassword takes as long as checking a real password and never
+// matches.
+func CompareDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+}
+
+// UserIdentity is one way a user logs in: their password, or an account with
+// a login provider.
+type UserIdentity struct {
+	ID         uuid.UUID
+	Provider   string
+	ProviderID string
+	CreatedAt  time.Time
+	value      string
+}
+
+func NewUserIdentityFromModel(model models.UserAuth) *UserIdentity {
+	return &UserIdentity{
+		ID:         model.ID,
+		Provider:   model.Provider,
+		ProviderID: model.ProviderID,
+		CreatedAt:  model.CreatedAt.Time,
+		value:      model.Value,
+	}
 }
 
-func (ua *UserAuth) CompareHashAndPassword(password string) error {
-	if ua.Provider != "password" {
+func (ui *UserIdentity) CompareHashAndPassword(password string) error {
+	if ui.Provider != ProviderPassword {
 		return ErrIncorrectProvider
 	}
-	err := bcrypt.CompareHashAndPassword([]byte(ua.Value), []byte(password))
+	err := bcrypt.CompareHashAndPassword([]byte(ui.value), []byte(password))
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			return ErrInvalidCredentials
@@ -43,39 +78,51 @@ func (ua *UserAuth) CompareHashAndPassword(password string) error {
 }
 
 type User struct {
-	ID         uuid.UUID
-	GivenName  *string
-	FamilyName *string
-	Email      string
-	AvatarUrl  *string
-	userAuth   *UserAuth
-}
-
-type NewUserEntityArgs struct {
-	ID         uuid.UUID
-	GivenName  *string
-	FamilyName *string
-	Email      string
-	AvatarUrl  *string
-	UserAuth   *UserAuth
+	ID            uuid.UUID
+	GivenName     *string
+	FamilyName    *string
+	Email         string
+	EmailVerified bool
+	AvatarUrl     *string
+	Role          string
+	// Identities are the user's ways to log in, oldest first. They are only
+	// loaded when the user is looked up on their own.
+	Identities []*UserIdentity
 }
 
-func NewUserEntityFromModel(userModel models.User, userAuthModel models.UserAuth) *User {
+func NewUserEntityFromModel(userModel models.User, userAuthModels []models.UserAuth) *User {
 	entity := &User{
-		ID:         userModel.ID,
-		GivenName:  userModel.GivenName,
-		FamilyName: userModel.FamilyName,
-		Email:      userModel.Email,
-		AvatarUrl:  userModel.AvatarUrl,
-		userAuth: &UserAuth{
-			Value:    userAuthModel.Value,
-			Provider: userAuthModel.Provider,
-		},
+		ID:            userModel.ID,
+		GivenName:     userModel.GivenName,
+		FamilyName:    userModel.FamilyName,
+		Email:         userModel.Email,
+		EmailVerified: userModel.EmailVerified != nil && *userModel.EmailVerified,
+		AvatarUrl:     userModel.AvatarUrl,
+		Role:          userModel.Role,
+	}
+
+	for _, userAuthModel := range userAuthModels {
+		entity.Identities = append(entity.Identities, NewUserIdentityFromModel(userAuthModel))
 	}
 
 	return entity
 }
 
+// Identity returns the user's identity with provider, or nil.
+func (u *User) Identity(provider string) *UserIdentity {
+	for _, identity := range u.Identities {
+		if identity.Provider == provider {
+			return identity
+		}
+	}
+	return nil
+}
+
 func (u *User) ComparePassword(password string) error {
-	return u.userAuth.CompareHashAndPassword(password)
+	identity := u.Identity(ProviderPassword)
+	if identity == nil {
+		CompareDummyPassword(password)
+		return ErrIncorrectProvider
+	}
+	return identity.CompareHashAndPassword(password)
 }
@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDummyPasswordHashMatchesStoredCost(t *testing.T) {
+	cost, err := bcrypt.Cost([]byte(dummyPasswordHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stored hashes are made with repositories.bcryptCost.
+	if cost != 12 {
+		t.Errorf("dummy hash cost = %d, want 12", cost)
+	}
+}
+
+func TestComparePasswordWithoutPassword(t *testing.T) {
+	user := &User{Identities: []*UserIdentity{{Provider: ProviderEmail}}}
+
+	err := user.ComparePassword("dummy password for unknown users")
+	if !errors.Is(err, ErrIncorrectProvider) {
+		t.Fatalf("ComparePassword err = %v, want ErrIncorrectProvider", err)
+	}
+}
@@ -0,0 +1,68 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrLastWorkspaceOwner  = errors.New("workspace must keep an owner")
+	ErrInviteUnavailable   = errors.New("invite has expired, been revoked or already been accepted")
+	ErrInviteEmailMismatch = errors.New("invite was sent to a different email")
+)
+
+var (
+	WorkspaceRoleOwner  = "owner"
+	WorkspaceRoleEditor = "editor"
+	WorkspaceRoleViewer = "viewer"
+)
+
+var WorkspaceRoles = []string{WorkspaceRoleOwner, WorkspaceRoleEditor, WorkspaceRoleViewer}
+
+type Workspace struct {
+	ID        uuid.UUID
+	Name      string
+	CreatedBy *uuid.UUID
+	CreatedAt time.Time
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID
+	Workspace   *Workspace
+	UserID      uuid.UUID
+	User        *User
+	Role        string
+	CreatedAt   time.Time
+}
+
+// CanEditLinks reports whether the member may create and change the
+// workspace's links.
+func (m *WorkspaceMember) CanEditLinks() bool {
+	return m.Role == WorkspaceRoleOwner || m.Role == WorkspaceRoleEditor
+}
+
+// CanManageMembers reports whether the member may invite, remove and change
+// the roles of other members.
+func (m *WorkspaceMember) CanManageMembers() bool {
+	return m.Role == WorkspaceRoleOwner
+}
+
+type WorkspaceInvite struct {
+	ID          uuid.UUID
+	WorkspaceID uuid.UUID
+	Workspace   *Workspace
+	Email       string
+	Role        string
+	InvitedBy   *uuid.UUID
+	ExpiresAt   time.Time
+	AcceptedAt  *time.Time
+	RevokedAt   *time.Time
+	CreatedAt   time.Time
+}
+
+// IsPending reports whether the invite can still be accepted.
+func (i *WorkspaceInvite) IsPending() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,234 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mcorrigan89/url_shortener/internal/oidc"
+)
+
+// Name identifies GitHub in routes and on linked accounts.
+const Name = "github"
+
+var ErrExchange = errors.New("github code exchange failed")
+
+// maxResponseSize bounds what is read from GitHub.
+const maxResponseSize = 1 << 20
+
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// BaseURL and APIURL default to github.com and its REST API. They can
+	// point at GitHub Enterprise or a local stub instead.
+	BaseURL string
+	APIURL  string
+}
+
+// Provider logs users in with GitHub's OAuth apps. GitHub doesn't speak
+// OpenID Connect, so the identity comes from the REST API rather than an ID
+// token.
+type Provider struct {
+	config Config
+	client oidc.HTTPClient
+}
+
+func NewProvider(config Config, client oidc.HTTPClient) *Provider {
+	if config.BaseURL == "" {
+		config.BaseURL = "https://github.com"
+	}
+	if config.APIURL == "" {
+		config.APIURL = "https://api.github.com"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	config.APIURL = strings.TrimSuffix(config.APIURL, "/")
+
+	return &Provider{
+		config: config,
+		client: client,
+	}
+}
+
+func (p *Provider) Name() string {
+	return Name
+}
+
+// AuthCodeURL returns GitHub's authorize endpoint. GitHub has no nonce, so
+// req.Nonce is not sent.
+func (p *Provider) AuthCodeURL(ctx context.Context, req oidc.AuthRequest) (string, error) {
+	params := url.Values{
+		"client_id":    {p.config.ClientID},
+		"redirect_uri": {p.config.RedirectURL},
+		"scope":        {"read:user user:email"},
+		"allow_signup": {"true"},
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if req.CodeVerifier != "" {
+		params.Set("code_challenge", oidc.CodeChallenge(req.CodeVerifier))
+		params.Set("code_challenge_method", "S256")
+	}
+
+	return p.config.BaseURL + "/login/oauth/authorize?" + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for an access token. The token has
+// no ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*oidc.Token, error) {
+	form := url.Values{
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+	}
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.BaseURL+"/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", ErrExchange, resp.StatusCode)
+	}
+
+	// GitHub reports a bad code with a 200 and an error field.
+	if resp.StatusCode != http.StatusOK || result.Error != "" || result.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %d %s %s", ErrExchange, resp.StatusCode, result.Error, result.ErrorDescription)
+	}
+
+	return &oidc.Token{
+		AccessToken: result.AccessToken,
+		TokenType:   result.TokenType,
+	}, nil
+}
+
+type user struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type email struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// Identity reads the user's profile and their verified primary email. The
+// public profile email is not used because the user picks it and GitHub
+// doesn't say whether it's verified. nonce is ignored.
+func (p *Provider) Identity(ctx context.Context, token *oidc.Token, nonce string) (*oidc.Identity, error) {
+	var profile user
+	err := p.getJSON(ctx, "/user", token.AccessToken, &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if profile.ID == 0 {
+		return nil, oidc.ErrNoSubject
+	}
+
+	var emails []email
+	err = p.getJSON(ctx, "/user/emails", token.AccessToken, &emails)
+	if err != nil {
+		return nil, err
+	}
+
+	var primary string
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			primary = e.Email
+			break
+		}
+	}
+	if primary == "" {
+		return nil, oidc.ErrNoEmail
+	}
+
+	givenName, familyName := splitName(profile.Name)
+
+	identity := &oidc.Identity{
+		Subject:       strconv.FormatInt(profile.ID, 10),
+		Email:         primary,
+		EmailVerified: true,
+		GivenName:     givenName,
+		FamilyName:    familyName,
+		Claims: map[string]any{
+			"id":         profile.ID,
+			"login":      profile.Login,
+			"name":       profile.Name,
+			"avatar_url": profile.AvatarURL,
+			"email":      primary,
+		},
+	}
+	if profile.AvatarURL != "" {
+		identity.Picture = &profile.AvatarURL
+	}
+
+	return identity, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, path, accessToken string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.APIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out)
+}
+
+// splitName splits GitHub's single name field at the first space.
+func splitName(name string) (*string, *string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+
+	given, family, found := strings.Cut(name, " ")
+	if !found {
+		return &given, nil
+	}
+
+	family = strings.TrimSpace(family)
+	return &given, &family
+}
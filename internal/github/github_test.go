@@ -0,0 +1,162 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mcorrigan89/url_shortener/internal/oidc"
+)
+
+// newTestProvider points a provider at a stub GitHub serving mux for both the
+// web and API hosts.
+func newTestProvider(t *testing.T, mux *http.ServeMux) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return NewProvider(Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:8080/auth/github/callback",
+		BaseURL:      server.URL,
+		APIURL:       server.URL + "/api",
+	}, server.Client())
+}
+
+func TestExchange(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		wantToken string
+		wantErr   error
+	}{
+		{name: "access token", status: http.StatusOK, body: `{"access_token":"gho_token","token_type":"bearer"}`, wantToken: "gho_token"},
+		{name: "bad code", status: http.StatusOK, body: `{"error":"bad_verification_code","error_description":"The code passed is incorrect or expired."}`, wantErr: ErrExchange},
+		{name: "no access token", status: http.StatusOK, body: `{"token_type":"bearer"}`, wantErr: ErrExchange},
+		{name: "server error", status: http.StatusInternalServerError, body: `{}`, wantErr: ErrExchange},
+		{name: "not json", status: http.StatusBadGateway, body: `<html>bad gateway</html>`, wantErr: ErrExchange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+				if r.PostFormValue("code") != "the-code" || r.PostFormValue("code_verifier") != "the-verifier" || r.PostFormValue("client_id") != "client-id" {
+					t.Errorf("unexpected exchange form %v", r.PostForm)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			p := newTestProvider(t, mux)
+
+			token, err := p.Exchange(context.Background(), "the-code", "the-verifier")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Exchange err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && token.AccessToken != tt.wantToken {
+				t.Errorf("AccessToken = %q, want %q", token.AccessToken, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	profile := user{ID: 42, Login: "octocat", Name: "Mona Lisa Octocat", AvatarURL: "https://avatars.example.com/42"}
+
+	tests := []struct {
+		name      string
+		profile   user
+		emails    []email
+		wantEmail string
+		wantErr   error
+	}{
+		{
+			name:    "verified primary",
+			profile: profile,
+			emails: []email{
+				{Email: "secondary@example.com", Verified: true},
+				{Email: "octocat@example.com", Primary: true, Verified: true},
+			},
+			wantEmail: "octocat@example.com",
+		},
+		{
+			name:    "unverified primary",
+			profile: profile,
+			emails:  []email{{Email: "octocat@example.com", Primary: true}},
+			wantErr: oidc.ErrNoEmail,
+		},
+		{
+			name:    "only a verified secondary",
+			profile: profile,
+			emails: []email{
+				{Email: "octocat@example.com", Primary: true},
+				{Email: "secondary@example.com", Verified: true},
+			},
+			wantErr: oidc.ErrNoEmail,
+		},
+		{
+			name:    "no emails",
+			profile: profile,
+			wantErr: oidc.ErrNoEmail,
+		},
+		{
+			name:    "no user id",
+			emails:  []email{{Email: "octocat@example.com", Primary: true, Verified: true}},
+			wantErr: oidc.ErrNoSubject,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /api/user", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer gho_token" {
+					http.Error(w, "unauthorized", http.StatusUnauthorized)
+					return
+				}
+				json.NewEncoder(w).Encode(tt.profile)
+			})
+			mux.HandleFunc("GET /api/user/emails", func(w http.ResponseWriter, r *http.Request) {
+				emails := tt.emails
+				if emails == nil {
+					emails = []email{}
+				}
+				json.NewEncoder(w).Encode(emails)
+			})
+			p := newTestProvider(t, mux)
+
+			identity, err := p.Identity(context.Background(), &oidc.Token{AccessToken: "gho_token"}, "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Identity err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if identity.Email != tt.wantEmail || !identity.EmailVerified {
+				t.Errorf("Email = %q verified %t, want %q verified", identity.Email, identity.EmailVerified, tt.wantEmail)
+			}
+			if identity.Subject != "42" {
+				t.Errorf("Subject = %q, want 42", identity.Subject)
+			}
+		})
+	}
+}
+
+func TestIdentityRejectedToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/user", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+	})
+	p := newTestProvider(t, mux)
+
+	_, err := p.Identity(context.Background(), &oidc.Token{AccessToken: "revoked"}, "")
+	if err == nil {
+		t.Fatal("Identity with a rejected token succeeded")
+	}
+}
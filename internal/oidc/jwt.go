@@ -0,0 +1,221 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidIDToken       = errors.New("invalid id token")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnknownKey           = errors.New("unknown signing key")
+)
+
+// clockSkew is how far the provider's clock may drift from ours.
+const clockSkew = time.Minute
+
+// keyRefreshInterval stops tokens with unknown key IDs from making us fetch
+// the key set on every request.
+const keyRefreshInterval = time.Minute
+
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+type publicKey struct {
+	key *rsa.PublicKey
+	alg string
+}
+
+// Verify checks the ID token's signature against the provider's key set and
+// its issuer, audience and lifetime, and returns its claims.
+func (p *Provider) Verify(ctx context.Context, rawIDToken string) (map[string]any, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err = decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, header.Alg)
+	}
+
+	key, err := p.key(ctx, discovery, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if key.alg != "" && key.alg != header.Alg {
+		return nil, fmt.Errorf("%w: key is for %s", ErrInvalidIDToken, key.alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+
+	digest := hash.New()
+	digest.Write([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key.key, hash, digest.Sum(nil), signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	var claims map[string]any
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.validateClaims(discovery, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (p *Provider) validateClaims(discovery *Discovery, claims map[string]any) error {
+	if stringClaim(claims, "iss") != discovery.Issuer {
+		return fmt.Errorf("%w: wrong issuer", ErrInvalidIDToken)
+	}
+
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, value := range aud {
+			if s, ok := value.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	if !slices.Contains(audiences, p.config.ClientID) {
+		return fmt.Errorf("%w: wrong audience", ErrInvalidIDToken)
+	}
+	if azp := stringClaim(claims, "azp"); len(audiences) > 1 && azp != p.config.ClientID {
+		return fmt.Errorf("%w: wrong authorized party", ErrInvalidIDToken)
+	}
+
+	now := p.now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("%w: not yet valid", ErrInvalidIDToken)
+	}
+
+	return nil
+}
+
+// key returns the signing key with kid, fetching the key set again when the
+// provider has rotated to a key we haven't seen.
+func (p *Provider) key(ctx context.Context, discovery *Discovery, kid string) (publicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	if p.keys != nil && p.now().Sub(p.keysFetchedAt) < keyRefreshInterval {
+		return publicKey{}, ErrUnknownKey
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			Alg string `json:"alg"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	err := p.getJSON(ctx, discovery.JWKSURI, "", &set)
+	if err != nil {
+		return publicKey{}, err
+	}
+
+	keys := make(map[string]publicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Kty != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[jwk.Kid] = publicKey{
+			key: &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			},
+			alg: jwk.Alg,
+		}
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	return publicKey{}, ErrUnknownKey
+}
+
+// lookupKey finds the key with kid. Tokens without a kid are accepted when
+// the set has a single key. The caller holds p.mu.
+func (p *Provider) lookupKey(kid string) (publicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidIDToken)
+	}
+	err = json.Unmarshal(data, out)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidIDToken)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDiscovery = errors.New("invalid discovery document")
+	ErrExchange  = errors.New("code exchange failed")
+	ErrNoIDToken = errors.New("token response has no id token")
+	ErrNoSubject = errors.New("identity has no subject")
+	ErrNoEmail   = errors.New("identity has no email")
+	ErrNonce     = errors.New("id token nonce does not match")
+)
+
+// maxResponseSize bounds what is read from the identity provider.
+const maxResponseSize = 1 << 20
+
+// ClaimMapping names the claims an identity is read from, for providers that
+// don't use the standard claim names.
+type ClaimMapping struct {
+	Email         string
+	EmailVerified string
+	GivenName     string
+	FamilyName    string
+	Picture       string
+}
+
+type Config struct {
+	// Name identifies the provider in routes and on linked accounts.
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Claims       ClaimMapping
+}
+
+// HTTPClient is the part of *http.Client the provider uses, so a stub identity
+// provider can stand in for a real one.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Provider is an OpenID Connect relying party for one identity provider. Its
+// endpoints and signing keys are discovered from the issuer on first use.
+type Provider struct {
+	config Config
+	client HTTPClient
+	now    func() time.Time
+
+	mu            sync.Mutex
+	discovery     *Discovery
+	keys          map[string]publicKey
+	keysFetchedAt time.Time
+}
+
+func NewProvider(config Config, client HTTPClient) *Provider {
+	return &Provider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// Discovery is the subset of the provider's openid-configuration document the
+// relying party uses.
+type Discovery struct {
+	Issuer                   string   `json:"issuer"`
+	AuthorizationEndpoint    string   `json:"authorization_endpoint"`
+	TokenEndpoint            string   `json:"token_endpoint"`
+	UserinfoEndpoint         string   `json:"userinfo_endpoint"`
+	JWKSURI                  string   `json:"jwks_uri"`
+	TokenEndpointAuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+func (p *Provider) discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var discovery Discovery
+	err := p.getJSON(ctx, strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", "", &discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != strings.TrimSuffix(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, discovery.Issuer, p.config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	p.discovery = &discovery
+
+	return p.discovery, nil
+}
+
+// AuthRequest carries the per-attempt values that tie the provider's response
+// to the browser that started the login.
+type AuthRequest struct {
+	State string
+	Nonce string
+	// CodeVerifier is the PKCE secret. Only its S256 challenge is sent here;
+	// the verifier itself goes to Exchange.
+	CodeVerifier string
+}
+
+// AuthCodeURL returns the provider's authorization endpoint for an
+// authorization code login.
+func (p *Provider) AuthCodeURL(ctx context.Context, req AuthRequest) (string, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {p.config.ClientID},
+		"redirect_uri":  {p.config.RedirectURL},
+		"scope":         {strings.Join(p.config.Scopes, " ")},
+	}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	if req.Nonce != "" {
+		params.Set("nonce", req.Nonce)
+	}
+	if req.CodeVerifier != "" {
+		params.Set("code_challenge", CodeChallenge(req.CodeVerifier))
+		params.Set("code_challenge_method", "S256")
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+type Token struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	IDToken     string `json:"id_token"`
+}
+
+// CodeChallenge returns the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Exchange trades an authorization code for tokens. codeVerifier must be the
+// one the login was started with.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.config.RedirectURL},
+	}
+	if codeVerifier != "" {
+		form.Set("code_verifier", codeVerifier)
+	}
+
+	// client_secret_basic is the default when the provider doesn't say.
+	basicAuth := len(discovery.TokenEndpointAuthMethods) == 0 ||
+		slices.Contains(discovery.TokenEndpointAuthMethods, "client_secret_basic")
+	if !basicAuth {
+		form.Set("client_id", p.config.ClientID)
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basicAuth {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		json.Unmarshal(body, &failure)
+		return nil, fmt.Errorf("%w: %d %s %s", ErrExchange, resp.StatusCode, failure.Error, failure.ErrorDescription)
+	}
+
+	var token Token
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.IDToken == "" {
+		return nil, ErrNoIDToken
+	}
+
+	return &token, nil
+}
+
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     *string
+	FamilyName    *string
+	Picture       *string
+	// Claims holds every claim the identity was read from.
+	Claims map[string]any
+}
+
+// Identity verifies the token's ID token, including that it carries nonce,
+// and maps its claims to an identity. Claims the ID token leaves out are
+// filled in from the userinfo endpoint.
+func (p *Provider) Identity(ctx context.Context, token *Token, nonce string) (*Identity, error) {
+	claims, err := p.Verify(ctx, token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(stringClaim(claims, "nonce")), []byte(nonce)) != 1 {
+		return nil, ErrNonce
+	}
+
+	mapping := p.config.Claims
+
+	if _, ok := claims[mapping.Email]; !ok && token.AccessToken != "" {
+		err = p.mergeUserinfo(ctx, token.AccessToken, claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	identity := &Identity{
+		Subject:       stringClaim(claims, "sub"),
+		Email:         stringClaim(claims, mapping.Email),
+		EmailVerified: boolClaim(claims, mapping.EmailVerified),
+		GivenName:     optionalClaim(claims, mapping.GivenName),
+		FamilyName:    optionalClaim(claims, mapping.FamilyName),
+		Picture:       optionalClaim(claims, mapping.Picture),
+		Claims:        claims,
+	}
+
+	if identity.Subject == "" {
+		return nil, ErrNoSubject
+	}
+	if identity.Email == "" {
+		return nil, ErrNoEmail
+	}
+
+	return identity, nil
+}
+
+// mergeUserinfo adds the userinfo claims that claims is missing. Userinfo for
+// a different subject is rejected, as the spec requires.
+func (p *Provider) mergeUserinfo(ctx context.Context, accessToken string, claims map[string]any) error {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+
+	if discovery.UserinfoEndpoint == "" {
+		return nil
+	}
+
+	var userinfo map[string]any
+	err = p.getJSON(ctx, discovery.UserinfoEndpoint, accessToken, &userinfo)
+	if err != nil {
+		return err
+	}
+
+	if stringClaim(userinfo, "sub") != stringClaim(claims, "sub") {
+		return fmt.Errorf("%w: userinfo subject does not match", ErrInvalidIDToken)
+	}
+
+	for key, value := range userinfo {
+		if _, ok := claims[key]; !ok {
+			claims[key] = value
+		}
+	}
+
+	return nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, endpoint, bearer string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", endpoint, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out)
+}
+
+func stringClaim(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+func optionalClaim(claims map[string]any, name string) *string {
+	value := stringClaim(claims, name)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// boolClaim also accepts "true", which some providers send for
+// email_verified.
+func boolClaim(claims map[string]any, name string) bool {
+	switch value := claims[name].(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
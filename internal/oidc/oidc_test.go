@@ -0,0 +1,313 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testClientID = "client-id"
+
+// testIdP is a stub identity provider serving discovery and a key set that
+// tests can rotate.
+type testIdP struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PrivateKey
+	jwksFetches int
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+
+	idp := &testIdP{keys: make(map[string]*rsa.PrivateKey)}
+	idp.addKey(t, "key-1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+
+		idp.jwksFetches++
+
+		keys := []map[string]string{}
+		for kid, key := range idp.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *testIdP) addKey(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp.mu.Lock()
+	idp.keys[kid] = key
+	idp.mu.Unlock()
+}
+
+func (idp *testIdP) fetches() int {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	return idp.jwksFetches
+}
+
+// sign encodes claims as a token with the given header. RS256 tokens are
+// signed with the kid's key, HS256 tokens with that key's public modulus as
+// the HMAC secret, and none tokens aren't signed at all.
+func (idp *testIdP) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	idp.mu.Lock()
+	key := idp.keys[kid]
+	idp.mu.Unlock()
+
+	var signature []byte
+	switch alg {
+	case "RS256":
+		if key == nil {
+			key, err = rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		digest := sha256.Sum256([]byte(signingInput))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "HS256":
+		mac := hmac.New(sha256.New, key.N.Bytes())
+		mac.Write([]byte(signingInput))
+		signature = mac.Sum(nil)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *testIdP) claims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss":            idp.server.URL,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"email":          "someone@example.com",
+		"email_verified": true,
+		"nonce":          "nonce-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func newTestProvider(idp *testIdP, now *time.Time) *Provider {
+	p := NewProvider(Config{
+		Name:     "test",
+		Issuer:   idp.server.URL,
+		ClientID: testClientID,
+		Claims:   ClaimMapping{Email: "email", EmailVerified: "email_verified"},
+	}, idp.server.Client())
+	p.now = func() time.Time { return *now }
+	return p
+}
+
+func TestVerify(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		alg     string
+		kid     string
+		modify  func(claims map[string]any)
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "audience list with azp", modify: func(c map[string]any) {
+			c["aud"] = []any{testClientID, "other-client"}
+			c["azp"] = testClientID
+		}},
+		{name: "expired within skew", modify: func(c map[string]any) { c["exp"] = now.Add(-30 * time.Second).Unix() }},
+		{name: "wrong issuer", modify: func(c map[string]any) { c["iss"] = "https://evil.example.com" }, wantErr: ErrInvalidIDToken},
+		{name: "wrong audience", modify: func(c map[string]any) { c["aud"] = "other-client" }, wantErr: ErrInvalidIDToken},
+		{name: "missing audience", modify: func(c map[string]any) { delete(c, "aud") }, wantErr: ErrInvalidIDToken},
+		{name: "audience list without azp", modify: func(c map[string]any) {
+			c["aud"] = []any{testClientID, "other-client"}
+		}, wantErr: ErrInvalidIDToken},
+		{name: "audience list with wrong azp", modify: func(c map[string]any) {
+			c["aud"] = []any{testClientID, "other-client"}
+			c["azp"] = "other-client"
+		}, wantErr: ErrInvalidIDToken},
+		{name: "expired", modify: func(c map[string]any) { c["exp"] = now.Add(-2 * time.Minute).Unix() }, wantErr: ErrInvalidIDToken},
+		{name: "missing exp", modify: func(c map[string]any) { delete(c, "exp") }, wantErr: ErrInvalidIDToken},
+		{name: "not yet valid", modify: func(c map[string]any) { c["nbf"] = now.Add(2 * time.Minute).Unix() }, wantErr: ErrInvalidIDToken},
+		{name: "alg none", alg: "none", wantErr: ErrUnsupportedAlgorithm},
+		{name: "alg HS256 with public key", alg: "HS256", wantErr: ErrUnsupportedAlgorithm},
+		{name: "unknown kid", kid: "key-9", wantErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(idp, &now)
+
+			alg, kid := tt.alg, tt.kid
+			if alg == "" {
+				alg = "RS256"
+			}
+			if kid == "" {
+				kid = "key-1"
+			}
+
+			claims := idp.claims(now)
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+
+			_, err := p.Verify(context.Background(), idp.sign(t, alg, kid, claims))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsTamperedSignature(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Now()
+	p := newTestProvider(idp, &now)
+
+	token := strings.Split(idp.sign(t, "RS256", "key-1", idp.claims(now)), ".")
+
+	claims := idp.claims(now)
+	claims["sub"] = "someone-else"
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := token[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + token[2]
+
+	_, err = p.Verify(context.Background(), tampered)
+	if !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("Verify err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestVerifyRefreshesKeysForUnknownKid(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	p := newTestProvider(idp, &now)
+	ctx := context.Background()
+
+	_, err := p.Verify(ctx, idp.sign(t, "RS256", "key-1", idp.claims(now)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if idp.fetches() != 1 {
+		t.Fatalf("key set fetched %d times, want 1", idp.fetches())
+	}
+
+	idp.addKey(t, "key-2")
+	rotated := idp.sign(t, "RS256", "key-2", idp.claims(now))
+
+	_, err = p.Verify(ctx, rotated)
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify right after a fetch err = %v, want ErrUnknownKey", err)
+	}
+	if idp.fetches() != 1 {
+		t.Fatalf("key set fetched %d times within the refresh interval, want 1", idp.fetches())
+	}
+
+	now = now.Add(keyRefreshInterval + time.Second)
+
+	_, err = p.Verify(ctx, rotated)
+	if err != nil {
+		t.Fatalf("Verify after rotation err = %v", err)
+	}
+	if idp.fetches() != 2 {
+		t.Fatalf("key set fetched %d times, want 2", idp.fetches())
+	}
+
+	_, err = p.Verify(ctx, idp.sign(t, "RS256", "key-3", idp.claims(now)))
+	if !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Verify with unknown kid err = %v, want ErrUnknownKey", err)
+	}
+	if idp.fetches() != 2 {
+		t.Fatalf("unknown kid fetched the key set again within the refresh interval")
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	idp := newTestIdP(t)
+	now := time.Now()
+	p := newTestProvider(idp, &now)
+	ctx := context.Background()
+
+	token := &Token{IDToken: idp.sign(t, "RS256", "key-1", idp.claims(now))}
+
+	identity, err := p.Identity(ctx, token, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Subject != "subject-1" || identity.Email != "someone@example.com" || !identity.EmailVerified {
+		t.Errorf("Identity = %+v", identity)
+	}
+
+	_, err = p.Identity(ctx, token, "nonce-2")
+	if !errors.Is(err, ErrNonce) {
+		t.Fatalf("Identity with another nonce err = %v, want ErrNonce", err)
+	}
+
+	claims := idp.claims(now)
+	delete(claims, "nonce")
+	_, err = p.Identity(ctx, &Token{IDToken: idp.sign(t, "RS256", "key-1", claims)}, "nonce-1")
+	if !errors.Is(err, ErrNonce) {
+		t.Fatalf("Identity without a nonce err = %v, want ErrNonce", err)
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter allows up to limit events per key in each fixed window. It is safe
+// for concurrent use and keeps its state in memory, so limits are per process.
+type Limiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	count   int
+	resetAt time.Time
+}
+
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		window:  window,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok || !now.Before(b.resetAt) {
+		b = &bucket{resetAt: now.Add(l.window)}
+		l.buckets[key] = b
+	}
+
+	if b.count >= l.limit {
+		return false
+	}
+
+	b.count++
+	return true
+}
+
+// Reset forgets every event recorded for key.
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.buckets, key)
+}
+
+// sweep drops expired buckets at most once per window so memory use stays
+// bounded by the number of keys seen in a window.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.window {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if !now.Before(b.resetAt) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
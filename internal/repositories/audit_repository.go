@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type AuditRepository struct {
+	utils   ServicesUtils
+	DB      *pgxpool.Pool
+	queries *models.Queries
+}
+
+func NewAuditRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries) *AuditRepository {
+	return &AuditRepository{
+		utils:   utils,
+		DB:      db,
+		queries: queries,
+	}
+}
+
+type CreateAuditEventArgs struct {
+	Action         string
+	ActorID        *uuid.UUID
+	ImpersonatorID *uuid.UUID
+	TargetType     *string
+	TargetID       *string
+	IPAddress      *string
+	UserAgent      *string
+	CorrelationID  *string
+	Metadata       map[string]any
+}
+
+func (repo *AuditRepository) CreateAuditEvent(ctx context.Context, args CreateAuditEventArgs) (*entities.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var metadata []byte
+	if len(args.Metadata) > 0 {
+		encoded, err := json.Marshal(args.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		metadata = encoded
+	}
+
+	row, err := repo.queries.CreateAuditEvent(ctx, models.CreateAuditEventParams{
+		Action:         args.Action,
+		ActorID:        args.ActorID,
+		ImpersonatorID: args.ImpersonatorID,
+		TargetType:     args.TargetType,
+		TargetID:       args.TargetID,
+		IpAddress:      args.IPAddress,
+		UserAgent:      args.UserAgent,
+		CorrelationID:  args.CorrelationID,
+		Metadata:       metadata,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating audit event")
+		return nil, err
+	}
+
+	return auditModelToEntity(row), nil
+}
+
+func (repo *AuditRepository) GetAuditEvents(ctx context.Context, filter entities.AuditFilter) ([]*entities.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetAuditEvents(ctx, models.GetAuditEventsParams{
+		Action:        filter.Action,
+		ActorID:       filter.ActorID,
+		CreatedAfter:  toTimestamptz(filter.CreatedAfter),
+		CreatedBefore: toTimestamptz(filter.CreatedBefore),
+		RowLimit:      int32(filter.Limit),
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting audit events")
+		return nil, err
+	}
+
+	events := []*entities.AuditEvent{}
+
+	for _, row := range rows {
+		events = append(events, auditModelToEntity(row))
+	}
+
+	return events, nil
+}
+
+func auditModelToEntity(model models.AuditEvent) *entities.AuditEvent {
+	event := &entities.AuditEvent{
+		ID:             model.ID,
+		Action:         model.Action,
+		ActorID:        model.ActorID,
+		ImpersonatorID: model.ImpersonatorID,
+		TargetType:     model.TargetType,
+		TargetID:       model.TargetID,
+		IPAddress:      model.IpAddress,
+		UserAgent:      model.UserAgent,
+		CorrelationID:  model.CorrelationID,
+		CreatedAt:      model.CreatedAt.Time,
+	}
+
+	if len(model.Metadata) > 0 {
+		json.Unmarshal(model.Metadata, &event.Metadata)
+	}
+
+	return event
+}
@@ -2,17 +2,35 @@ package repositories
 
 import (
 	"context"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/blocklist"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
 	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+	"github.com/mcorrigan89/url_shortener/internal/threatfeed"
 )
 
+// syncTimeout allows for feeds with tens of thousands of entries.
+const syncTimeout = 5 * time.Minute
+
+// patternCacheTTL is how long compiled pattern rules are reused. Patterns are
+// checked on every redirect and only change through SQL, so a minute's delay
+// in picking up a change is the cost of not querying them each time.
+const patternCacheTTL = time.Minute
+
 type BlockedRepository struct {
-	utils   ServicesUtils
-	DB      *pgxpool.Pool
-	queries *models.Queries
+	utils            ServicesUtils
+	DB               *pgxpool.Pool
+	queries          *models.Queries
+	patternsMu       sync.Mutex
+	patterns         []blocklist.Rule
+	patternsLoadedAt time.Time
 }
 
 func NewBlockedRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries) *BlockedRepository {
@@ -23,8 +41,17 @@ func NewBlockedRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models
 	}
 }
 
-func (repo *BlockedRepository) IsDomainBlocked(ctx context.Context, domain string) (bool, error) {
-	_, err := repo.queries.GetBlockedDomain(ctx, domain)
+// IsDomainBlocked reports whether host, any of its parent domains, or a
+// wildcard covering it is on the block list. Every entry on the list is a
+// normalised host, so a host that can't be normalised matches none of them.
+func (repo *BlockedRepository) IsDomainBlocked(ctx context.Context, host string) (bool, error) {
+	candidates, err := blocklist.Candidates(host)
+	if err != nil {
+		repo.utils.logger.Warn().Err(err).Ctx(ctx).Str("host", host).Msg("Host cannot be normalized, so it matches no blocked domain")
+		return false, nil
+	}
+
+	_, err = repo.queries.GetBlockedDomain(ctx, candidates)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return false, nil
@@ -48,3 +75,310 @@ func (repo *BlockedRepository) IsUserBlocked(ctx context.Context, userID uuid.UU
 	}
 	return true, nil
 }
+
+type BlockDomainArgs struct {
+	Domain    string
+	Reason    *string
+	ExpiresAt *time.Time
+	CreatedBy *uuid.UUID
+}
+
+// BlockDomain adds a manual entry for the domain. An entry that came from a
+// feed is taken over so that later imports of that feed leave it in place.
+func (repo *BlockedRepository) BlockDomain(ctx context.Context, args BlockDomainArgs) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	domain, err := blocklist.NormalizeHost(strings.TrimPrefix(strings.TrimSpace(args.Domain), "*."))
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error normalizing domain to block")
+		return "", err
+	}
+	if strings.HasPrefix(strings.TrimSpace(args.Domain), "*.") {
+		domain = "*." + domain
+	}
+
+	err = repo.queries.CreateBlockedDomain(ctx, models.CreateBlockedDomainParams{
+		Domain:    domain,
+		Reason:    args.Reason,
+		ExpiresAt: toTimestamptz(args.ExpiresAt),
+		CreatedBy: args.CreatedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("domain", domain).Msg("Error blocking domain")
+		return "", err
+	}
+
+	return domain, nil
+}
+
+func (repo *BlockedRepository) UnblockDomain(ctx context.Context, domain string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	affected, err := repo.queries.DeleteBlockedDomain(ctx, domain)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("domain", domain).Msg("Error unblocking domain")
+		return err
+	}
+	if affected == 0 {
+		return entities.ErrNotBlocked
+	}
+
+	return nil
+}
+
+func (repo *BlockedRepository) GetBlockedDomainsBySource(ctx context.Context, source string) ([]*entities.BlockedDomain, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetBlockedDomainsBySource(ctx, source)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting blocked domains")
+		return nil, err
+	}
+
+	domains := []*entities.BlockedDomain{}
+
+	for _, row := range rows {
+		domains = append(domains, &entities.BlockedDomain{
+			Domain:    row.Domain,
+			Source:    row.Source,
+			Reason:    row.Reason,
+			ExpiresAt: fromTimestamptz(row.ExpiresAt),
+			CreatedBy: row.CreatedBy,
+			FirstSeen: fromTimestamptz(row.FirstSeen),
+			LastSeen:  fromTimestamptz(row.LastSeen),
+			CreatedAt: row.CreatedAt.Time,
+		})
+	}
+
+	return domains, nil
+}
+
+func (repo *BlockedRepository) CountBlockedDomainsBySource(ctx context.Context) ([]*entities.BlockedDomainSource, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.CountBlockedDomainsBySource(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error counting blocked domains")
+		return nil, err
+	}
+
+	sources := []*entities.BlockedDomainSource{}
+
+	for _, row := range rows {
+		sources = append(sources, &entities.BlockedDomainSource{
+			Source:  row.Source,
+			Domains: int(row.Domains),
+		})
+	}
+
+	return sources, nil
+}
+
+type BlockUserArgs struct {
+	UserID    uuid.UUID
+	Reason    *string
+	ExpiresAt *time.Time
+	CreatedBy *uuid.UUID
+}
+
+func (repo *BlockedRepository) BlockUser(ctx context.Context, args BlockUserArgs) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	err := repo.queries.CreateBlockedUser(ctx, models.CreateBlockedUserParams{
+		UserID:    args.UserID,
+		Reason:    args.Reason,
+		ExpiresAt: toTimestamptz(args.ExpiresAt),
+		CreatedBy: args.CreatedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("userID", args.UserID.String()).Msg("Error blocking user")
+		return err
+	}
+
+	return nil
+}
+
+func (repo *BlockedRepository) UnblockUser(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	affected, err := repo.queries.DeleteBlockedUser(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("userID", userID.String()).Msg("Error unblocking user")
+		return err
+	}
+	if affected == 0 {
+		return entities.ErrNotBlocked
+	}
+
+	return nil
+}
+
+func (repo *BlockedRepository) GetBlockedUsers(ctx context.Context) ([]*entities.BlockedUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetBlockedUsers(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting blocked users")
+		return nil, err
+	}
+
+	users := []*entities.BlockedUser{}
+
+	for _, row := range rows {
+		users = append(users, &entities.BlockedUser{
+			UserID:    row.UserID,
+			Reason:    row.Reason,
+			ExpiresAt: fromTimestamptz(row.ExpiresAt),
+			CreatedBy: row.CreatedBy,
+			CreatedAt: row.CreatedAt.Time,
+		})
+	}
+
+	return users, nil
+}
+
+// GetBlockedPatterns returns the compiled regex and path prefix rules, served
+// from a cache refreshed every patternCacheTTL. Rules that can't be compiled
+// are logged and skipped.
+func (repo *BlockedRepository) GetBlockedPatterns(ctx context.Context) ([]blocklist.Rule, error) {
+	repo.patternsMu.Lock()
+	defer repo.patternsMu.Unlock()
+
+	if repo.patterns != nil && time.Since(repo.patternsLoadedAt) < patternCacheTTL {
+		return repo.patterns, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetBlockedPatterns(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting blocked patterns")
+		return nil, err
+	}
+
+	rules := []blocklist.Rule{}
+
+	for _, row := range rows {
+		rule, err := blocklist.NewRule(row.Kind, row.Pattern)
+		if err != nil {
+			repo.utils.logger.Warn().Err(err).Ctx(ctx).Str("kind", row.Kind).Str("pattern", row.Pattern).Msg("Skipping invalid blocked pattern")
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	repo.patterns = rules
+	repo.patternsLoadedAt = time.Now()
+
+	return rules, nil
+}
+
+type SyncBlockedDomainsArgs struct {
+	Source  string
+	Entries []threatfeed.Entry
+}
+
+// SyncBlockedDomains makes the domains tagged with args.Source match
+// args.Entries: new domains are added, known ones have their seen dates
+// widened, and domains missing from the feed are removed. Domains already on
+// the list under another source are left alone.
+func (repo *BlockedRepository) SyncBlockedDomains(ctx context.Context, args SyncBlockedDomainsArgs) (*entities.BlockListImport, error) {
+	ctx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction syncing blocked domains")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	existingRows, err := qtx.GetBlockedDomainsBySource(ctx, args.Source)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting blocked domains for source")
+		return nil, err
+	}
+
+	existing := map[string]bool{}
+	for _, row := range existingRows {
+		existing[row.Domain] = true
+	}
+
+	result := &entities.BlockListImport{Source: args.Source}
+	seen := map[string]bool{}
+
+	for _, entry := range args.Entries {
+		seen[entry.Domain] = true
+
+		affected, err := qtx.UpsertBlockedDomain(ctx, models.UpsertBlockedDomainParams{
+			Domain:    entry.Domain,
+			Source:    args.Source,
+			FirstSeen: toTimestamptz(entry.FirstSeen),
+			LastSeen:  toTimestamptz(entry.LastSeen),
+		})
+		if err != nil {
+			repo.utils.logger.Err(err).Ctx(ctx).Str("domain", entry.Domain).Msg("Error upserting blocked domain")
+			return nil, err
+		}
+
+		switch {
+		case affected == 0:
+			result.Skipped++
+		case existing[entry.Domain]:
+			result.Updated++
+		default:
+			result.Added++
+		}
+	}
+
+	removed := []string{}
+	for domain := range existing {
+		if !seen[domain] {
+			removed = append(removed, domain)
+		}
+	}
+
+	if len(removed) > 0 {
+		err = qtx.DeleteBlockedDomains(ctx, models.DeleteBlockedDomainsParams{
+			Source:  args.Source,
+			Domains: removed,
+		})
+		if err != nil {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error removing blocked domains")
+			return nil, err
+		}
+	}
+	result.Removed = len(removed)
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func toTimestamptz(t *time.Time) pgtype.Timestamptz {
+	if t == nil {
+		return pgtype.Timestamptz{}
+	}
+	return pgtype.Timestamptz{Time: *t, Valid: true}
+}
+
+func fromTimestamptz(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
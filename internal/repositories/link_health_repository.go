@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type LinkHealthRepository struct {
+	utils   ServicesUtils
+	DB      *pgxpool.Pool
+	queries *models.Queries
+}
+
+func NewLinkHealthRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries) *LinkHealthRepository {
+	return &LinkHealthRepository{
+		utils:   utils,
+		DB:      db,
+		queries: queries,
+	}
+}
+
+type CreateLinkHealthCheckArgs struct {
+	LinkID        uuid.UUID
+	StatusCode    *int
+	Latency       time.Duration
+	RedirectChain []string
+	TLSValid      bool
+	Healthy       bool
+	Error         *string
+}
+
+func (repo *LinkHealthRepository) CreateLinkHealthCheck(ctx context.Context, args CreateLinkHealthCheckArgs) (*entities.LinkHealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	var statusCode *int32
+	if args.StatusCode != nil {
+		code := int32(*args.StatusCode)
+		statusCode = &code
+	}
+
+	redirectChain := args.RedirectChain
+	if redirectChain == nil {
+		redirectChain = []string{}
+	}
+
+	row, err := repo.queries.CreateLinkHealthCheck(ctx, models.CreateLinkHealthCheckParams{
+		LinkID:        args.LinkID,
+		StatusCode:    statusCode,
+		LatencyMs:     int32(args.Latency.Milliseconds()),
+		RedirectChain: redirectChain,
+		TlsValid:      args.TLSValid,
+		Healthy:       args.Healthy,
+		Error:         args.Error,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link health check")
+		return nil, err
+	}
+
+	check := repo.modelToEntity(row)
+
+	return &check, nil
+}
+
+func (repo *LinkHealthRepository) GetRecentLinkHealthChecks(ctx context.Context, linkID uuid.UUID, limit int) ([]*entities.LinkHealthCheck, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetRecentLinkHealthChecks(ctx, models.GetRecentLinkHealthChecksParams{
+		LinkID: linkID,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("linkID", linkID.String()).Msg("Error getting recent link health checks")
+		return nil, err
+	}
+
+	checks := []*entities.LinkHealthCheck{}
+
+	for _, row := range rows {
+		check := repo.modelToEntity(row)
+		checks = append(checks, &check)
+	}
+
+	return checks, nil
+}
+
+func (repo *LinkHealthRepository) modelToEntity(model models.LinkHealthCheck) entities.LinkHealthCheck {
+	var statusCode *int
+	if model.StatusCode != nil {
+		code := int(*model.StatusCode)
+		statusCode = &code
+	}
+
+	return entities.LinkHealthCheck{
+		ID:            model.ID,
+		LinkID:        model.LinkID,
+		StatusCode:    statusCode,
+		Latency:       time.Duration(model.LatencyMs) * time.Millisecond,
+		RedirectChain: model.RedirectChain,
+		TLSValid:      model.TlsValid,
+		Healthy:       model.Healthy,
+		Error:         model.Error,
+		CheckedAt:     model.CheckedAt.Time,
+	}
+}
@@ -87,10 +87,33 @@ func (repo *LinkRepository) GetLinksByUserID(ctx context.Context, userID uuid.UU
 	return links, nil
 }
 
+func (repo *LinkRepository) GetLinksByWorkspaceID(ctx context.Context, workspaceID uuid.UUID) ([]*entities.LinkEntity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	linkRows, err := repo.queries.GetLinksByWorkspaceID(ctx, &workspaceID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("workspaceID", workspaceID.String()).Msg("Error getting links by workspace id")
+		return nil, err
+	}
+
+	links := []*entities.LinkEntity{}
+
+	for _, linkRow := range linkRows {
+		link := repo.modelToEntity(linkRow)
+		links = append(links, &link)
+	}
+
+	return links, nil
+}
+
 type CreateLinkArgs struct {
-	LinkURL    string
-	ShortedURL string
-	CreatedBy  uuid.UUID
+	LinkURL     string
+	ShortedURL  string
+	CreatedBy   uuid.UUID
+	UpdatedBy   uuid.UUID
+	Quarantined bool
+	WorkspaceID *uuid.UUID
 }
 
 func (repo *LinkRepository) CreateLink(ctx context.Context, args CreateLinkArgs) (*entities.LinkEntity, error) {
@@ -100,8 +123,11 @@ func (repo *LinkRepository) CreateLink(ctx context.Context, args CreateLinkArgs)
 	linkRow, err := repo.queries.CreateLink(ctx, models.CreateLinkParams{
 		LinkUrl:      args.LinkURL,
 		CreatedBy:    args.CreatedBy,
-		UpdatedBy:    args.CreatedBy,
+		UpdatedBy:    args.UpdatedBy,
 		ShortenedUrl: args.ShortedURL,
+		Quarantined:  args.Quarantined,
+		WorkspaceID:  args.WorkspaceID,
+		OwnerID:      args.CreatedBy,
 	})
 
 	if err != nil {
@@ -115,10 +141,12 @@ func (repo *LinkRepository) CreateLink(ctx context.Context, args CreateLinkArgs)
 }
 
 type UpdateLinkArgs struct {
-	ID        uuid.UUID
-	UpdatedBy uuid.UUID
-	LinkURL   *string
-	Active    *bool
+	ID          uuid.UUID
+	UpdatedBy   uuid.UUID
+	LinkURL     *string
+	Active      *bool
+	Quarantined *bool
+	Reason      *string
 }
 
 func (repo *LinkRepository) UpdateLink(ctx context.Context, args UpdateLinkArgs) (*entities.LinkEntity, error) {
@@ -132,23 +160,41 @@ func (repo *LinkRepository) UpdateLink(ctx context.Context, args UpdateLinkArgs)
 	}
 	defer tx.Rollback(ctx)
 
-	qtx := repo.queries.WithTx(tx)
+	updatedLinkRow, err := repo.updateLink(ctx, repo.queries.WithTx(tx), args)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	link := repo.modelToEntity(updatedLinkRow)
 
+	return &link, nil
+}
+
+// updateLink applies args and records the previous state in the link's
+// history using qtx, so callers can include it in a larger transaction.
+func (repo *LinkRepository) updateLink(ctx context.Context, qtx *models.Queries, args UpdateLinkArgs) (models.LinkRedirect, error) {
 	previousLinkRow, err := qtx.GetLinkByID(ctx, args.ID)
 	if err != nil {
 		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting previous link")
-		return nil, err
+		return models.LinkRedirect{}, err
 	}
 
 	updatedLinkRow, err := qtx.UpdateLink(ctx, models.UpdateLinkParams{
-		ID:        args.ID,
-		LinkUrl:   args.LinkURL,
-		UpdatedBy: args.UpdatedBy,
-		Active:    args.Active,
+		ID:          args.ID,
+		LinkUrl:     args.LinkURL,
+		UpdatedBy:   args.UpdatedBy,
+		Active:      args.Active,
+		Quarantined: args.Quarantined,
 	})
 	if err != nil {
 		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error updating link")
-		return nil, err
+		return models.LinkRedirect{}, err
 	}
 
 	_, err = qtx.CreateLinkHistory(ctx, models.CreateLinkHistoryParams{
@@ -158,21 +204,128 @@ func (repo *LinkRepository) UpdateLink(ctx context.Context, args UpdateLinkArgs)
 		Quarantined: previousLinkRow.Quarantined,
 		CreatedBy:   previousLinkRow.CreatedBy,
 		UpdatedBy:   args.UpdatedBy,
+		Reason:      args.Reason,
 	})
 	if err != nil {
 		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link history")
-		return nil, err
+		return models.LinkRedirect{}, err
+	}
+
+	return updatedLinkRow, nil
+}
+
+type DeactivateLinksByOwnerArgs struct {
+	OwnerID   uuid.UUID
+	UpdatedBy uuid.UUID
+	Reason    string
+}
+
+// DeactivateLinksByOwner switches off every active link the user owns,
+// recording history for each, and returns how many were changed.
+func (repo *LinkRepository) DeactivateLinksByOwner(ctx context.Context, args DeactivateLinksByOwnerArgs) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction deactivating links")
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	linkRows, err := qtx.GetActiveLinksByOwner(ctx, args.OwnerID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting links to deactivate")
+		return 0, err
+	}
+
+	active := false
+	for _, linkRow := range linkRows {
+		_, err = qtx.UpdateLink(ctx, models.UpdateLinkParams{
+			ID:        linkRow.ID,
+			UpdatedBy: args.UpdatedBy,
+			Active:    &active,
+		})
+		if err != nil {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error deactivating link")
+			return 0, err
+		}
+
+		_, err = qtx.CreateLinkHistory(ctx, models.CreateLinkHistoryParams{
+			LinkID:      linkRow.ID,
+			LinkUrl:     linkRow.LinkUrl,
+			Active:      linkRow.Active,
+			Quarantined: linkRow.Quarantined,
+			CreatedBy:   linkRow.CreatedBy,
+			UpdatedBy:   args.UpdatedBy,
+			Reason:      &args.Reason,
+		})
+		if err != nil {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link history")
+			return 0, err
+		}
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
 		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return 0, err
+	}
+
+	return len(linkRows), nil
+}
+
+func (repo *LinkRepository) GetActiveLinks(ctx context.Context) ([]*entities.LinkEntity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	linkRows, err := repo.queries.GetActiveLinks(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting active links")
 		return nil, err
 	}
 
-	link := repo.modelToEntity(updatedLinkRow)
+	links := []*entities.LinkEntity{}
 
-	return &link, nil
+	for _, linkRow := range linkRows {
+		link := repo.modelToEntity(linkRow)
+		links = append(links, &link)
+	}
+
+	return links, nil
+}
+
+// CountActiveLinksCreatedBy counts the active links the user created, in any
+// workspace.
+func (repo *LinkRepository) CountActiveLinksCreatedBy(ctx context.Context, userID uuid.UUID) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	count, err := repo.queries.CountActiveLinksCreatedBy(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("userID", userID.String()).Msg("Error counting links created by user")
+		return 0, err
+	}
+
+	return int(count), nil
+}
+
+func (repo *LinkRepository) SetLinkBroken(ctx context.Context, linkID uuid.UUID, broken bool) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	err := repo.queries.SetLinkBroken(ctx, models.SetLinkBrokenParams{
+		ID:     linkID,
+		Broken: broken,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("linkID", linkID.String()).Msg("Error setting link broken")
+		return err
+	}
+
+	return nil
 }
 
 func (repo *LinkRepository) modelToEntity(model models.LinkRedirect) entities.LinkEntity {
@@ -182,7 +335,10 @@ func (repo *LinkRepository) modelToEntity(model models.LinkRedirect) entities.Li
 		ShortenedURLSlug: model.ShortenedUrl,
 		LinkURL:          model.LinkUrl,
 		CreatedBy:        model.CreatedBy,
+		OwnerID:          model.OwnerID,
+		WorkspaceID:      model.WorkspaceID,
 		Quarantined:      model.Quarantined,
 		Active:           model.Active,
+		Broken:           model.Broken,
 	}
 }
@@ -9,26 +9,283 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countBlockedDomainsBySource = `-- name: CountBlockedDomainsBySource :many
+SELECT source, count(*) AS domains FROM blocked_domain GROUP BY source ORDER BY source
+`
+
+type CountBlockedDomainsBySourceRow struct {
+	Source  string `json:"source"`
+	Domains int64  `json:"domains"`
+}
+
+func (q *Queries) CountBlockedDomainsBySource(ctx context.Context) ([]CountBlockedDomainsBySourceRow, error) {
+	rows, err := q.db.Query(ctx, countBlockedDomainsBySource)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []CountBlockedDomainsBySourceRow{}
+	for rows.Next() {
+		var i CountBlockedDomainsBySourceRow
+		if err := rows.Scan(&i.Source, &i.Domains); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createBlockedDomain = `-- name: CreateBlockedDomain :exec
+INSERT INTO blocked_domain (domain, reason, expires_at, created_by) VALUES ($1, $2, $3, $4)
+ON CONFLICT (domain) DO UPDATE SET
+  source = EXCLUDED.source,
+  reason = EXCLUDED.reason,
+  expires_at = EXCLUDED.expires_at,
+  created_by = EXCLUDED.created_by
+`
+
+type CreateBlockedDomainParams struct {
+	Domain    string             `json:"domain"`
+	Reason    *string            `json:"reason"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedBy *uuid.UUID         `json:"created_by"`
+}
+
+func (q *Queries) CreateBlockedDomain(ctx context.Context, arg CreateBlockedDomainParams) error {
+	_, err := q.db.Exec(ctx, createBlockedDomain,
+		arg.Domain,
+		arg.Reason,
+		arg.ExpiresAt,
+		arg.CreatedBy,
+	)
+	return err
+}
+
+const createBlockedUser = `-- name: CreateBlockedUser :exec
+INSERT INTO blocked_user (user_id, reason, expires_at, created_by) VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO UPDATE SET
+  reason = EXCLUDED.reason,
+  expires_at = EXCLUDED.expires_at,
+  created_by = EXCLUDED.created_by,
+  created_at = now()
+`
+
+type CreateBlockedUserParams struct {
+	UserID    uuid.UUID          `json:"user_id"`
+	Reason    *string            `json:"reason"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedBy *uuid.UUID         `json:"created_by"`
+}
+
+func (q *Queries) CreateBlockedUser(ctx context.Context, arg CreateBlockedUserParams) error {
+	_, err := q.db.Exec(ctx, createBlockedUser,
+		arg.UserID,
+		arg.Reason,
+		arg.ExpiresAt,
+		arg.CreatedBy,
+	)
+	return err
+}
+
+const deleteBlockedDomain = `-- name: DeleteBlockedDomain :execrows
+DELETE FROM blocked_domain WHERE domain = $1
+`
+
+func (q *Queries) DeleteBlockedDomain(ctx context.Context, domain string) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteBlockedDomain, domain)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const deleteBlockedDomains = `-- name: DeleteBlockedDomains :exec
+DELETE FROM blocked_domain WHERE source = $1 AND domain = ANY($2::text[])
+`
+
+type DeleteBlockedDomainsParams struct {
+	Source  string   `json:"source"`
+	Domains []string `json:"domains"`
+}
+
+func (q *Queries) DeleteBlockedDomains(ctx context.Context, arg DeleteBlockedDomainsParams) error {
+	_, err := q.db.Exec(ctx, deleteBlockedDomains, arg.Source, arg.Domains)
+	return err
+}
+
+const deleteBlockedUser = `-- name: DeleteBlockedUser :execrows
+DELETE FROM blocked_user WHERE user_id = $1
+`
+
+func (q *Queries) DeleteBlockedUser(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteBlockedUser, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const getBlockedDomain = `-- name: GetBlockedDomain :one
-SELECT domain, created_at FROM blocked_domain WHERE domain = $1
+SELECT domain, created_at, source, first_seen, last_seen, reason, expires_at, created_by FROM blocked_domain WHERE lower(domain) = ANY($1::text[])
+AND (expires_at IS NULL OR expires_at > now()) LIMIT 1
 `
 
-func (q *Queries) GetBlockedDomain(ctx context.Context, domain string) (BlockedDomain, error) {
-	row := q.db.QueryRow(ctx, getBlockedDomain, domain)
+func (q *Queries) GetBlockedDomain(ctx context.Context, domains []string) (BlockedDomain, error) {
+	row := q.db.QueryRow(ctx, getBlockedDomain, domains)
 	var i BlockedDomain
-	err := row.Scan(&i.Domain, &i.CreatedAt)
+	err := row.Scan(
+		&i.Domain,
+		&i.CreatedAt,
+		&i.Source,
+		&i.FirstSeen,
+		&i.LastSeen,
+		&i.Reason,
+		&i.ExpiresAt,
+		&i.CreatedBy,
+	)
 	return i, err
 }
 
+const getBlockedDomainsBySource = `-- name: GetBlockedDomainsBySource :many
+SELECT domain, created_at, source, first_seen, last_seen, reason, expires_at, created_by FROM blocked_domain WHERE source = $1 ORDER BY domain
+`
+
+func (q *Queries) GetBlockedDomainsBySource(ctx context.Context, source string) ([]BlockedDomain, error) {
+	rows, err := q.db.Query(ctx, getBlockedDomainsBySource, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BlockedDomain{}
+	for rows.Next() {
+		var i BlockedDomain
+		if err := rows.Scan(
+			&i.Domain,
+			&i.CreatedAt,
+			&i.Source,
+			&i.FirstSeen,
+			&i.LastSeen,
+			&i.Reason,
+			&i.ExpiresAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getBlockedPatterns = `-- name: GetBlockedPatterns :many
+SELECT id, kind, pattern, created_at FROM blocked_pattern
+`
+
+func (q *Queries) GetBlockedPatterns(ctx context.Context) ([]BlockedPattern, error) {
+	rows, err := q.db.Query(ctx, getBlockedPatterns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BlockedPattern{}
+	for rows.Next() {
+		var i BlockedPattern
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Pattern,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getBlockedUser = `-- name: GetBlockedUser :one
-SELECT user_id, created_at FROM blocked_user WHERE user_id = $1
+SELECT user_id, created_at, reason, expires_at, created_by FROM blocked_user WHERE user_id = $1
+AND (expires_at IS NULL OR expires_at > now())
 `
 
 func (q *Queries) GetBlockedUser(ctx context.Context, userID uuid.UUID) (BlockedUser, error) {
 	row := q.db.QueryRow(ctx, getBlockedUser, userID)
 	var i BlockedUser
-	err := row.Scan(&i.UserID, &i.CreatedAt)
+	err := row.Scan(
+		&i.UserID,
+		&i.CreatedAt,
+		&i.Reason,
+		&i.ExpiresAt,
+		&i.CreatedBy,
+	)
 	return i, err
 }
+
+const getBlockedUsers = `-- name: GetBlockedUsers :many
+SELECT user_id, created_at, reason, expires_at, created_by FROM blocked_user ORDER BY created_at DESC
+`
+
+func (q *Queries) GetBlockedUsers(ctx context.Context) ([]BlockedUser, error) {
+	rows, err := q.db.Query(ctx, getBlockedUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []BlockedUser{}
+	for rows.Next() {
+		var i BlockedUser
+		if err := rows.Scan(
+			&i.UserID,
+			&i.CreatedAt,
+			&i.Reason,
+			&i.ExpiresAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertBlockedDomain = `-- name: UpsertBlockedDomain :execrows
+INSERT INTO blocked_domain (domain, source, first_seen, last_seen) VALUES ($1, $2, $3, $4)
+ON CONFLICT (domain) DO UPDATE SET
+  first_seen = LEAST(blocked_domain.first_seen, EXCLUDED.first_seen),
+  last_seen = GREATEST(blocked_domain.last_seen, EXCLUDED.last_seen)
+WHERE blocked_domain.source = EXCLUDED.source
+`
+
+type UpsertBlockedDomainParams struct {
+	Domain    string             `json:"domain"`
+	Source    string             `json:"source"`
+	FirstSeen pgtype.Timestamptz `json:"first_seen"`
+	LastSeen  pgtype.Timestamptz `json:"last_seen"`
+}
+
+func (q *Queries) UpsertBlockedDomain(ctx context.Context, arg UpsertBlockedDomainParams) (int64, error) {
+	result, err := q.db.Exec(ctx, upsertBlockedDomain,
+		arg.Domain,
+		arg.Source,
+		arg.FirstSeen,
+		arg.LastSeen,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
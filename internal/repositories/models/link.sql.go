@@ -11,16 +11,30 @@ import (
 	"github.com/google/uuid"
 )
 
+const countActiveLinksCreatedBy = `-- name: CountActiveLinksCreatedBy :one
+SELECT count(*) FROM link_redirect WHERE created_by = $1 AND active = TRUE
+`
+
+func (q *Queries) CountActiveLinksCreatedBy(ctx context.Context, createdBy uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countActiveLinksCreatedBy, createdBy)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createLink = `-- name: CreateLink :one
-INSERT INTO link_redirect (link_url, shortened_url, created_by, updated_by) 
-VALUES ($1, $2, $3, $4) RETURNING id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version
+INSERT INTO link_redirect (link_url, shortened_url, created_by, updated_by, quarantined, workspace_id, owner_id) 
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id
 `
 
 type CreateLinkParams struct {
-	LinkUrl      string    `json:"link_url"`
-	ShortenedUrl string    `json:"shortened_url"`
-	CreatedBy    uuid.UUID `json:"created_by"`
-	UpdatedBy    uuid.UUID `json:"updated_by"`
+	LinkUrl      string     `json:"link_url"`
+	ShortenedUrl string     `json:"shortened_url"`
+	CreatedBy    uuid.UUID  `json:"created_by"`
+	UpdatedBy    uuid.UUID  `json:"updated_by"`
+	Quarantined  bool       `json:"quarantined"`
+	WorkspaceID  *uuid.UUID `json:"workspace_id"`
+	OwnerID      uuid.UUID  `json:"owner_id"`
 }
 
 func (q *Queries) CreateLink(ctx context.Context, arg CreateLinkParams) (LinkRedirect, error) {
@@ -29,6 +43,9 @@ func (q *Queries) CreateLink(ctx context.Context, arg CreateLinkParams) (LinkRed
 		arg.ShortenedUrl,
 		arg.CreatedBy,
 		arg.UpdatedBy,
+		arg.Quarantined,
+		arg.WorkspaceID,
+		arg.OwnerID,
 	)
 	var i LinkRedirect
 	err := row.Scan(
@@ -42,13 +59,16 @@ func (q *Queries) CreateLink(ctx context.Context, arg CreateLinkParams) (LinkRed
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Broken,
+		&i.WorkspaceID,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const createLinkHistory = `-- name: CreateLinkHistory :one
-INSERT INTO link_redirect_history (link_id, link_url, active, quarantined, created_by, updated_by)
-VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, link_id, link_url, active, quarantined, created_by, updated_by, created_at, updated_at, version
+INSERT INTO link_redirect_history (link_id, link_url, active, quarantined, created_by, updated_by, reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, link_id, link_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, reason
 `
 
 type CreateLinkHistoryParams struct {
@@ -58,6 +78,7 @@ type CreateLinkHistoryParams struct {
 	Quarantined bool      `json:"quarantined"`
 	CreatedBy   uuid.UUID `json:"created_by"`
 	UpdatedBy   uuid.UUID `json:"updated_by"`
+	Reason      *string   `json:"reason"`
 }
 
 func (q *Queries) CreateLinkHistory(ctx context.Context, arg CreateLinkHistoryParams) (LinkRedirectHistory, error) {
@@ -68,6 +89,7 @@ func (q *Queries) CreateLinkHistory(ctx context.Context, arg CreateLinkHistoryPa
 		arg.Quarantined,
 		arg.CreatedBy,
 		arg.UpdatedBy,
+		arg.Reason,
 	)
 	var i LinkRedirectHistory
 	err := row.Scan(
@@ -81,12 +103,89 @@ func (q *Queries) CreateLinkHistory(ctx context.Context, arg CreateLinkHistoryPa
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Reason,
 	)
 	return i, err
 }
 
+const getActiveLinks = `-- name: GetActiveLinks :many
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE active = TRUE AND quarantined = FALSE
+`
+
+func (q *Queries) GetActiveLinks(ctx context.Context) ([]LinkRedirect, error) {
+	rows, err := q.db.Query(ctx, getActiveLinks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LinkRedirect{}
+	for rows.Next() {
+		var i LinkRedirect
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkUrl,
+			&i.ShortenedUrl,
+			&i.Active,
+			&i.Quarantined,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.Broken,
+			&i.WorkspaceID,
+			&i.OwnerID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveLinksByOwner = `-- name: GetActiveLinksByOwner :many
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE owner_id = $1 AND active = TRUE
+`
+
+func (q *Queries) GetActiveLinksByOwner(ctx context.Context, ownerID uuid.UUID) ([]LinkRedirect, error) {
+	rows, err := q.db.Query(ctx, getActiveLinksByOwner, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LinkRedirect{}
+	for rows.Next() {
+		var i LinkRedirect
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkUrl,
+			&i.ShortenedUrl,
+			&i.Active,
+			&i.Quarantined,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.Broken,
+			&i.WorkspaceID,
+			&i.OwnerID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLinkByID = `-- name: GetLinkByID :one
-SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version FROM link_redirect WHERE id = $1
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE id = $1
 `
 
 func (q *Queries) GetLinkByID(ctx context.Context, id uuid.UUID) (LinkRedirect, error) {
@@ -103,12 +202,15 @@ func (q *Queries) GetLinkByID(ctx context.Context, id uuid.UUID) (LinkRedirect,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Broken,
+		&i.WorkspaceID,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
 const getLinkByShortenedURL = `-- name: GetLinkByShortenedURL :one
-SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version FROM link_redirect WHERE shortened_url = $1
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE shortened_url = $1
 `
 
 func (q *Queries) GetLinkByShortenedURL(ctx context.Context, shortenedUrl string) (LinkRedirect, error) {
@@ -125,16 +227,57 @@ func (q *Queries) GetLinkByShortenedURL(ctx context.Context, shortenedUrl string
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Broken,
+		&i.WorkspaceID,
+		&i.OwnerID,
 	)
 	return i, err
 }
 
+const getLinksByOwnerID = `-- name: GetLinksByOwnerID :many
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE owner_id = $1
+`
+
+func (q *Queries) GetLinksByOwnerID(ctx context.Context, ownerID uuid.UUID) ([]LinkRedirect, error) {
+	rows, err := q.db.Query(ctx, getLinksByOwnerID, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LinkRedirect{}
+	for rows.Next() {
+		var i LinkRedirect
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkUrl,
+			&i.ShortenedUrl,
+			&i.Active,
+			&i.Quarantined,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.Broken,
+			&i.WorkspaceID,
+			&i.OwnerID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getLinksByUserID = `-- name: GetLinksByUserID :many
-SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version FROM link_redirect WHERE created_by = $1 OR updated_by = $1
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE owner_id = $1 AND workspace_id IS NULL
 `
 
-func (q *Queries) GetLinksByUserID(ctx context.Context, createdBy uuid.UUID) ([]LinkRedirect, error) {
-	rows, err := q.db.Query(ctx, getLinksByUserID, createdBy)
+func (q *Queries) GetLinksByUserID(ctx context.Context, ownerID uuid.UUID) ([]LinkRedirect, error) {
+	rows, err := q.db.Query(ctx, getLinksByUserID, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -153,6 +296,9 @@ func (q *Queries) GetLinksByUserID(ctx context.Context, createdBy uuid.UUID) ([]
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.Version,
+			&i.Broken,
+			&i.WorkspaceID,
+			&i.OwnerID,
 		); err != nil {
 			return nil, err
 		}
@@ -164,27 +310,125 @@ func (q *Queries) GetLinksByUserID(ctx context.Context, createdBy uuid.UUID) ([]
 	return items, nil
 }
 
+const getLinksByWorkspaceID = `-- name: GetLinksByWorkspaceID :many
+SELECT id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id FROM link_redirect WHERE workspace_id = $1
+`
+
+func (q *Queries) GetLinksByWorkspaceID(ctx context.Context, workspaceID *uuid.UUID) ([]LinkRedirect, error) {
+	rows, err := q.db.Query(ctx, getLinksByWorkspaceID, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LinkRedirect{}
+	for rows.Next() {
+		var i LinkRedirect
+		if err := rows.Scan(
+			&i.ID,
+			&i.LinkUrl,
+			&i.ShortenedUrl,
+			&i.Active,
+			&i.Quarantined,
+			&i.CreatedBy,
+			&i.UpdatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.Broken,
+			&i.WorkspaceID,
+			&i.OwnerID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setLinkBroken = `-- name: SetLinkBroken :exec
+UPDATE link_redirect SET broken = $2 WHERE id = $1
+`
+
+type SetLinkBrokenParams struct {
+	ID     uuid.UUID `json:"id"`
+	Broken bool      `json:"broken"`
+}
+
+func (q *Queries) SetLinkBroken(ctx context.Context, arg SetLinkBrokenParams) error {
+	_, err := q.db.Exec(ctx, setLinkBroken, arg.ID, arg.Broken)
+	return err
+}
+
+const transferLink = `-- name: TransferLink :one
+UPDATE link_redirect SET 
+owner_id = $2, 
+workspace_id = $3, 
+updated_by = $4, 
+updated_at = now(), 
+version = version + 1 
+WHERE id = $1 RETURNING id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id
+`
+
+type TransferLinkParams struct {
+	ID          uuid.UUID  `json:"id"`
+	OwnerID     uuid.UUID  `json:"owner_id"`
+	WorkspaceID *uuid.UUID `json:"workspace_id"`
+	UpdatedBy   uuid.UUID  `json:"updated_by"`
+}
+
+func (q *Queries) TransferLink(ctx context.Context, arg TransferLinkParams) (LinkRedirect, error) {
+	row := q.db.QueryRow(ctx, transferLink,
+		arg.ID,
+		arg.OwnerID,
+		arg.WorkspaceID,
+		arg.UpdatedBy,
+	)
+	var i LinkRedirect
+	err := row.Scan(
+		&i.ID,
+		&i.LinkUrl,
+		&i.ShortenedUrl,
+		&i.Active,
+		&i.Quarantined,
+		&i.CreatedBy,
+		&i.UpdatedBy,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.Broken,
+		&i.WorkspaceID,
+		&i.OwnerID,
+	)
+	return i, err
+}
+
 const updateLink = `-- name: UpdateLink :one
 UPDATE link_redirect SET 
 link_url = COALESCE($1, link_url), 
 active = COALESCE($2, active),  
-updated_by = $3, 
+quarantined = COALESCE($3, quarantined), 
+updated_by = $4, 
 updated_at = now(), 
 version = version + 1 
-WHERE id = $4 RETURNING id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version
+WHERE id = $5 RETURNING id, link_url, shortened_url, active, quarantined, created_by, updated_by, created_at, updated_at, version, broken, workspace_id, owner_id
 `
 
 type UpdateLinkParams struct {
-	LinkUrl   *string   `json:"link_url"`
-	Active    *bool     `json:"active"`
-	UpdatedBy uuid.UUID `json:"updated_by"`
-	ID        uuid.UUID `json:"id"`
+	LinkUrl     *string   `json:"link_url"`
+	Active      *bool     `json:"active"`
+	Quarantined *bool     `json:"quarantined"`
+	UpdatedBy   uuid.UUID `json:"updated_by"`
+	ID          uuid.UUID `json:"id"`
 }
 
 func (q *Queries) UpdateLink(ctx context.Context, arg UpdateLinkParams) (LinkRedirect, error) {
 	row := q.db.QueryRow(ctx, updateLink,
 		arg.LinkUrl,
 		arg.Active,
+		arg.Quarantined,
 		arg.UpdatedBy,
 		arg.ID,
 	)
@@ -200,6 +444,9 @@ func (q *Queries) UpdateLink(ctx context.Context, arg UpdateLinkParams) (LinkRed
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Broken,
+		&i.WorkspaceID,
+		&i.OwnerID,
 	)
 	return i, err
 }
@@ -9,14 +9,68 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AuditEvent struct {
+	ID             uuid.UUID          `json:"id"`
+	Action         string             `json:"action"`
+	ActorID        *uuid.UUID         `json:"actor_id"`
+	ImpersonatorID *uuid.UUID         `json:"impersonator_id"`
+	TargetType     *string            `json:"target_type"`
+	TargetID       *string            `json:"target_id"`
+	IpAddress      *string            `json:"ip_address"`
+	UserAgent      *string            `json:"user_agent"`
+	CorrelationID  *string            `json:"correlation_id"`
+	Metadata       []byte             `json:"metadata"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+}
+
 type BlockedDomain struct {
 	Domain    string             `json:"domain"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Source    string             `json:"source"`
+	FirstSeen pgtype.Timestamptz `json:"first_seen"`
+	LastSeen  pgtype.Timestamptz `json:"last_seen"`
+	Reason    *string            `json:"reason"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedBy *uuid.UUID         `json:"created_by"`
+}
+
+type BlockedPattern struct {
+	ID        uuid.UUID          `json:"id"`
+	Kind      string             `json:"kind"`
+	Pattern   string             `json:"pattern"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
 type BlockedUser struct {
 	UserID    uuid.UUID          `json:"user_id"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	Reason    *string            `json:"reason"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedBy *uuid.UUID         `json:"created_by"`
+}
+
+type LinkHealthCheck struct {
+	ID            uuid.UUID          `json:"id"`
+	LinkID        uuid.UUID          `json:"link_id"`
+	StatusCode    *int32             `json:"status_code"`
+	LatencyMs     int32              `json:"latency_ms"`
+	RedirectChain []string           `json:"redirect_chain"`
+	TlsValid      bool               `json:"tls_valid"`
+	Healthy       bool               `json:"healthy"`
+	Error         *string            `json:"error"`
+	CheckedAt     pgtype.Timestamptz `json:"checked_at"`
+}
+
+type LinkQuarantine struct {
+	ID         uuid.UUID          `json:"id"`
+	LinkID     uuid.UUID          `json:"link_id"`
+	Source     string             `json:"source"`
+	Reason     string             `json:"reason"`
+	ReporterID *uuid.UUID         `json:"reporter_id"`
+	Status     string             `json:"status"`
+	DecidedBy  *uuid.UUID         `json:"decided_by"`
+	DecidedAt  pgtype.Timestamptz `json:"decided_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
 }
 
 type LinkRedirect struct {
@@ -30,6 +84,9 @@ type LinkRedirect struct {
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
 	Version      int32              `json:"version"`
+	Broken       bool               `json:"broken"`
+	WorkspaceID  *uuid.UUID         `json:"workspace_id"`
+	OwnerID      uuid.UUID          `json:"owner_id"`
 }
 
 type LinkRedirectHistory struct {
@@ -43,6 +100,54 @@ type LinkRedirectHistory struct {
 	CreatedAt   pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
 	Version     int32              `json:"version"`
+	Reason      *string            `json:"reason"`
+}
+
+type LinkReport struct {
+	ID         uuid.UUID          `json:"id"`
+	LinkID     uuid.UUID          `json:"link_id"`
+	ReporterID *uuid.UUID         `json:"reporter_id"`
+	IpAddress  string             `json:"ip_address"`
+	Category   string             `json:"category"`
+	Comment    *string            `json:"comment"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type LinkTransfer struct {
+	ID            uuid.UUID          `json:"id"`
+	LinkID        uuid.UUID          `json:"link_id"`
+	FromUserID    uuid.UUID          `json:"from_user_id"`
+	ToUserID      *uuid.UUID         `json:"to_user_id"`
+	ToWorkspaceID *uuid.UUID         `json:"to_workspace_id"`
+	Status        string             `json:"status"`
+	DecidedBy     *uuid.UUID         `json:"decided_by"`
+	DecidedAt     pgtype.Timestamptz `json:"decided_at"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+}
+
+type LoginLink struct {
+	ID        uuid.UUID          `json:"id"`
+	Email     string             `json:"email"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type PasswordReset struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type RolePolicy struct {
+	Role             string             `json:"role"`
+	RequireTwoFactor bool               `json:"require_two_factor"`
+	UpdatedBy        *uuid.UUID         `json:"updated_by"`
+	UpdatedAt        pgtype.Timestamptz `json:"updated_at"`
 }
 
 type SchemaMigration struct {
@@ -60,6 +165,7 @@ type User struct {
 	CreatedAt     pgtype.Timestamptz `json:"created_at"`
 	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
 	Version       int32              `json:"version"`
+	Role          string             `json:"role"`
 }
 
 type UserAuth struct {
@@ -75,14 +181,74 @@ type UserAuth struct {
 	Version      int32              `json:"version"`
 }
 
+type UserNotification struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	LinkID    *uuid.UUID         `json:"link_id"`
+	Kind      string             `json:"kind"`
+	Message   string             `json:"message"`
+	Read      bool               `json:"read"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type UserRecoveryCode struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    uuid.UUID          `json:"user_id"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type UserSession struct {
-	ID             uuid.UUID          `json:"id"`
-	UserID         uuid.UUID          `json:"user_id"`
-	ImpersonatorID *uuid.UUID         `json:"impersonator_id"`
-	Token          string             `json:"token"`
-	ExpiresAt      pgtype.Timestamptz `json:"expires_at"`
-	UserExpired    bool               `json:"user_expired"`
-	CreatedAt      pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
-	Version        int32              `json:"version"`
+	ID                uuid.UUID          `json:"id"`
+	UserID            uuid.UUID          `json:"user_id"`
+	ImpersonatorID    *uuid.UUID         `json:"impersonator_id"`
+	ExpiresAt         pgtype.Timestamptz `json:"expires_at"`
+	UserExpired       bool               `json:"user_expired"`
+	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt         pgtype.Timestamptz `json:"updated_at"`
+	Version           int32              `json:"version"`
+	UserAgent         *string            `json:"user_agent"`
+	IpAddress         *string            `json:"ip_address"`
+	LastSeenAt        pgtype.Timestamptz `json:"last_seen_at"`
+	TokenHash         string             `json:"token_hash"`
+	AbsoluteExpiresAt pgtype.Timestamptz `json:"absolute_expires_at"`
+	TwoFactorPending  bool               `json:"two_factor_pending"`
+}
+
+type UserTwoFactor struct {
+	UserID       uuid.UUID          `json:"user_id"`
+	Secret       string             `json:"secret"`
+	EnabledAt    pgtype.Timestamptz `json:"enabled_at"`
+	LastUsedStep int64              `json:"last_used_step"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+}
+
+type Workspace struct {
+	ID        uuid.UUID          `json:"id"`
+	Name      string             `json:"name"`
+	CreatedBy *uuid.UUID         `json:"created_by"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	Version   int32              `json:"version"`
+}
+
+type WorkspaceInvite struct {
+	ID          uuid.UUID          `json:"id"`
+	WorkspaceID uuid.UUID          `json:"workspace_id"`
+	Email       string             `json:"email"`
+	Role        string             `json:"role"`
+	InvitedBy   *uuid.UUID         `json:"invited_by"`
+	ExpiresAt   pgtype.Timestamptz `json:"expires_at"`
+	AcceptedBy  *uuid.UUID         `json:"accepted_by"`
+	AcceptedAt  pgtype.Timestamptz `json:"accepted_at"`
+	RevokedAt   pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID uuid.UUID          `json:"workspace_id"`
+	UserID      uuid.UUID          `json:"user_id"`
+	Role        string             `json:"role"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
 }
@@ -12,9 +12,82 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countUnusedRecoveryCodes = `-- name: CountUnusedRecoveryCodes :one
+SELECT count(*) FROM user_recovery_code WHERE user_id = $1 AND used_at IS NULL
+`
+
+func (q *Queries) CountUnusedRecoveryCodes(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnusedRecoveryCodes, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createLoginLink = `-- name: CreateLoginLink :one
+INSERT INTO login_link (email, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id, email, token_hash, expires_at, used_at, created_at
+`
+
+type CreateLoginLinkParams struct {
+	Email     string             `json:"email"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreateLoginLink(ctx context.Context, arg CreateLoginLinkParams) (LoginLink, error) {
+	row := q.db.QueryRow(ctx, createLoginLink, arg.Email, arg.TokenHash, arg.ExpiresAt)
+	var i LoginLink
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createPasswordReset = `-- name: CreatePasswordReset :one
+INSERT INTO password_reset (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id, user_id, token_hash, expires_at, used_at, created_at
+`
+
+type CreatePasswordResetParams struct {
+	UserID    uuid.UUID          `json:"user_id"`
+	TokenHash string             `json:"token_hash"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+}
+
+func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error) {
+	row := q.db.QueryRow(ctx, createPasswordReset, arg.UserID, arg.TokenHash, arg.ExpiresAt)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_code (user_id, code_hash) VALUES ($1, $2)
+`
+
+type CreateRecoveryCodeParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	CodeHash string    `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.Exec(ctx, createRecoveryCode, arg.UserID, arg.CodeHash)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (given_name, family_name, email, email_verified, avatar_url) 
-VALUES ($1, $2, $3, $4::boolean, $5) RETURNING id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version
+VALUES ($1, $2, $3, $4::boolean, $5) RETURNING id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role
 `
 
 type CreateUserParams struct {
@@ -44,6 +117,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Role,
 	)
 	return i, err
 }
@@ -86,32 +160,213 @@ func (q *Queries) CreateUserAuth(ctx context.Context, arg CreateUserAuthParams)
 }
 
 const createUserSession = `-- name: CreateUserSession :one
-INSERT INTO user_session (user_id, token, expires_at) VALUES ($1, $2, $3) RETURNING id, user_id, impersonator_id, token, expires_at, user_expired, created_at, updated_at, version
+INSERT INTO user_session (user_id, token_hash, expires_at, absolute_expires_at, impersonator_id, user_agent, ip_address, two_factor_pending) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, user_id, impersonator_id, expires_at, user_expired, created_at, updated_at, version, user_agent, ip_address, last_seen_at, token_hash, absolute_expires_at, two_factor_pending
 `
 
 type CreateUserSessionParams struct {
-	UserID    uuid.UUID          `json:"user_id"`
-	Token     string             `json:"token"`
-	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	UserID            uuid.UUID          `json:"user_id"`
+	TokenHash         string             `json:"token_hash"`
+	ExpiresAt         pgtype.Timestamptz `json:"expires_at"`
+	AbsoluteExpiresAt pgtype.Timestamptz `json:"absolute_expires_at"`
+	ImpersonatorID    *uuid.UUID         `json:"impersonator_id"`
+	UserAgent         *string            `json:"user_agent"`
+	IpAddress         *string            `json:"ip_address"`
+	TwoFactorPending  bool               `json:"two_factor_pending"`
 }
 
 func (q *Queries) CreateUserSession(ctx context.Context, arg CreateUserSessionParams) (UserSession, error) {
-	row := q.db.QueryRow(ctx, createUserSession, arg.UserID, arg.Token, arg.ExpiresAt)
+	row := q.db.QueryRow(ctx, createUserSession,
+		arg.UserID,
+		arg.TokenHash,
+		arg.ExpiresAt,
+		arg.AbsoluteExpiresAt,
+		arg.ImpersonatorID,
+		arg.UserAgent,
+		arg.IpAddress,
+		arg.TwoFactorPending,
+	)
 	var i UserSession
 	err := row.Scan(
 		&i.ID,
 		&i.UserID,
 		&i.ImpersonatorID,
-		&i.Token,
 		&i.ExpiresAt,
 		&i.UserExpired,
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastSeenAt,
+		&i.TokenHash,
+		&i.AbsoluteExpiresAt,
+		&i.TwoFactorPending,
 	)
 	return i, err
 }
 
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_code WHERE user_id = $1
+`
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteRecoveryCodes, userID)
+	return err
+}
+
+const deleteTwoFactor = `-- name: DeleteTwoFactor :exec
+DELETE FROM user_two_factor WHERE user_id = $1
+`
+
+func (q *Queries) DeleteTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, deleteTwoFactor, userID)
+	return err
+}
+
+const deleteUserAuth = `-- name: DeleteUserAuth :one
+DELETE FROM user_auth WHERE id = $1 AND user_id = $2
+AND (SELECT count(*) FROM user_auth WHERE user_id = $2) > 1
+RETURNING id, user_id, value, provider, provider_id, provider_data, active, created_at, updated_at, version
+`
+
+type DeleteUserAuthParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteUserAuth(ctx context.Context, arg DeleteUserAuthParams) (UserAuth, error) {
+	row := q.db.QueryRow(ctx, deleteUserAuth, arg.ID, arg.UserID)
+	var i UserAuth
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Value,
+		&i.Provider,
+		&i.ProviderID,
+		&i.ProviderData,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const deleteUserAuthsByUser = `-- name: DeleteUserAuthsByUser :many
+DELETE FROM user_auth WHERE user_id = $1
+RETURNING id, user_id, value, provider, provider_id, provider_data, active, created_at, updated_at, version
+`
+
+func (q *Queries) DeleteUserAuthsByUser(ctx context.Context, userID uuid.UUID) ([]UserAuth, error) {
+	rows, err := q.db.Query(ctx, deleteUserAuthsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserAuth{}
+	for rows.Next() {
+		var i UserAuth
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Value,
+			&i.Provider,
+			&i.ProviderID,
+			&i.ProviderData,
+			&i.Active,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const enableTwoFactor = `-- name: EnableTwoFactor :one
+UPDATE user_two_factor SET enabled_at = now(), last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NULL
+RETURNING user_id, secret, enabled_at, last_used_step, created_at
+`
+
+type EnableTwoFactorParams struct {
+	UserID       uuid.UUID `json:"user_id"`
+	LastUsedStep int64     `json:"last_used_step"`
+}
+
+func (q *Queries) EnableTwoFactor(ctx context.Context, arg EnableTwoFactorParams) (UserTwoFactor, error) {
+	row := q.db.QueryRow(ctx, enableTwoFactor, arg.UserID, arg.LastUsedStep)
+	var i UserTwoFactor
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const expireOtherUserSessions = `-- name: ExpireOtherUserSessions :many
+UPDATE user_session SET user_expired = TRUE, updated_at = now(), version = version + 1
+WHERE user_id = $1 AND id <> $2 AND user_expired = FALSE AND expires_at > now()
+RETURNING id, user_id, impersonator_id, expires_at, user_expired, created_at, updated_at, version, user_agent, ip_address, last_seen_at, token_hash, absolute_expires_at, two_factor_pending
+`
+
+type ExpireOtherUserSessionsParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+
+func (q *Queries) ExpireOtherUserSessions(ctx context.Context, arg ExpireOtherUserSessionsParams) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, expireOtherUserSessions, arg.UserID, arg.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserSession{}
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImpersonatorID,
+			&i.ExpiresAt,
+			&i.UserExpired,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastSeenAt,
+			&i.TokenHash,
+			&i.AbsoluteExpiresAt,
+			&i.TwoFactorPending,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const expirePasswordResetsByUser = `-- name: ExpirePasswordResetsByUser :exec
+UPDATE password_reset SET used_at = now() WHERE user_id = $1 AND used_at IS NULL
+`
+
+func (q *Queries) ExpirePasswordResetsByUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, expirePasswordResetsByUser, userID)
+	return err
+}
+
 const expireUserSession = `-- name: ExpireUserSession :exec
 UPDATE user_session SET user_expired = TRUE WHERE user_session.id = $1
 `
@@ -121,87 +376,289 @@ func (q *Queries) ExpireUserSession(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT users.id, users.given_name, users.family_name, users.email, users.email_verified, users.avatar_url, users.created_at, users.updated_at, users.version, user_auth.id, user_auth.user_id, user_auth.value, user_auth.provider, user_auth.provider_id, user_auth.provider_data, user_auth.active, user_auth.created_at, user_auth.updated_at, user_auth.version FROM users 
-JOIN user_auth ON users.id = user_auth.user_id
-WHERE users.email = $1
+const expireUserSessionForUser = `-- name: ExpireUserSessionForUser :one
+UPDATE user_session SET user_expired = TRUE, updated_at = now(), version = version + 1
+WHERE id = $1 AND user_id = $2 AND user_expired = FALSE
+RETURNING id, user_id, impersonator_id, expires_at, user_expired, created_at, updated_at, version, user_agent, ip_address, last_seen_at, token_hash, absolute_expires_at, two_factor_pending
 `
 
-type GetUserByEmailRow struct {
-	User     User     `json:"user"`
-	UserAuth UserAuth `json:"user_auth"`
+type ExpireUserSessionForUserParams struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
 }
 
-func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
-	row := q.db.QueryRow(ctx, getUserByEmail, email)
-	var i GetUserByEmailRow
+func (q *Queries) ExpireUserSessionForUser(ctx context.Context, arg ExpireUserSessionForUserParams) (UserSession, error) {
+	row := q.db.QueryRow(ctx, expireUserSessionForUser, arg.ID, arg.UserID)
+	var i UserSession
 	err := row.Scan(
-		&i.User.ID,
-		&i.User.GivenName,
-		&i.User.FamilyName,
-		&i.User.Email,
-		&i.User.EmailVerified,
-		&i.User.AvatarUrl,
-		&i.User.CreatedAt,
-		&i.User.UpdatedAt,
-		&i.User.Version,
-		&i.UserAuth.ID,
-		&i.UserAuth.UserID,
-		&i.UserAuth.Value,
-		&i.UserAuth.Provider,
-		&i.UserAuth.ProviderID,
-		&i.UserAuth.ProviderData,
-		&i.UserAuth.Active,
-		&i.UserAuth.CreatedAt,
-		&i.UserAuth.UpdatedAt,
-		&i.UserAuth.Version,
+		&i.ID,
+		&i.UserID,
+		&i.ImpersonatorID,
+		&i.ExpiresAt,
+		&i.UserExpired,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.UserAgent,
+		&i.IpAddress,
+		&i.LastSeenAt,
+		&i.TokenHash,
+		&i.AbsoluteExpiresAt,
+		&i.TwoFactorPending,
 	)
 	return i, err
 }
 
-const getUserByID = `-- name: GetUserByID :one
-SELECT users.id, users.given_name, users.family_name, users.email, users.email_verified, users.avatar_url, users.created_at, users.updated_at, users.version, user_auth.id, user_auth.user_id, user_auth.value, user_auth.provider, user_auth.provider_id, user_auth.provider_data, user_auth.active, user_auth.created_at, user_auth.updated_at, user_auth.version FROM users
-JOIN user_auth ON users.id = user_auth.user_id
-WHERE users.id = $1
+const expireUserSessionsByUser = `-- name: ExpireUserSessionsByUser :many
+UPDATE user_session SET user_expired = TRUE, updated_at = now(), version = version + 1
+WHERE user_id = $1 AND user_expired = FALSE AND expires_at > now()
+RETURNING id, user_id, impersonator_id, expires_at, user_expired, created_at, updated_at, version, user_agent, ip_address, last_seen_at, token_hash, absolute_expires_at, two_factor_pending
+`
+
+func (q *Queries) ExpireUserSessionsByUser(ctx context.Context, userID uuid.UUID) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, expireUserSessionsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserSession{}
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImpersonatorID,
+			&i.ExpiresAt,
+			&i.UserExpired,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastSeenAt,
+			&i.TokenHash,
+			&i.AbsoluteExpiresAt,
+			&i.TwoFactorPending,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getActiveUserSessions = `-- name: GetActiveUserSessions :many
+SELECT id, user_id, impersonator_id, expires_at, user_expired, created_at, updated_at, version, user_agent, ip_address, last_seen_at, token_hash, absolute_expires_at, two_factor_pending FROM user_session
+WHERE user_id = $1
+AND user_expired = FALSE
+AND expires_at > now()
+ORDER BY last_seen_at DESC
+`
+
+func (q *Queries) GetActiveUserSessions(ctx context.Context, userID uuid.UUID) ([]UserSession, error) {
+	rows, err := q.db.Query(ctx, getActiveUserSessions, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserSession{}
+	for rows.Next() {
+		var i UserSession
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.ImpersonatorID,
+			&i.ExpiresAt,
+			&i.UserExpired,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.UserAgent,
+			&i.IpAddress,
+			&i.LastSeenAt,
+			&i.TokenHash,
+			&i.AbsoluteExpiresAt,
+			&i.TwoFactorPending,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPasswordResetByTokenHash = `-- name: GetPasswordResetByTokenHash :one
+SELECT id, user_id, token_hash, expires_at, used_at, created_at FROM password_reset
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+`
+
+func (q *Queries) GetPasswordResetByTokenHash(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRow(ctx, getPasswordResetByTokenHash, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRolePolicies = `-- name: GetRolePolicies :many
+SELECT role, require_two_factor, updated_by, updated_at FROM role_policy ORDER BY role
+`
+
+func (q *Queries) GetRolePolicies(ctx context.Context) ([]RolePolicy, error) {
+	rows, err := q.db.Query(ctx, getRolePolicies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []RolePolicy{}
+	for rows.Next() {
+		var i RolePolicy
+		if err := rows.Scan(
+			&i.Role,
+			&i.RequireTwoFactor,
+			&i.UpdatedBy,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRolePolicy = `-- name: GetRolePolicy :one
+SELECT role, require_two_factor, updated_by, updated_at FROM role_policy WHERE role = $1
 `
 
-type GetUserByIDRow struct {
-	User     User     `json:"user"`
-	UserAuth UserAuth `json:"user_auth"`
+func (q *Queries) GetRolePolicy(ctx context.Context, role string) (RolePolicy, error) {
+	row := q.db.QueryRow(ctx, getRolePolicy, role)
+	var i RolePolicy
+	err := row.Scan(
+		&i.Role,
+		&i.RequireTwoFactor,
+		&i.UpdatedBy,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getTwoFactorByUserID = `-- name: GetTwoFactorByUserID :one
+SELECT user_id, secret, enabled_at, last_used_step, created_at FROM user_two_factor WHERE user_id = $1
+`
+
+func (q *Queries) GetTwoFactorByUserID(ctx context.Context, userID uuid.UUID) (UserTwoFactor, error) {
+	row := q.db.QueryRow(ctx, getTwoFactorByUserID, userID)
+	var i UserTwoFactor
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getUserAuthsByUserID = `-- name: GetUserAuthsByUserID :many
+SELECT id, user_id, value, provider, provider_id, provider_data, active, created_at, updated_at, version FROM user_auth WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) GetUserAuthsByUserID(ctx context.Context, userID uuid.UUID) ([]UserAuth, error) {
+	rows, err := q.db.Query(ctx, getUserAuthsByUserID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []UserAuth{}
+	for rows.Next() {
+		var i UserAuth
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Value,
+			&i.Provider,
+			&i.ProviderID,
+			&i.ProviderData,
+			&i.Active,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role FROM users WHERE email = $1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.GivenName,
+		&i.FamilyName,
+		&i.Email,
+		&i.EmailVerified,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.Role,
+	)
+	return i, err
 }
 
-func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role FROM users WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 	row := q.db.QueryRow(ctx, getUserByID, id)
-	var i GetUserByIDRow
+	var i User
 	err := row.Scan(
-		&i.User.ID,
-		&i.User.GivenName,
-		&i.User.FamilyName,
-		&i.User.Email,
-		&i.User.EmailVerified,
-		&i.User.AvatarUrl,
-		&i.User.CreatedAt,
-		&i.User.UpdatedAt,
-		&i.User.Version,
-		&i.UserAuth.ID,
-		&i.UserAuth.UserID,
-		&i.UserAuth.Value,
-		&i.UserAuth.Provider,
-		&i.UserAuth.ProviderID,
-		&i.UserAuth.ProviderData,
-		&i.UserAuth.Active,
-		&i.UserAuth.CreatedAt,
-		&i.UserAuth.UpdatedAt,
-		&i.UserAuth.Version,
+		&i.ID,
+		&i.GivenName,
+		&i.FamilyName,
+		&i.Email,
+		&i.EmailVerified,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserByProviderID = `-- name: GetUserByProviderID :one
-SELECT users.id, users.given_name, users.family_name, users.email, users.email_verified, users.avatar_url, users.created_at, users.updated_at, users.version, user_auth.id, user_auth.user_id, user_auth.value, user_auth.provider, user_auth.provider_id, user_auth.provider_data, user_auth.active, user_auth.created_at, user_auth.updated_at, user_auth.version FROM users 
-JOIN user_auth ON users.id = user_auth.user_id
-WHERE user_auth.provider_id = $1
-AND user_auth.provider = $2
+SELECT id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role FROM users WHERE id = (
+  SELECT user_id FROM user_auth
+  WHERE user_auth.provider_id = $1
+  AND user_auth.provider = $2
+)
 `
 
 type GetUserByProviderIDParams struct {
@@ -209,54 +666,38 @@ type GetUserByProviderIDParams struct {
 	Provider   string `json:"provider"`
 }
 
-type GetUserByProviderIDRow struct {
-	User     User     `json:"user"`
-	UserAuth UserAuth `json:"user_auth"`
-}
-
-func (q *Queries) GetUserByProviderID(ctx context.Context, arg GetUserByProviderIDParams) (GetUserByProviderIDRow, error) {
+func (q *Queries) GetUserByProviderID(ctx context.Context, arg GetUserByProviderIDParams) (User, error) {
 	row := q.db.QueryRow(ctx, getUserByProviderID, arg.ProviderID, arg.Provider)
-	var i GetUserByProviderIDRow
+	var i User
 	err := row.Scan(
-		&i.User.ID,
-		&i.User.GivenName,
-		&i.User.FamilyName,
-		&i.User.Email,
-		&i.User.EmailVerified,
-		&i.User.AvatarUrl,
-		&i.User.CreatedAt,
-		&i.User.UpdatedAt,
-		&i.User.Version,
-		&i.UserAuth.ID,
-		&i.UserAuth.UserID,
-		&i.UserAuth.Value,
-		&i.UserAuth.Provider,
-		&i.UserAuth.ProviderID,
-		&i.UserAuth.ProviderData,
-		&i.UserAuth.Active,
-		&i.UserAuth.CreatedAt,
-		&i.UserAuth.UpdatedAt,
-		&i.UserAuth.Version,
+		&i.ID,
+		&i.GivenName,
+		&i.FamilyName,
+		&i.Email,
+		&i.EmailVerified,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserBySessionToken = `-- name: GetUserBySessionToken :one
-SELECT users.id, users.given_name, users.family_name, users.email, users.email_verified, users.avatar_url, users.created_at, users.updated_at, users.version, user_auth.id, user_auth.user_id, user_auth.value, user_auth.provider, user_auth.provider_id, user_auth.provider_data, user_auth.active, user_auth.created_at, user_auth.updated_at, user_auth.version, user_session.id, user_session.user_id, user_session.impersonator_id, user_session.token, user_session.expires_at, user_session.user_expired, user_session.created_at, user_session.updated_at, user_session.version FROM users 
-JOIN user_auth ON users.id = user_auth.user_id
+SELECT users.id, users.given_name, users.family_name, users.email, users.email_verified, users.avatar_url, users.created_at, users.updated_at, users.version, users.role, user_session.id, user_session.user_id, user_session.impersonator_id, user_session.expires_at, user_session.user_expired, user_session.created_at, user_session.updated_at, user_session.version, user_session.user_agent, user_session.ip_address, user_session.last_seen_at, user_session.token_hash, user_session.absolute_expires_at, user_session.two_factor_pending FROM users 
 JOIN user_session ON users.id = user_session.user_id
-WHERE user_session.token = $1
+WHERE user_session.token_hash = $1
 AND user_session.user_expired = FALSE
 `
 
 type GetUserBySessionTokenRow struct {
 	User        User        `json:"user"`
-	UserAuth    UserAuth    `json:"user_auth"`
 	UserSession UserSession `json:"user_session"`
 }
 
-func (q *Queries) GetUserBySessionToken(ctx context.Context, token string) (GetUserBySessionTokenRow, error) {
-	row := q.db.QueryRow(ctx, getUserBySessionToken, token)
+func (q *Queries) GetUserBySessionToken(ctx context.Context, tokenHash string) (GetUserBySessionTokenRow, error) {
+	row := q.db.QueryRow(ctx, getUserBySessionToken, tokenHash)
 	var i GetUserBySessionTokenRow
 	err := row.Scan(
 		&i.User.ID,
@@ -268,31 +709,159 @@ func (q *Queries) GetUserBySessionToken(ctx context.Context, token string) (GetU
 		&i.User.CreatedAt,
 		&i.User.UpdatedAt,
 		&i.User.Version,
-		&i.UserAuth.ID,
-		&i.UserAuth.UserID,
-		&i.UserAuth.Value,
-		&i.UserAuth.Provider,
-		&i.UserAuth.ProviderID,
-		&i.UserAuth.ProviderData,
-		&i.UserAuth.Active,
-		&i.UserAuth.CreatedAt,
-		&i.UserAuth.UpdatedAt,
-		&i.UserAuth.Version,
+		&i.User.Role,
 		&i.UserSession.ID,
 		&i.UserSession.UserID,
 		&i.UserSession.ImpersonatorID,
-		&i.UserSession.Token,
 		&i.UserSession.ExpiresAt,
 		&i.UserSession.UserExpired,
 		&i.UserSession.CreatedAt,
 		&i.UserSession.UpdatedAt,
 		&i.UserSession.Version,
+		&i.UserSession.UserAgent,
+		&i.UserSession.IpAddress,
+		&i.UserSession.LastSeenAt,
+		&i.UserSession.TokenHash,
+		&i.UserSession.AbsoluteExpiresAt,
+		&i.UserSession.TwoFactorPending,
 	)
 	return i, err
 }
 
+const getUsersByRole = `-- name: GetUsersByRole :many
+SELECT id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role FROM users WHERE role = ANY($1::text[]) ORDER BY email
+`
+
+func (q *Queries) GetUsersByRole(ctx context.Context, roles []string) ([]User, error) {
+	rows, err := q.db.Query(ctx, getUsersByRole, roles)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []User{}
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.GivenName,
+			&i.FamilyName,
+			&i.Email,
+			&i.EmailVerified,
+			&i.AvatarUrl,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Version,
+			&i.Role,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setRolePolicy = `-- name: SetRolePolicy :one
+INSERT INTO role_policy (role, require_two_factor, updated_by) VALUES ($1, $2, $3)
+ON CONFLICT (role) DO UPDATE SET require_two_factor = EXCLUDED.require_two_factor, updated_by = EXCLUDED.updated_by, updated_at = now()
+RETURNING role, require_two_factor, updated_by, updated_at
+`
+
+type SetRolePolicyParams struct {
+	Role             string     `json:"role"`
+	RequireTwoFactor bool       `json:"require_two_factor"`
+	UpdatedBy        *uuid.UUID `json:"updated_by"`
+}
+
+func (q *Queries) SetRolePolicy(ctx context.Context, arg SetRolePolicyParams) (RolePolicy, error) {
+	row := q.db.QueryRow(ctx, setRolePolicy, arg.Role, arg.RequireTwoFactor, arg.UpdatedBy)
+	var i RolePolicy
+	err := row.Scan(
+		&i.Role,
+		&i.RequireTwoFactor,
+		&i.UpdatedBy,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const setUserEmailVerified = `-- name: SetUserEmailVerified :one
+UPDATE users SET email_verified = TRUE, updated_at = now(), version = version + 1
+WHERE id = $1 AND email = $2
+RETURNING id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role
+`
+
+type SetUserEmailVerifiedParams struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+}
+
+func (q *Queries) SetUserEmailVerified(ctx context.Context, arg SetUserEmailVerifiedParams) (User, error) {
+	row := q.db.QueryRow(ctx, setUserEmailVerified, arg.ID, arg.Email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.GivenName,
+		&i.FamilyName,
+		&i.Email,
+		&i.EmailVerified,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.Role,
+	)
+	return i, err
+}
+
+const startTwoFactor = `-- name: StartTwoFactor :one
+INSERT INTO user_two_factor (user_id, secret) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+WHERE user_two_factor.enabled_at IS NULL
+RETURNING user_id, secret, enabled_at, last_used_step, created_at
+`
+
+type StartTwoFactorParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Secret string    `json:"secret"`
+}
+
+func (q *Queries) StartTwoFactor(ctx context.Context, arg StartTwoFactorParams) (UserTwoFactor, error) {
+	row := q.db.QueryRow(ctx, startTwoFactor, arg.UserID, arg.Secret)
+	var i UserTwoFactor
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const touchUserSession = `-- name: TouchUserSession :exec
+UPDATE user_session SET
+  last_seen_at = now(),
+  ip_address = $1,
+  expires_at = LEAST($2::timestamptz, absolute_expires_at)
+WHERE id = $3
+`
+
+type TouchUserSessionParams struct {
+	IpAddress *string            `json:"ip_address"`
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	ID        uuid.UUID          `json:"id"`
+}
+
+func (q *Queries) TouchUserSession(ctx context.Context, arg TouchUserSessionParams) error {
+	_, err := q.db.Exec(ctx, touchUserSession, arg.IpAddress, arg.ExpiresAt, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
-UPDATE users SET given_name = $2, family_name = $3 WHERE id = $1 RETURNING id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version
+UPDATE users SET given_name = $2, family_name = $3 WHERE id = $1 RETURNING id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role
 `
 
 type UpdateUserParams struct {
@@ -314,6 +883,151 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.Version,
+		&i.Role,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :one
+UPDATE user_auth SET value = $2, updated_at = now(), version = version + 1
+WHERE user_id = $1 AND provider = 'password'
+RETURNING id, user_id, value, provider, provider_id, provider_data, active, created_at, updated_at, version
+`
+
+type UpdateUserPasswordParams struct {
+	UserID uuid.UUID `json:"user_id"`
+	Value  string    `json:"value"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (UserAuth, error) {
+	row := q.db.QueryRow(ctx, updateUserPassword, arg.UserID, arg.Value)
+	var i UserAuth
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Value,
+		&i.Provider,
+		&i.ProviderID,
+		&i.ProviderData,
+		&i.Active,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const updateUserRole = `-- name: UpdateUserRole :one
+UPDATE users SET role = $2, updated_at = now(), version = version + 1 WHERE id = $1 RETURNING id, given_name, family_name, email, email_verified, avatar_url, created_at, updated_at, version, role
+`
+
+type UpdateUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) UpdateUserRole(ctx context.Context, arg UpdateUserRoleParams) (User, error) {
+	row := q.db.QueryRow(ctx, updateUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.GivenName,
+		&i.FamilyName,
+		&i.Email,
+		&i.EmailVerified,
+		&i.AvatarUrl,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Version,
+		&i.Role,
+	)
+	return i, err
+}
+
+const useLoginLink = `-- name: UseLoginLink :one
+UPDATE login_link SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING id, email, token_hash, expires_at, used_at, created_at
+`
+
+func (q *Queries) UseLoginLink(ctx context.Context, tokenHash string) (LoginLink, error) {
+	row := q.db.QueryRow(ctx, useLoginLink, tokenHash)
+	var i LoginLink
+	err := row.Scan(
+		&i.ID,
+		&i.Email,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const usePasswordReset = `-- name: UsePasswordReset :one
+UPDATE password_reset SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING id, user_id, token_hash, expires_at, used_at, created_at
+`
+
+func (q *Queries) UsePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRow(ctx, usePasswordReset, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :one
+UPDATE user_recovery_code SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+RETURNING id, user_id, code_hash, used_at, created_at
+`
+
+type UseRecoveryCodeParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	CodeHash string    `json:"code_hash"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (UserRecoveryCode, error) {
+	row := q.db.QueryRow(ctx, useRecoveryCode, arg.UserID, arg.CodeHash)
+	var i UserRecoveryCode
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.CodeHash,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useTwoFactorStep = `-- name: UseTwoFactorStep :one
+UPDATE user_two_factor SET last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+RETURNING user_id, secret, enabled_at, last_used_step, created_at
+`
+
+type UseTwoFactorStepParams struct {
+	UserID       uuid.UUID `json:"user_id"`
+	LastUsedStep int64     `json:"last_used_step"`
+}
+
+func (q *Queries) UseTwoFactorStep(ctx context.Context, arg UseTwoFactorStepParams) (UserTwoFactor, error) {
+	row := q.db.QueryRow(ctx, useTwoFactorStep, arg.UserID, arg.LastUsedStep)
+	var i UserTwoFactor
+	err := row.Scan(
+		&i.UserID,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
 	)
 	return i, err
 }
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type NotificationRepository struct {
+	utils   ServicesUtils
+	DB      *pgxpool.Pool
+	queries *models.Queries
+}
+
+func NewNotificationRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries) *NotificationRepository {
+	return &NotificationRepository{
+		utils:   utils,
+		DB:      db,
+		queries: queries,
+	}
+}
+
+type CreateNotificationArgs struct {
+	UserID  uuid.UUID
+	LinkID  *uuid.UUID
+	Kind    string
+	Message string
+}
+
+func (repo *NotificationRepository) CreateNotification(ctx context.Context, args CreateNotificationArgs) (*entities.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.CreateNotification(ctx, models.CreateNotificationParams{
+		UserID:  args.UserID,
+		LinkID:  args.LinkID,
+		Kind:    args.Kind,
+		Message: args.Message,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating notification")
+		return nil, err
+	}
+
+	notification := repo.modelToEntity(row)
+
+	return &notification, nil
+}
+
+func (repo *NotificationRepository) GetUnreadNotificationsByUserID(ctx context.Context, userID uuid.UUID) ([]*entities.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetUnreadNotificationsByUserID(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Str("userID", userID.String()).Msg("Error getting unread notifications")
+		return nil, err
+	}
+
+	notifications := []*entities.Notification{}
+
+	for _, row := range rows {
+		notification := repo.modelToEntity(row)
+		notifications = append(notifications, &notification)
+	}
+
+	return notifications, nil
+}
+
+func (repo *NotificationRepository) MarkNotificationRead(ctx context.Context, notificationID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	err := repo.queries.MarkNotificationRead(ctx, models.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: userID,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error marking notification read")
+		return err
+	}
+
+	return nil
+}
+
+func (repo *NotificationRepository) modelToEntity(model models.UserNotification) entities.Notification {
+	return entities.Notification{
+		ID:        model.ID,
+		UserID:    model.UserID,
+		LinkID:    model.LinkID,
+		Kind:      model.Kind,
+		Message:   model.Message,
+		Read:      model.Read,
+		CreatedAt: model.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,180 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type QuarantineRepository struct {
+	utils          ServicesUtils
+	DB             *pgxpool.Pool
+	queries        *models.Queries
+	linkRepository *LinkRepository
+}
+
+func NewQuarantineRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries, linkRepo *LinkRepository) *QuarantineRepository {
+	return &QuarantineRepository{
+		utils:          utils,
+		DB:             db,
+		queries:        queries,
+		linkRepository: linkRepo,
+	}
+}
+
+type CreateLinkQuarantineArgs struct {
+	LinkID     uuid.UUID
+	Source     string
+	Reason     string
+	ReporterID *uuid.UUID
+}
+
+func (repo *QuarantineRepository) CreateLinkQuarantine(ctx context.Context, args CreateLinkQuarantineArgs) (*entities.LinkQuarantine, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.CreateLinkQuarantine(ctx, models.CreateLinkQuarantineParams{
+		LinkID:     args.LinkID,
+		Source:     args.Source,
+		Reason:     args.Reason,
+		ReporterID: args.ReporterID,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link quarantine")
+		return nil, err
+	}
+
+	quarantine := repo.modelToEntity(row)
+
+	return &quarantine, nil
+}
+
+func (repo *QuarantineRepository) GetLinkQuarantineByID(ctx context.Context, quarantineID uuid.UUID) (*entities.LinkQuarantine, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetLinkQuarantineByID(ctx, quarantineID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting link quarantine by ID")
+			return nil, err
+		}
+	}
+
+	quarantine := repo.modelToEntity(row)
+
+	return &quarantine, nil
+}
+
+// GetPendingLinkQuarantines returns the review queue, oldest first. Only the
+// quarantine and link are populated on each review.
+func (repo *QuarantineRepository) GetPendingLinkQuarantines(ctx context.Context) ([]*entities.QuarantineReview, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetPendingLinkQuarantines(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting pending link quarantines")
+		return nil, err
+	}
+
+	reviews := []*entities.QuarantineReview{}
+
+	for _, row := range rows {
+		quarantine := repo.modelToEntity(row.LinkQuarantine)
+		link := repo.linkRepository.modelToEntity(row.LinkRedirect)
+		reviews = append(reviews, &entities.QuarantineReview{
+			Quarantine: &quarantine,
+			Link:       &link,
+		})
+	}
+
+	return reviews, nil
+}
+
+type ResolveLinkQuarantineArgs struct {
+	ID          uuid.UUID
+	Status      string
+	DecidedBy   uuid.UUID
+	Active      *bool
+	Quarantined *bool
+	Reason      *string
+}
+
+// ResolveLinkQuarantine records the decision on a pending review and applies
+// it to the link in the same transaction, so the two can't disagree.
+func (repo *QuarantineRepository) ResolveLinkQuarantine(ctx context.Context, args ResolveLinkQuarantineArgs) (*entities.LinkQuarantine, *entities.LinkEntity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction resolving link quarantine")
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	row, err := qtx.ResolveLinkQuarantine(ctx, models.ResolveLinkQuarantineParams{
+		ID:        args.ID,
+		Status:    args.Status,
+		DecidedBy: &args.DecidedBy,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil, ErrNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error resolving link quarantine")
+			return nil, nil, err
+		}
+	}
+
+	linkRow, err := repo.linkRepository.updateLink(ctx, qtx, UpdateLinkArgs{
+		ID:          row.LinkID,
+		UpdatedBy:   args.DecidedBy,
+		Active:      args.Active,
+		Quarantined: args.Quarantined,
+		Reason:      args.Reason,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, nil, err
+	}
+
+	quarantine := repo.modelToEntity(row)
+	link := repo.linkRepository.modelToEntity(linkRow)
+
+	return &quarantine, &link, nil
+}
+
+func (repo *QuarantineRepository) modelToEntity(model models.LinkQuarantine) entities.LinkQuarantine {
+	var decidedAt *time.Time
+	if model.DecidedAt.Valid {
+		decidedAt = &model.DecidedAt.Time
+	}
+
+	return entities.LinkQuarantine{
+		ID:         model.ID,
+		LinkID:     model.LinkID,
+		Source:     model.Source,
+		Reason:     model.Reason,
+		ReporterID: model.ReporterID,
+		Status:     model.Status,
+		DecidedBy:  model.DecidedBy,
+		DecidedAt:  decidedAt,
+		CreatedAt:  model.CreatedAt.Time,
+	}
+}
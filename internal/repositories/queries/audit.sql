@@ -0,0 +1,12 @@
+-- name: CreateAuditEvent :one
+INSERT INTO audit_event (action, actor_id, impersonator_id, target_type, target_id, ip_address, user_agent, correlation_id, metadata)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+
+-- name: GetAuditEvents :many
+SELECT * FROM audit_event
+WHERE (sqlc.narg(action)::text IS NULL OR action = sqlc.narg(action))
+AND (sqlc.narg(actor_id)::uuid IS NULL OR actor_id = sqlc.narg(actor_id) OR impersonator_id = sqlc.narg(actor_id))
+AND (sqlc.narg(created_after)::timestamptz IS NULL OR created_at >= sqlc.narg(created_after))
+AND (sqlc.narg(created_before)::timestamptz IS NULL OR created_at < sqlc.narg(created_before))
+ORDER BY created_at DESC
+LIMIT sqlc.arg(row_limit);
\ No newline at end of file
@@ -1,5 +1,51 @@
 -- name: GetBlockedDomain :one
-SELECT * FROM blocked_domain WHERE domain = $1;
+SELECT * FROM blocked_domain WHERE lower(domain) = ANY(sqlc.arg(domains)::text[])
+AND (expires_at IS NULL OR expires_at > now()) LIMIT 1;
 
 -- name: GetBlockedUser :one
-SELECT * FROM blocked_user WHERE user_id = $1;
\ No newline at end of file
+SELECT * FROM blocked_user WHERE user_id = $1
+AND (expires_at IS NULL OR expires_at > now());
+
+-- name: GetBlockedUsers :many
+SELECT * FROM blocked_user ORDER BY created_at DESC;
+
+-- name: CreateBlockedUser :exec
+INSERT INTO blocked_user (user_id, reason, expires_at, created_by) VALUES ($1, $2, $3, $4)
+ON CONFLICT (user_id) DO UPDATE SET
+  reason = EXCLUDED.reason,
+  expires_at = EXCLUDED.expires_at,
+  created_by = EXCLUDED.created_by,
+  created_at = now();
+
+-- name: DeleteBlockedUser :execrows
+DELETE FROM blocked_user WHERE user_id = $1;
+
+-- name: GetBlockedPatterns :many
+SELECT * FROM blocked_pattern;
+
+-- name: CreateBlockedDomain :exec
+INSERT INTO blocked_domain (domain, reason, expires_at, created_by) VALUES ($1, $2, $3, $4)
+ON CONFLICT (domain) DO UPDATE SET
+  source = EXCLUDED.source,
+  reason = EXCLUDED.reason,
+  expires_at = EXCLUDED.expires_at,
+  created_by = EXCLUDED.created_by;
+
+-- name: DeleteBlockedDomain :execrows
+DELETE FROM blocked_domain WHERE domain = $1;
+
+-- name: GetBlockedDomainsBySource :many
+SELECT * FROM blocked_domain WHERE source = $1 ORDER BY domain;
+
+-- name: CountBlockedDomainsBySource :many
+SELECT source, count(*) AS domains FROM blocked_domain GROUP BY source ORDER BY source;
+
+-- name: UpsertBlockedDomain :execrows
+INSERT INTO blocked_domain (domain, source, first_seen, last_seen) VALUES ($1, $2, $3, $4)
+ON CONFLICT (domain) DO UPDATE SET
+  first_seen = LEAST(blocked_domain.first_seen, EXCLUDED.first_seen),
+  last_seen = GREATEST(blocked_domain.last_seen, EXCLUDED.last_seen)
+WHERE blocked_domain.source = EXCLUDED.source;
+
+-- name: DeleteBlockedDomains :exec
+DELETE FROM blocked_domain WHERE source = $1 AND domain = ANY(sqlc.arg(domains)::text[]);
\ No newline at end of file
@@ -5,21 +5,50 @@ SELECT * FROM link_redirect WHERE id = $1;
 SELECT * FROM link_redirect WHERE shortened_url = $1;
 
 -- name: GetLinksByUserID :many
-SELECT * FROM link_redirect WHERE created_by = $1 OR updated_by = $1;
+SELECT * FROM link_redirect WHERE owner_id = $1 AND workspace_id IS NULL;
+
+-- name: GetLinksByOwnerID :many
+SELECT * FROM link_redirect WHERE owner_id = $1;
+
+-- name: GetLinksByWorkspaceID :many
+SELECT * FROM link_redirect WHERE workspace_id = $1;
 
 -- name: CreateLink :one
-INSERT INTO link_redirect (link_url, shortened_url, created_by, updated_by) 
-VALUES ($1, $2, $3, $4) RETURNING *;
+INSERT INTO link_redirect (link_url, shortened_url, created_by, updated_by, quarantined, workspace_id, owner_id) 
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
 
 -- name: UpdateLink :one
 UPDATE link_redirect SET 
 link_url = COALESCE(sqlc.narg(link_url), link_url), 
 active = COALESCE(sqlc.narg(active), active),  
+quarantined = COALESCE(sqlc.narg(quarantined), quarantined), 
 updated_by = sqlc.arg(updated_by), 
 updated_at = now(), 
 version = version + 1 
 WHERE id = sqlc.arg(id) RETURNING *;
 
 -- name: CreateLinkHistory :one
-INSERT INTO link_redirect_history (link_id, link_url, active, quarantined, created_by, updated_by)
-VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
\ No newline at end of file
+INSERT INTO link_redirect_history (link_id, link_url, active, quarantined, created_by, updated_by, reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
+
+-- name: GetActiveLinks :many
+SELECT * FROM link_redirect WHERE active = TRUE AND quarantined = FALSE;
+
+-- name: SetLinkBroken :exec
+UPDATE link_redirect SET broken = $2 WHERE id = $1;
+
+
+-- name: GetActiveLinksByOwner :many
+SELECT * FROM link_redirect WHERE owner_id = $1 AND active = TRUE;
+
+-- name: TransferLink :one
+UPDATE link_redirect SET 
+owner_id = $2, 
+workspace_id = $3, 
+updated_by = $4, 
+updated_at = now(), 
+version = version + 1 
+WHERE id = $1 RETURNING *;
+
+-- name: CountActiveLinksCreatedBy :one
+SELECT count(*) FROM link_redirect WHERE created_by = $1 AND active = TRUE;
\ No newline at end of file
@@ -0,0 +1,6 @@
+-- name: CreateLinkHealthCheck :one
+INSERT INTO link_health_check (link_id, status_code, latency_ms, redirect_chain, tls_valid, healthy, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
+
+-- name: GetRecentLinkHealthChecks :many
+SELECT * FROM link_health_check WHERE link_id = $1 ORDER BY checked_at DESC LIMIT $2;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- name: CreateNotification :one
+INSERT INTO user_notification (user_id, link_id, kind, message) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetUnreadNotificationsByUserID :many
+SELECT * FROM user_notification WHERE user_id = $1 AND read = FALSE ORDER BY created_at DESC;
+
+-- name: MarkNotificationRead :exec
+UPDATE user_notification SET read = TRUE WHERE id = $1 AND user_id = $2;
\ No newline at end of file
@@ -0,0 +1,18 @@
+-- name: CreateLinkQuarantine :one
+INSERT INTO link_quarantine (link_id, source, reason, reporter_id) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetLinkQuarantineByID :one
+SELECT * FROM link_quarantine WHERE id = $1;
+
+-- name: GetPendingLinkQuarantines :many
+SELECT sqlc.embed(link_quarantine), sqlc.embed(link_redirect) FROM link_quarantine
+JOIN link_redirect ON link_redirect.id = link_quarantine.link_id
+WHERE link_quarantine.status = 'pending'
+ORDER BY link_quarantine.created_at;
+
+-- name: ResolveLinkQuarantine :one
+UPDATE link_quarantine SET 
+status = $2, 
+decided_by = $3, 
+decided_at = now() 
+WHERE id = $1 AND status = 'pending' RETURNING *;
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- name: CreateLinkReport :one
+INSERT INTO link_report (link_id, reporter_id, ip_address, category, comment) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (link_id, ip_address) DO NOTHING RETURNING *;
+
+-- name: CountLinkReports :one
+SELECT count(*) FROM link_report WHERE link_id = $1
+AND created_at > coalesce((SELECT max(decided_at) FROM link_quarantine WHERE link_quarantine.link_id = $1), '-infinity');
\ No newline at end of file
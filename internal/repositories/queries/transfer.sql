@@ -0,0 +1,29 @@
+-- name: CreateLinkTransfer :one
+INSERT INTO link_transfer (link_id, from_user_id, to_user_id, to_workspace_id) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetLinkTransferByID :one
+SELECT * FROM link_transfer WHERE id = $1;
+
+-- name: GetIncomingLinkTransfers :many
+SELECT sqlc.embed(link_transfer), sqlc.embed(link_redirect) FROM link_transfer
+JOIN link_redirect ON link_redirect.id = link_transfer.link_id
+WHERE link_transfer.status = 'pending' AND (
+  link_transfer.to_user_id = $1 OR link_transfer.to_workspace_id IN (
+    SELECT workspace_id FROM workspace_member WHERE user_id = $1 AND role = 'owner'
+  )
+)
+ORDER BY link_transfer.created_at;
+
+-- name: GetOutgoingLinkTransfers :many
+SELECT sqlc.embed(link_transfer), sqlc.embed(link_redirect) FROM link_transfer
+JOIN link_redirect ON link_redirect.id = link_transfer.link_id
+WHERE link_transfer.status = 'pending' AND link_transfer.from_user_id = $1
+ORDER BY link_transfer.created_at;
+
+-- name: DecideLinkTransfer :execrows
+UPDATE link_transfer SET status = $2, decided_by = $3, decided_at = now()
+WHERE id = $1 AND status = 'pending';
+
+-- name: CancelLinkTransfersByLink :exec
+UPDATE link_transfer SET status = 'cancelled', decided_at = now()
+WHERE link_id = $1 AND status = 'pending';
\ No newline at end of file
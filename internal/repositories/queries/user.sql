@@ -1,30 +1,43 @@
 -- name: GetUserByID :one
-SELECT sqlc.embed(users), sqlc.embed(user_auth) FROM users
-JOIN user_auth ON users.id = user_auth.user_id
-WHERE users.id = $1;
+SELECT * FROM users WHERE id = $1;
 
 -- name: GetUserByEmail :one
-SELECT sqlc.embed(users), sqlc.embed(user_auth) FROM users 
-JOIN user_auth ON users.id = user_auth.user_id
-WHERE users.email = $1;
+SELECT * FROM users WHERE email = $1;
 
 -- name: GetUserByProviderID :one
-SELECT sqlc.embed(users), sqlc.embed(user_auth) FROM users 
-JOIN user_auth ON users.id = user_auth.user_id
-WHERE user_auth.provider_id = $1
-AND user_auth.provider = $2;
+SELECT * FROM users WHERE id = (
+  SELECT user_id FROM user_auth
+  WHERE user_auth.provider_id = $1
+  AND user_auth.provider = $2
+);
 
 -- name: GetUserBySessionToken :one
-SELECT sqlc.embed(users), sqlc.embed(user_auth), sqlc.embed(user_session) FROM users 
-JOIN user_auth ON users.id = user_auth.user_id
+SELECT sqlc.embed(users), sqlc.embed(user_session) FROM users 
 JOIN user_session ON users.id = user_session.user_id
-WHERE user_session.token = $1
+WHERE user_session.token_hash = $1
 AND user_session.user_expired = FALSE;
 
+-- name: GetUserAuthsByUserID :many
+SELECT * FROM user_auth WHERE user_id = $1 ORDER BY created_at;
+
+-- name: DeleteUserAuth :one
+DELETE FROM user_auth WHERE id = $1 AND user_id = $2
+AND (SELECT count(*) FROM user_auth WHERE user_id = $2) > 1
+RETURNING *;
+
+-- name: DeleteUserAuthsByUser :many
+DELETE FROM user_auth WHERE user_id = $1
+RETURNING *;
+
 -- name: CreateUser :one
 INSERT INTO users (given_name, family_name, email, email_verified, avatar_url) 
 VALUES (sqlc.narg(given_name), sqlc.narg(family_name), sqlc.arg(email), sqlc.arg(email_verified)::boolean, sqlc.narg(avatar_url)) RETURNING *;
  
+-- name: SetUserEmailVerified :one
+UPDATE users SET email_verified = TRUE, updated_at = now(), version = version + 1
+WHERE id = $1 AND email = $2
+RETURNING *;
+
 -- name: UpdateUser :one
 UPDATE users SET given_name = $2, family_name = $3 WHERE id = $1 RETURNING *;
 
@@ -33,7 +46,117 @@ INSERT INTO user_auth (user_id, value, provider, provider_id, provider_data)
 VALUES (sqlc.arg(user_id), sqlc.arg(value), sqlc.arg(provider), sqlc.arg(provider_id), sqlc.arg(provider_data)) RETURNING *;
 
 -- name: CreateUserSession :one
-INSERT INTO user_session (user_id, token, expires_at) VALUES ($1, $2, $3) RETURNING *;
+INSERT INTO user_session (user_id, token_hash, expires_at, absolute_expires_at, impersonator_id, user_agent, ip_address, two_factor_pending) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING *;
 
 -- name: ExpireUserSession :exec
-UPDATE user_session SET user_expired = TRUE WHERE user_session.id = $1;
\ No newline at end of file
+UPDATE user_session SET user_expired = TRUE WHERE user_session.id = $1;
+
+-- name: GetActiveUserSessions :many
+SELECT * FROM user_session
+WHERE user_id = $1
+AND user_expired = FALSE
+AND expires_at > now()
+ORDER BY last_seen_at DESC;
+
+-- name: TouchUserSession :exec
+UPDATE user_session SET
+  last_seen_at = now(),
+  ip_address = sqlc.narg(ip_address),
+  expires_at = LEAST(sqlc.arg(expires_at)::timestamptz, absolute_expires_at)
+WHERE id = sqlc.arg(id);
+
+-- name: ExpireUserSessionForUser :one
+UPDATE user_session SET user_expired = TRUE, updated_at = now(), version = version + 1
+WHERE id = $1 AND user_id = $2 AND user_expired = FALSE
+RETURNING *;
+
+-- name: ExpireUserSessionsByUser :many
+UPDATE user_session SET user_expired = TRUE, updated_at = now(), version = version + 1
+WHERE user_id = $1 AND user_expired = FALSE AND expires_at > now()
+RETURNING *;
+
+-- name: ExpireOtherUserSessions :many
+UPDATE user_session SET user_expired = TRUE, updated_at = now(), version = version + 1
+WHERE user_id = $1 AND id <> $2 AND user_expired = FALSE AND expires_at > now()
+RETURNING *;
+
+-- name: UpdateUserPassword :one
+UPDATE user_auth SET value = $2, updated_at = now(), version = version + 1
+WHERE user_id = $1 AND provider = 'password'
+RETURNING *;
+
+-- name: CreatePasswordReset :one
+INSERT INTO password_reset (user_id, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetPasswordResetByTokenHash :one
+SELECT * FROM password_reset
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now();
+
+-- name: UsePasswordReset :one
+UPDATE password_reset SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: ExpirePasswordResetsByUser :exec
+UPDATE password_reset SET used_at = now() WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: CreateLoginLink :one
+INSERT INTO login_link (email, token_hash, expires_at) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: UseLoginLink :one
+UPDATE login_link SET used_at = now()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: GetTwoFactorByUserID :one
+SELECT * FROM user_two_factor WHERE user_id = $1;
+
+-- name: StartTwoFactor :one
+INSERT INTO user_two_factor (user_id, secret) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = now()
+WHERE user_two_factor.enabled_at IS NULL
+RETURNING *;
+
+-- name: EnableTwoFactor :one
+UPDATE user_two_factor SET enabled_at = now(), last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NULL
+RETURNING *;
+
+-- name: UseTwoFactorStep :one
+UPDATE user_two_factor SET last_used_step = $2
+WHERE user_id = $1 AND enabled_at IS NOT NULL AND last_used_step < $2
+RETURNING *;
+
+-- name: DeleteTwoFactor :exec
+DELETE FROM user_two_factor WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO user_recovery_code (user_id, code_hash) VALUES ($1, $2);
+
+-- name: UseRecoveryCode :one
+UPDATE user_recovery_code SET used_at = now()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+RETURNING *;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT count(*) FROM user_recovery_code WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM user_recovery_code WHERE user_id = $1;
+
+-- name: GetRolePolicies :many
+SELECT * FROM role_policy ORDER BY role;
+
+-- name: GetRolePolicy :one
+SELECT * FROM role_policy WHERE role = $1;
+
+-- name: SetRolePolicy :one
+INSERT INTO role_policy (role, require_two_factor, updated_by) VALUES ($1, $2, $3)
+ON CONFLICT (role) DO UPDATE SET require_two_factor = EXCLUDED.require_two_factor, updated_by = EXCLUDED.updated_by, updated_at = now()
+RETURNING *;
+
+-- name: GetUsersByRole :many
+SELECT * FROM users WHERE role = ANY(sqlc.arg(roles)::text[]) ORDER BY email;
+
+-- name: UpdateUserRole :one
+UPDATE users SET role = $2, updated_at = now(), version = version + 1 WHERE id = $1 RETURNING *;
\ No newline at end of file
@@ -0,0 +1,47 @@
+-- name: CreateWorkspace :one
+INSERT INTO workspace (name, created_by) VALUES ($1, $2) RETURNING *;
+
+-- name: GetWorkspaceByID :one
+SELECT * FROM workspace WHERE id = $1;
+
+-- name: GetWorkspacesByUserID :many
+SELECT sqlc.embed(workspace), sqlc.embed(workspace_member) FROM workspace
+JOIN workspace_member ON workspace.id = workspace_member.workspace_id
+WHERE workspace_member.user_id = $1
+ORDER BY workspace.name;
+
+-- name: GetWorkspaceMember :one
+SELECT * FROM workspace_member WHERE workspace_id = $1 AND user_id = $2;
+
+-- name: GetWorkspaceMembers :many
+SELECT * FROM workspace_member WHERE workspace_id = $1 ORDER BY created_at;
+
+-- name: UpsertWorkspaceMember :one
+INSERT INTO workspace_member (workspace_id, user_id, role) VALUES ($1, $2, $3)
+ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = EXCLUDED.role
+RETURNING *;
+
+-- name: DeleteWorkspaceMember :execrows
+DELETE FROM workspace_member WHERE workspace_id = $1 AND user_id = $2;
+
+-- name: CountWorkspaceOwners :one
+SELECT count(*) FROM workspace_member WHERE workspace_id = $1 AND role = 'owner';
+
+-- name: CreateWorkspaceInvite :one
+INSERT INTO workspace_invite (workspace_id, email, role, invited_by, expires_at) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetWorkspaceInviteByID :one
+SELECT * FROM workspace_invite WHERE id = $1;
+
+-- name: GetPendingWorkspaceInvites :many
+SELECT * FROM workspace_invite
+WHERE workspace_id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > now()
+ORDER BY created_at;
+
+-- name: AcceptWorkspaceInvite :execrows
+UPDATE workspace_invite SET accepted_by = $2, accepted_at = now()
+WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > now();
+
+-- name: RevokeWorkspaceInvite :execrows
+UPDATE workspace_invite SET revoked_at = now()
+WHERE id = $1 AND workspace_id = $2 AND accepted_at IS NULL AND revoked_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,80 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type ReportRepository struct {
+	utils   ServicesUtils
+	DB      *pgxpool.Pool
+	queries *models.Queries
+}
+
+func NewReportRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries) *ReportRepository {
+	return &ReportRepository{
+		utils:   utils,
+		DB:      db,
+		queries: queries,
+	}
+}
+
+type CreateLinkReportArgs struct {
+	LinkID     uuid.UUID
+	ReporterID *uuid.UUID
+	IPAddress  string
+	Category   string
+	Comment    *string
+}
+
+func (repo *ReportRepository) CreateLinkReport(ctx context.Context, args CreateLinkReportArgs) (*entities.LinkReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.CreateLinkReport(ctx, models.CreateLinkReportParams{
+		LinkID:     args.LinkID,
+		ReporterID: args.ReporterID,
+		IpAddress:  args.IPAddress,
+		Category:   args.Category,
+		Comment:    args.Comment,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrDuplicateReport
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link report")
+			return nil, err
+		}
+	}
+
+	return &entities.LinkReport{
+		ID:         row.ID,
+		LinkID:     row.LinkID,
+		ReporterID: row.ReporterID,
+		IPAddress:  row.IpAddress,
+		Category:   row.Category,
+		Comment:    row.Comment,
+		CreatedAt:  row.CreatedAt.Time,
+	}, nil
+}
+
+// CountLinkReports counts the reports made since a moderator last decided on
+// the link, so reports a review already dealt with don't count towards the
+// next quarantine.
+func (repo *ReportRepository) CountLinkReports(ctx context.Context, linkID uuid.UUID) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	count, err := repo.queries.CountLinkReports(ctx, linkID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error counting link reports")
+		return 0, err
+	}
+
+	return int(count), nil
+}
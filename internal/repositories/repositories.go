@@ -24,10 +24,17 @@ type ServicesUtils struct {
 }
 
 type Repositories struct {
-	utils             ServicesUtils
-	UserRepository    *UserRepository
-	LinkRepository    *LinkRepository
-	BlockedRepository *BlockedRepository
+	utils                  ServicesUtils
+	UserRepository         *UserRepository
+	LinkRepository         *LinkRepository
+	BlockedRepository      *BlockedRepository
+	LinkHealthRepository   *LinkHealthRepository
+	NotificationRepository *NotificationRepository
+	QuarantineRepository   *QuarantineRepository
+	ReportRepository       *ReportRepository
+	WorkspaceRepository    *WorkspaceRepository
+	TransferRepository     *TransferRepository
+	AuditRepository        *AuditRepository
 }
 
 func NewRepositories(db *pgxpool.Pool, cfg *config.Config, logger *zerolog.Logger, wg *sync.WaitGroup) Repositories {
@@ -41,11 +48,25 @@ func NewRepositories(db *pgxpool.Pool, cfg *config.Config, logger *zerolog.Logge
 	userRepo := NewUserRepository(utils, db, queries)
 	linkRepo := NewLinkRepository(utils, db, queries)
 	blockRepo := NewBlockedRepository(utils, db, queries)
+	linkHealthRepo := NewLinkHealthRepository(utils, db, queries)
+	notificationRepo := NewNotificationRepository(utils, db, queries)
+	quarantineRepo := NewQuarantineRepository(utils, db, queries, linkRepo)
+	reportRepo := NewReportRepository(utils, db, queries)
+	workspaceRepo := NewWorkspaceRepository(utils, db, queries)
+	transferRepo := NewTransferRepository(utils, db, queries, linkRepo)
+	auditRepo := NewAuditRepository(utils, db, queries)
 
 	return Repositories{
-		utils:             utils,
-		UserRepository:    userRepo,
-		LinkRepository:    linkRepo,
-		BlockedRepository: blockRepo,
+		utils:                  utils,
+		UserRepository:         userRepo,
+		LinkRepository:         linkRepo,
+		BlockedRepository:      blockRepo,
+		LinkHealthRepository:   linkHealthRepo,
+		NotificationRepository: notificationRepo,
+		QuarantineRepository:   quarantineRepo,
+		ReportRepository:       reportRepo,
+		WorkspaceRepository:    workspaceRepo,
+		TransferRepository:     transferRepo,
+		AuditRepository:        auditRepo,
 	}
 }
@@ -0,0 +1,322 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type TransferRepository struct {
+	utils          ServicesUtils
+	DB             *pgxpool.Pool
+	queries        *models.Queries
+	linkRepository *LinkRepository
+}
+
+func NewTransferRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries, linkRepository *LinkRepository) *TransferRepository {
+	return &TransferRepository{
+		utils:          utils,
+		DB:             db,
+		queries:        queries,
+		linkRepository: linkRepository,
+	}
+}
+
+type CreateLinkTransfersArgs struct {
+	LinkIDs       []uuid.UUID
+	FromUserID    uuid.UUID
+	ToUserID      *uuid.UUID
+	ToWorkspaceID *uuid.UUID
+}
+
+// CreateLinkTransfers offers every link to the same recipient. Either all the
+// offers are made or none are; a link that already has a pending transfer
+// fails the batch with entities.ErrTransferPending.
+func (repo *TransferRepository) CreateLinkTransfers(ctx context.Context, args CreateLinkTransfersArgs) ([]*entities.LinkTransfer, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction creating link transfers")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	transfers := []*entities.LinkTransfer{}
+
+	for _, linkID := range args.LinkIDs {
+		row, err := qtx.CreateLinkTransfer(ctx, models.CreateLinkTransferParams{
+			LinkID:        linkID,
+			FromUserID:    args.FromUserID,
+			ToUserID:      args.ToUserID,
+			ToWorkspaceID: args.ToWorkspaceID,
+		})
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) {
+				if pgErr.Code == "23505" && pgErr.ConstraintName == "link_transfer_pending_idx" {
+					return nil, entities.ErrTransferPending
+				}
+			}
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link transfer")
+			return nil, err
+		}
+		transfers = append(transfers, transferModelToEntity(row))
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+func (repo *TransferRepository) GetLinkTransferByID(ctx context.Context, transferID uuid.UUID) (*entities.LinkTransfer, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetLinkTransferByID(ctx, transferID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting link transfer by id")
+			return nil, err
+		}
+	}
+
+	return transferModelToEntity(row), nil
+}
+
+// GetIncomingLinkTransfers returns pending transfers to the user, and to the
+// workspaces they own.
+func (repo *TransferRepository) GetIncomingLinkTransfers(ctx context.Context, userID uuid.UUID) ([]*entities.LinkTransfer, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetIncomingLinkTransfers(ctx, &userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting incoming link transfers")
+		return nil, err
+	}
+
+	transfers := []*entities.LinkTransfer{}
+
+	for _, row := range rows {
+		transfer := transferModelToEntity(row.LinkTransfer)
+		link := repo.linkRepository.modelToEntity(row.LinkRedirect)
+		transfer.Link = &link
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+func (repo *TransferRepository) GetOutgoingLinkTransfers(ctx context.Context, userID uuid.UUID) ([]*entities.LinkTransfer, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetOutgoingLinkTransfers(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting outgoing link transfers")
+		return nil, err
+	}
+
+	transfers := []*entities.LinkTransfer{}
+
+	for _, row := range rows {
+		transfer := transferModelToEntity(row.LinkTransfer)
+		link := repo.linkRepository.modelToEntity(row.LinkRedirect)
+		transfer.Link = &link
+		transfers = append(transfers, transfer)
+	}
+
+	return transfers, nil
+}
+
+type AcceptLinkTransferArgs struct {
+	Transfer  *entities.LinkTransfer
+	DecidedBy uuid.UUID
+	Reason    string
+}
+
+// AcceptLinkTransfer hands the link over and records the change in the link's
+// history. A transfer into a workspace keeps the link's owner and moves the
+// link into the workspace; a transfer to a user makes them the owner of a
+// personal link.
+func (repo *TransferRepository) AcceptLinkTransfer(ctx context.Context, args AcceptLinkTransferArgs) (*entities.LinkEntity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction accepting link transfer")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	affected, err := qtx.DecideLinkTransfer(ctx, models.DecideLinkTransferParams{
+		ID:        args.Transfer.ID,
+		Status:    entities.TransferStatusAccepted,
+		DecidedBy: &args.DecidedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error accepting link transfer")
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, entities.ErrTransferUnavailable
+	}
+
+	linkRow, err := qtx.GetLinkByID(ctx, args.Transfer.LinkID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting transferred link")
+		return nil, err
+	}
+
+	ownerID := linkRow.OwnerID
+	if args.Transfer.ToUserID != nil {
+		ownerID = *args.Transfer.ToUserID
+	}
+
+	updatedLinkRow, err := repo.transferLink(ctx, qtx, linkRow, ownerID, args.Transfer.ToWorkspaceID, args.DecidedBy, args.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	link := repo.linkRepository.modelToEntity(updatedLinkRow)
+
+	return &link, nil
+}
+
+// DecideLinkTransfer declines or cancels a pending transfer.
+func (repo *TransferRepository) DecideLinkTransfer(ctx context.Context, transferID uuid.UUID, status string, decidedBy uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	affected, err := repo.queries.DecideLinkTransfer(ctx, models.DecideLinkTransferParams{
+		ID:        transferID,
+		Status:    status,
+		DecidedBy: &decidedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error deciding link transfer")
+		return err
+	}
+	if affected == 0 {
+		return entities.ErrTransferUnavailable
+	}
+
+	return nil
+}
+
+type ReassignLinksArgs struct {
+	FromUserID uuid.UUID
+	ToUserID   uuid.UUID
+	UpdatedBy  uuid.UUID
+	Reason     string
+}
+
+// ReassignLinks makes ToUserID the owner of every link FromUserID owns,
+// without asking either of them. Links stay in their workspaces, and pending
+// transfers of the links are cancelled. It returns how many were reassigned.
+func (repo *TransferRepository) ReassignLinks(ctx context.Context, args ReassignLinksArgs) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction reassigning links")
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	linkRows, err := qtx.GetLinksByOwnerID(ctx, args.FromUserID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting links to reassign")
+		return 0, err
+	}
+
+	for _, linkRow := range linkRows {
+		err = qtx.CancelLinkTransfersByLink(ctx, linkRow.ID)
+		if err != nil {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error cancelling link transfers")
+			return 0, err
+		}
+
+		_, err = repo.transferLink(ctx, qtx, linkRow, args.ToUserID, linkRow.WorkspaceID, args.UpdatedBy, args.Reason)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return 0, err
+	}
+
+	return len(linkRows), nil
+}
+
+func (repo *TransferRepository) transferLink(ctx context.Context, qtx *models.Queries, linkRow models.LinkRedirect, ownerID uuid.UUID, workspaceID *uuid.UUID, updatedBy uuid.UUID, reason string) (models.LinkRedirect, error) {
+	updatedLinkRow, err := qtx.TransferLink(ctx, models.TransferLinkParams{
+		ID:          linkRow.ID,
+		OwnerID:     ownerID,
+		WorkspaceID: workspaceID,
+		UpdatedBy:   updatedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error transferring link")
+		return models.LinkRedirect{}, err
+	}
+
+	_, err = qtx.CreateLinkHistory(ctx, models.CreateLinkHistoryParams{
+		LinkID:      linkRow.ID,
+		LinkUrl:     linkRow.LinkUrl,
+		Active:      linkRow.Active,
+		Quarantined: linkRow.Quarantined,
+		CreatedBy:   linkRow.CreatedBy,
+		UpdatedBy:   updatedBy,
+		Reason:      &reason,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link history")
+		return models.LinkRedirect{}, err
+	}
+
+	return updatedLinkRow, nil
+}
+
+func transferModelToEntity(model models.LinkTransfer) *entities.LinkTransfer {
+	return &entities.LinkTransfer{
+		ID:            model.ID,
+		LinkID:        model.LinkID,
+		FromUserID:    model.FromUserID,
+		ToUserID:      model.ToUserID,
+		ToWorkspaceID: model.ToWorkspaceID,
+		Status:        model.Status,
+		CreatedAt:     model.CreatedAt.Time,
+	}
+}
@@ -3,6 +3,7 @@ package repositories
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -12,8 +13,8 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/mcorrigan89/url_shortener/internal/entities"
 	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
 
-	"github.com/rs/xid"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -44,9 +45,7 @@ func (repo *UserRepository) GetUserByID(ctx context.Context, id uuid.UUID) (*ent
 		}
 	}
 
-	entity := entities.NewUserEntityFromModel(row.User, row.UserAuth)
-
-	return entity, nil
+	return repo.userWithIdentities(ctx, row)
 }
 
 func (repo *UserRepository) GetUserByEmail(ctx context.Context, email string) (*entities.User, error) {
@@ -62,15 +61,13 @@ func (repo *UserRepository) GetUserByEmail(ctx context.Context, email string) (*
 		}
 	}
 
-	entity := entities.NewUserEntityFromModel(row.User, row.UserAuth)
-
-	return entity, nil
+	return repo.userWithIdentities(ctx, row)
 }
 
 func (repo *UserRepository) GetUserBySessionToken(ctx context.Context, token string) (*entities.User, *entities.UserSession, error) {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
-	row, err := repo.queries.GetUserBySessionToken(ctx, token)
+	row, err := repo.queries.GetUserBySessionToken(ctx, tokens.Hash(token))
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil, entities.ErrUserNotFound
@@ -80,14 +77,11 @@ func (repo *UserRepository) GetUserBySessionToken(ctx context.Context, token str
 		}
 	}
 
-	userEntity := entities.NewUserEntityFromModel(row.User, row.UserAuth)
-	sessionEntity := entities.NewUserSession(entities.NewUserSessionArgs{
-		ID:            row.UserSession.ID,
-		UserID:        row.UserSession.UserID,
-		Token:         row.UserSession.Token,
-		ExpiresAt:     row.UserSession.ExpiresAt.Time,
-		ExpiredByUser: row.UserSession.UserExpired,
-	})
+	userEntity, err := repo.userWithIdentities(ctx, row.User)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessionEntity := sessionModelToEntity(row.UserSession)
 
 	return userEntity, sessionEntity, nil
 }
@@ -108,40 +102,133 @@ func (repo *UserRepository) GetUserByProviderID(ctx context.Context, providerID,
 		}
 	}
 
-	entity := entities.NewUserEntityFromModel(row.User, row.UserAuth)
+	return repo.userWithIdentities(ctx, row)
+}
+
+// userWithIdentities loads the identities of the user in model, so a user
+// with several logins is still one user.
+func (repo *UserRepository) userWithIdentities(ctx context.Context, model models.User) (*entities.User, error) {
+	rows, err := repo.queries.GetUserAuthsByUserID(ctx, model.ID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get user auths by user ID")
+		return nil, err
+	}
+
+	return entities.NewUserEntityFromModel(model, rows), nil
+}
 
-	return entity, nil
+type CreateUserIdentityArgs struct {
+	UserID       uuid.UUID
+	Provider     string
+	ProviderID   string
+	ProviderData []byte
+}
+
+// CreateUserIdentity links a provider account to an existing user.
+func (repo *UserRepository) CreateUserIdentity(ctx context.Context, args CreateUserIdentityArgs) (*entities.UserIdentity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.CreateUserAuth(ctx, models.CreateUserAuthParams{
+		UserID:       args.UserID,
+		Provider:     args.Provider,
+		ProviderID:   args.ProviderID,
+		ProviderData: args.ProviderData,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			switch pgErr.ConstraintName {
+			case "user_auth_provider_idx":
+				return nil, entities.ErrIdentityInUse
+			case "user_auth_user_provider_idx":
+				return nil, entities.ErrProviderAlreadyLinked
+			}
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Create user identity")
+		return nil, err
+	}
+
+	return entities.NewUserIdentityFromModel(row), nil
+}
+
+func (repo *UserRepository) DeleteUserIdentity(ctx context.Context, userID, identityID uuid.UUID) (*entities.UserIdentity, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.DeleteUserAuth(ctx, models.DeleteUserAuthParams{
+		ID:     identityID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrIdentityNotFound
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Delete user identity")
+		return nil, err
+	}
+
+	return entities.NewUserIdentityFromModel(row), nil
 }
 
+// impersonationSessionLength keeps support sessions short so a forgotten
+// impersonation doesn't linger for the usual session lifetime.
+const impersonationSessionLength = time.Hour
+
+// twoFactorPendingSessionLength is how long a user has to enter their second
+// factor after their password.
+const twoFactorPendingSessionLength = 10 * time.Minute
+
 type CreateUserSessionArgs struct {
-	UserID uuid.UUID
+	UserID           uuid.UUID
+	ImpersonatorID   *uuid.UUID
+	UserAgent        *string
+	IPAddress        *string
+	TwoFactorPending bool
 }
 
 func (repo *UserRepository) CreateUserSession(ctx context.Context, args CreateUserSessionArgs) (*entities.UserSession, error) {
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
-	token := xid.New().String()
-	expiresAt := time.Now().Add(time.Hour * 24 * 30)
-	expires := pgtype.Timestamptz{Time: expiresAt, Valid: true}
+	token, err := tokens.Generate()
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Generate session token")
+		return nil, err
+	}
+
+	now := time.Now()
+	absoluteExpiresAt := now.Add(repo.utils.cfg.Sessions.MaxLifetime)
+	if args.ImpersonatorID != nil {
+		absoluteExpiresAt = now.Add(impersonationSessionLength)
+	}
+	if args.TwoFactorPending {
+		absoluteExpiresAt = now.Add(twoFactorPendingSessionLength)
+	}
+	expiresAt := now.Add(repo.utils.cfg.Sessions.IdleTimeout)
+	if expiresAt.After(absoluteExpiresAt) {
+		expiresAt = absoluteExpiresAt
+	}
 
 	row, err := repo.queries.CreateUserSession(ctx, models.CreateUserSessionParams{
-		UserID:    args.UserID,
-		Token:     token,
-		ExpiresAt: expires,
+		UserID:            args.UserID,
+		TokenHash:         tokens.Hash(token),
+		ExpiresAt:         pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		AbsoluteExpiresAt: pgtype.Timestamptz{Time: absoluteExpiresAt, Valid: true},
+		ImpersonatorID:    args.ImpersonatorID,
+		UserAgent:         args.UserAgent,
+		IpAddress:         args.IPAddress,
+		TwoFactorPending:  args.TwoFactorPending,
 	})
 	if err != nil {
 		repo.utils.logger.Err(err).Ctx(ctx).Msg("Create user session")
 		return nil, err
 	}
 
-	entity := entities.NewUserSession(entities.NewUserSessionArgs{
-		ID:            row.ID,
-		UserID:        row.UserID,
-		Token:         row.Token,
-		ExpiresAt:     row.ExpiresAt.Time,
-		ExpiredByUser: row.UserExpired,
-	})
+	// Only the hash is stored, so the token itself is handed back once here
+	// for the session cookie.
+	entity := sessionModelToEntity(row)
+	entity.Token = token
 
 	return entity, nil
 }
@@ -157,6 +244,126 @@ func (repo *UserRepository) ExpireUserSession(ctx context.Context, sessionID uui
 	return nil
 }
 
+// GetActiveUserSessions returns the user's unexpired sessions, most recently
+// used first.
+func (repo *UserRepository) GetActiveUserSessions(ctx context.Context, userID uuid.UUID) ([]*entities.UserSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetActiveUserSessions(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get active user sessions")
+		return nil, err
+	}
+
+	sessions := make([]*entities.UserSession, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, sessionModelToEntity(row))
+	}
+
+	return sessions, nil
+}
+
+// TouchUserSession records a use of the session and slides its expiry out by
+// the idle timeout, up to its absolute expiry.
+func (repo *UserRepository) TouchUserSession(ctx context.Context, sessionID uuid.UUID, ipAddress *string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	expiresAt := time.Now().Add(repo.utils.cfg.Sessions.IdleTimeout)
+
+	err := repo.queries.TouchUserSession(ctx, models.TouchUserSessionParams{
+		ID:        sessionID,
+		IpAddress: ipAddress,
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Touch user session")
+		return err
+	}
+	return nil
+}
+
+// ExpireUserSessionForUser expires one of the user's sessions. Sessions that
+// belong to someone else or are already expired return ErrSessionNotFound.
+func (repo *UserRepository) ExpireUserSessionForUser(ctx context.Context, userID, sessionID uuid.UUID) (*entities.UserSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.ExpireUserSessionForUser(ctx, models.ExpireUserSessionForUserParams{
+		ID:     sessionID,
+		UserID: userID,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrSessionNotFound
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Expire user session for user")
+		return nil, err
+	}
+
+	return sessionModelToEntity(row), nil
+}
+
+// ExpireUserSessionsByUser expires every active session of the user and
+// returns them.
+func (repo *UserRepository) ExpireUserSessionsByUser(ctx context.Context, userID uuid.UUID) ([]*entities.UserSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.ExpireUserSessionsByUser(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Expire user sessions by user")
+		return nil, err
+	}
+
+	sessions := make([]*entities.UserSession, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, sessionModelToEntity(row))
+	}
+
+	return sessions, nil
+}
+
+// ExpireOtherUserSessions expires every active session of the user except
+// keepSessionID and returns them.
+func (repo *UserRepository) ExpireOtherUserSessions(ctx context.Context, userID, keepSessionID uuid.UUID) ([]*entities.UserSession, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.ExpireOtherUserSessions(ctx, models.ExpireOtherUserSessionsParams{
+		UserID: userID,
+		ID:     keepSessionID,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Expire other user sessions")
+		return nil, err
+	}
+
+	sessions := make([]*entities.UserSession, 0, len(rows))
+	for _, row := range rows {
+		sessions = append(sessions, sessionModelToEntity(row))
+	}
+
+	return sessions, nil
+}
+
+func sessionModelToEntity(model models.UserSession) *entities.UserSession {
+	return entities.NewUserSession(entities.NewUserSessionArgs{
+		ID:                model.ID,
+		UserID:            model.UserID,
+		ImpersonatorID:    model.ImpersonatorID,
+		ExpiresAt:         model.ExpiresAt.Time,
+		AbsoluteExpiresAt: model.AbsoluteExpiresAt.Time,
+		ExpiredByUser:     model.UserExpired,
+		UserAgent:         model.UserAgent,
+		IPAddress:         model.IpAddress,
+		CreatedAt:         model.CreatedAt.Time,
+		LastSeenAt:        model.LastSeenAt.Time,
+		TwoFactorPending:  model.TwoFactorPending,
+	})
+}
+
 type CreateUserPasswordArgs struct {
 	GivenName  *string
 	FamilyName *string
@@ -166,7 +373,7 @@ type CreateUserPasswordArgs struct {
 
 func (repo *UserRepository) CreateUserPassword(ctx context.Context, args CreateUserPasswordArgs) (*entities.User, error) {
 
-	repo.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Creating user")
+	repo.utils.logger.Info().Ctx(ctx).Str("email", args.Email).Msg("Creating user")
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
@@ -179,12 +386,11 @@ func (repo *UserRepository) CreateUserPassword(ctx context.Context, args CreateU
 
 	qtx := repo.queries.WithTx(tx)
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(args.Password), 12)
+	hashedPasswordString, err := hashPassword(args.Password)
 	if err != nil {
 		repo.utils.logger.Err(err).Ctx(ctx).Msg("Generate from password")
 		return nil, err
 	}
-	hashedPasswordString := string(hashedPassword)
 
 	userRow, err := qtx.CreateUser(ctx, models.CreateUserParams{
 		GivenName:     args.GivenName,
@@ -221,25 +427,225 @@ func (repo *UserRepository) CreateUserPassword(ctx context.Context, args CreateU
 		return nil, err
 	}
 
-	entity := entities.NewUserEntityFromModel(userRow, userAuthRow)
+	entity := entities.NewUserEntityFromModel(userRow, []models.UserAuth{userAuthRow})
 
 	return entity, nil
 }
 
+// bcryptCost is the work factor for stored password hashes.
+const bcryptCost = 12
+
+func hashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
+
+// SetUserPassword replaces the user's password. Outstanding reset links stop
+// working. Users without a password return ErrNoPasswordSet.
+func (repo *UserRepository) SetUserPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Generate from password")
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	err = setUserPassword(ctx, qtx, userID, hashedPassword)
+	if err != nil {
+		if err != entities.ErrNoPasswordSet {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Set user password")
+		}
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+func setUserPassword(ctx context.Context, qtx *models.Queries, userID uuid.UUID, hashedPassword string) error {
+	_, err := qtx.UpdateUserPassword(ctx, models.UpdateUserPasswordParams{
+		UserID: userID,
+		Value:  hashedPassword,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return entities.ErrNoPasswordSet
+		}
+		return err
+	}
+
+	return qtx.ExpirePasswordResetsByUser(ctx, userID)
+}
+
+// CreatePasswordReset issues a reset token for the user that works once
+// before expiresAt. Only its hash is stored, so the token is returned here
+// once to be mailed.
+func (repo *UserRepository) CreatePasswordReset(ctx context.Context, userID uuid.UUID, expiresAt time.Time) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	token, err := tokens.Generate()
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Generate password reset token")
+		return "", err
+	}
+
+	_, err = repo.queries.CreatePasswordReset(ctx, models.CreatePasswordResetParams{
+		UserID:    userID,
+		TokenHash: tokens.Hash(token),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Create password reset")
+		return "", err
+	}
+
+	return token, nil
+}
+
+// CheckPasswordReset returns ErrPasswordResetInvalid unless token is an
+// unused, unexpired reset token.
+func (repo *UserRepository) CheckPasswordReset(ctx context.Context, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := repo.queries.GetPasswordResetByTokenHash(ctx, tokens.Hash(token))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return entities.ErrPasswordResetInvalid
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get password reset by token hash")
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword uses up the reset token and sets the password of the user it
+// was issued to, returning their ID. Every other reset token of theirs stops
+// working too.
+func (repo *UserRepository) ResetPassword(ctx context.Context, token, password string) (uuid.UUID, error) {
+	hashedPassword, err := hashPassword(password)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Generate from password")
+		return uuid.Nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Begin transaction")
+		return uuid.Nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	reset, err := qtx.UsePasswordReset(ctx, tokens.Hash(token))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return uuid.Nil, entities.ErrPasswordResetInvalid
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Use password reset")
+		return uuid.Nil, err
+	}
+
+	err = setUserPassword(ctx, qtx, reset.UserID, hashedPassword)
+	if err != nil {
+		if err != entities.ErrNoPasswordSet {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Set user password")
+		}
+		return uuid.Nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Commit transaction")
+		return uuid.Nil, err
+	}
+
+	return reset.UserID, nil
+}
+
+// CreateLoginLink issues a login token for email that works once before
+// expiresAt. As with password resets, only its hash is stored.
+func (repo *UserRepository) CreateLoginLink(ctx context.Context, email string, expiresAt time.Time) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	token, err := tokens.Generate()
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Generate login link token")
+		return "", err
+	}
+
+	_, err = repo.queries.CreateLoginLink(ctx, models.CreateLoginLinkParams{
+		Email:     email,
+		TokenHash: tokens.Hash(token),
+		ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Create login link")
+		return "", err
+	}
+
+	return token, nil
+}
+
+// UseLoginLink uses up the login token and returns the address it was sent
+// to.
+func (repo *UserRepository) UseLoginLink(ctx context.Context, token string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	link, err := repo.queries.UseLoginLink(ctx, tokens.Hash(token))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", entities.ErrLoginLinkInvalid
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Use login link")
+		return "", err
+	}
+
+	return link.Email, nil
+}
+
 type CreateUserOAuthArgs struct {
-	GivenName    *string
-	FamilyName   *string
-	Email        string
-	AvatarUrl    *string
-	Value        string
-	Provider     string
-	ProviderID   string
-	ProviderData []byte
+	GivenName     *string
+	FamilyName    *string
+	Email         string
+	EmailVerified bool
+	AvatarUrl     *string
+	Value         string
+	Provider      string
+	ProviderID    string
+	ProviderData  []byte
 }
 
 func (repo *UserRepository) CreateUserOAuth(ctx context.Context, args CreateUserOAuthArgs) (*entities.User, error) {
 
-	repo.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Creating user from OAuth")
+	repo.utils.logger.Info().Ctx(ctx).Str("email", args.Email).Str("provider", args.Provider).Msg("Creating user from OAuth")
 	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
 	defer cancel()
 
@@ -256,7 +662,7 @@ func (repo *UserRepository) CreateUserOAuth(ctx context.Context, args CreateUser
 		GivenName:     args.GivenName,
 		FamilyName:    args.FamilyName,
 		Email:         args.Email,
-		EmailVerified: true,
+		EmailVerified: args.EmailVerified,
 		AvatarUrl:     args.AvatarUrl,
 	})
 	if err != nil {
@@ -289,7 +695,423 @@ func (repo *UserRepository) CreateUserOAuth(ctx context.Context, args CreateUser
 		return nil, err
 	}
 
-	entity := entities.NewUserEntityFromModel(userRow, userAuthRow)
+	entity := entities.NewUserEntityFromModel(userRow, []models.UserAuth{userAuthRow})
 
 	return entity, nil
 }
+
+// SetUserEmailVerified marks the user's email as verified, as long as it is
+// still email.
+func (repo *UserRepository) SetUserEmailVerified(ctx context.Context, userID uuid.UUID, email string) (*entities.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.SetUserEmailVerified(ctx, models.SetUserEmailVerifiedParams{
+		ID:    userID,
+		Email: email,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrUserNotFound
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Set user email verified")
+		return nil, err
+	}
+
+	return entities.NewUserEntityFromModel(row, nil), nil
+}
+
+// ClaimedUser is an unverified account after the owner of its email claimed
+// it, with whatever the previous holder could have used to get back in.
+type ClaimedUser struct {
+	User              *entities.User
+	RemovedIdentities []*entities.UserIdentity
+	ExpiredSessions   []*entities.UserSession
+}
+
+// ClaimUnverifiedUser hands an unverified account to whoever proved they own
+// its email. Every way to log in, the two-factor enrolment and every session
+// are removed, and the emailed link becomes the only login.
+func (repo *UserRepository) ClaimUnverifiedUser(ctx context.Context, userID uuid.UUID, email string) (*ClaimedUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Begin transaction")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	userRow, err := qtx.SetUserEmailVerified(ctx, models.SetUserEmailVerifiedParams{
+		ID:    userID,
+		Email: email,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrUserNotFound
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Set user email verified")
+		return nil, err
+	}
+
+	removedRows, err := qtx.DeleteUserAuthsByUser(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Delete user auths by user")
+		return nil, err
+	}
+
+	userAuthRow, err := qtx.CreateUserAuth(ctx, models.CreateUserAuthParams{
+		UserID:     userID,
+		Provider:   entities.ProviderEmail,
+		ProviderID: strings.ToLower(email),
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Create user auth")
+		return nil, err
+	}
+
+	err = qtx.DeleteTwoFactor(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Delete two factor")
+		return nil, err
+	}
+
+	err = qtx.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Delete recovery codes")
+		return nil, err
+	}
+
+	sessionRows, err := qtx.ExpireUserSessionsByUser(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Expire user sessions by user")
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Commit transaction")
+		return nil, err
+	}
+
+	claimed := &ClaimedUser{
+		User:              entities.NewUserEntityFromModel(userRow, []models.UserAuth{userAuthRow}),
+		RemovedIdentities: make([]*entities.UserIdentity, 0, len(removedRows)),
+		ExpiredSessions:   make([]*entities.UserSession, 0, len(sessionRows)),
+	}
+	for _, row := range removedRows {
+		claimed.RemovedIdentities = append(claimed.RemovedIdentities, entities.NewUserIdentityFromModel(row))
+	}
+	for _, row := range sessionRows {
+		claimed.ExpiredSessions = append(claimed.ExpiredSessions, sessionModelToEntity(row))
+	}
+
+	return claimed, nil
+}
+
+func (repo *UserRepository) GetUsersByRole(ctx context.Context, roles []string) ([]*entities.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetUsersByRole(ctx, roles)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get users by role")
+		return nil, err
+	}
+
+	users := []*entities.User{}
+
+	for _, row := range rows {
+		users = append(users, entities.NewUserEntityFromModel(row, nil))
+	}
+
+	return users, nil
+}
+
+func (repo *UserRepository) UpdateUserRole(ctx context.Context, userID uuid.UUID, role string) (*entities.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.UpdateUserRole(ctx, models.UpdateUserRoleParams{
+		ID:   userID,
+		Role: role,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrUserNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Update user role")
+			return nil, err
+		}
+	}
+
+	return entities.NewUserEntityFromModel(row, nil), nil
+}
+
+// GetTwoFactor returns the user's TOTP enrolment, finished or not. Users who
+// never started one return ErrTwoFactorNotEnabled.
+func (repo *UserRepository) GetTwoFactor(ctx context.Context, userID uuid.UUID) (*entities.TwoFactor, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetTwoFactorByUserID(ctx, userID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrTwoFactorNotEnabled
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get two factor by user ID")
+		return nil, err
+	}
+
+	count, err := repo.queries.CountUnusedRecoveryCodes(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Count unused recovery codes")
+		return nil, err
+	}
+
+	return entities.NewTwoFactorEntityFromModel(row, int(count)), nil
+}
+
+// StartTwoFactor stores a new secret for an enrolment the user hasn't
+// finished. Users who already have two-factor enabled return
+// ErrTwoFactorAlreadyEnabled.
+func (repo *UserRepository) StartTwoFactor(ctx context.Context, userID uuid.UUID, secret string) (*entities.TwoFactor, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.StartTwoFactor(ctx, models.StartTwoFactorParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, entities.ErrTwoFactorAlreadyEnabled
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Start two factor")
+		return nil, err
+	}
+
+	return entities.NewTwoFactorEntityFromModel(row, 0), nil
+}
+
+// EnableTwoFactor finishes the user's enrolment with the step of the code
+// they confirmed it with, and stores their recovery codes.
+func (repo *UserRepository) EnableTwoFactor(ctx context.Context, userID uuid.UUID, step int64, recoveryCodeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	_, err = qtx.EnableTwoFactor(ctx, models.EnableTwoFactorParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return entities.ErrTwoFactorNotStarted
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Enable two factor")
+		return err
+	}
+
+	err = replaceRecoveryCodes(ctx, qtx, userID, recoveryCodeHashes)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Replace recovery codes")
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+// UseTwoFactorStep records that a code for step was accepted. A step at or
+// before the last one used returns ErrInvalidTwoFactorCode, so each code only
+// works once.
+func (repo *UserRepository) UseTwoFactorStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := repo.queries.UseTwoFactorStep(ctx, models.UseTwoFactorStepParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return entities.ErrInvalidTwoFactorCode
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Use two factor step")
+		return err
+	}
+
+	return nil
+}
+
+// UseRecoveryCode uses up one of the user's recovery codes.
+func (repo *UserRepository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	_, err := repo.queries.UseRecoveryCode(ctx, models.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: codeHash,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return entities.ErrInvalidTwoFactorCode
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Use recovery code")
+		return err
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for a new set.
+func (repo *UserRepository) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, recoveryCodeHashes []string) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = replaceRecoveryCodes(ctx, repo.queries.WithTx(tx), userID, recoveryCodeHashes)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Replace recovery codes")
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, qtx *models.Queries, userID uuid.UUID, recoveryCodeHashes []string) error {
+	err := qtx.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		err = qtx.CreateRecoveryCode(ctx, models.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DisableTwoFactor removes the user's enrolment and recovery codes.
+func (repo *UserRepository) DisableTwoFactor(ctx context.Context, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Begin transaction")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	err = qtx.DeleteTwoFactor(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Delete two factor")
+		return err
+	}
+
+	err = qtx.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Delete recovery codes")
+		return err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Commit transaction")
+		return err
+	}
+
+	return nil
+}
+
+func (repo *UserRepository) GetRolePolicies(ctx context.Context) ([]*entities.RolePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetRolePolicies(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get role policies")
+		return nil, err
+	}
+
+	policies := []*entities.RolePolicy{}
+
+	for _, row := range rows {
+		policies = append(policies, entities.NewRolePolicyEntityFromModel(row))
+	}
+
+	return policies, nil
+}
+
+// GetRolePolicy returns the policy for role. Roles nobody has set a policy for
+// get the defaults.
+func (repo *UserRepository) GetRolePolicy(ctx context.Context, role string) (*entities.RolePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetRolePolicy(ctx, role)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return &entities.RolePolicy{Role: role}, nil
+		}
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Get role policy")
+		return nil, err
+	}
+
+	return entities.NewRolePolicyEntityFromModel(row), nil
+}
+
+func (repo *UserRepository) SetRolePolicy(ctx context.Context, role string, requireTwoFactor bool, updatedBy *uuid.UUID) (*entities.RolePolicy, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.SetRolePolicy(ctx, models.SetRolePolicyParams{
+		Role:             role,
+		RequireTwoFactor: requireTwoFactor,
+		UpdatedBy:        updatedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Set role policy")
+		return nil, err
+	}
+
+	return entities.NewRolePolicyEntityFromModel(row), nil
+}
@@ -0,0 +1,418 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories/models"
+)
+
+type WorkspaceRepository struct {
+	utils   ServicesUtils
+	DB      *pgxpool.Pool
+	queries *models.Queries
+}
+
+func NewWorkspaceRepository(utils ServicesUtils, db *pgxpool.Pool, queries *models.Queries) *WorkspaceRepository {
+	return &WorkspaceRepository{
+		utils:   utils,
+		DB:      db,
+		queries: queries,
+	}
+}
+
+type CreateWorkspaceArgs struct {
+	Name      string
+	CreatedBy uuid.UUID
+}
+
+// CreateWorkspace creates the workspace with its creator as the first owner.
+func (repo *WorkspaceRepository) CreateWorkspace(ctx context.Context, args CreateWorkspaceArgs) (*entities.WorkspaceMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction creating workspace")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	workspaceRow, err := qtx.CreateWorkspace(ctx, models.CreateWorkspaceParams{
+		Name:      args.Name,
+		CreatedBy: &args.CreatedBy,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating workspace")
+		return nil, err
+	}
+
+	memberRow, err := qtx.UpsertWorkspaceMember(ctx, models.UpsertWorkspaceMemberParams{
+		WorkspaceID: workspaceRow.ID,
+		UserID:      args.CreatedBy,
+		Role:        entities.WorkspaceRoleOwner,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error adding workspace owner")
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	member := memberModelToEntity(memberRow)
+	member.Workspace = workspaceModelToEntity(workspaceRow)
+
+	return member, nil
+}
+
+func (repo *WorkspaceRepository) GetWorkspaceByID(ctx context.Context, workspaceID uuid.UUID) (*entities.Workspace, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetWorkspaceByID(ctx, workspaceID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting workspace by id")
+			return nil, err
+		}
+	}
+
+	return workspaceModelToEntity(row), nil
+}
+
+// GetWorkspacesByUserID returns the user's memberships with their workspaces.
+func (repo *WorkspaceRepository) GetWorkspacesByUserID(ctx context.Context, userID uuid.UUID) ([]*entities.WorkspaceMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetWorkspacesByUserID(ctx, userID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting workspaces by user id")
+		return nil, err
+	}
+
+	memberships := []*entities.WorkspaceMember{}
+
+	for _, row := range rows {
+		member := memberModelToEntity(row.WorkspaceMember)
+		member.Workspace = workspaceModelToEntity(row.Workspace)
+		memberships = append(memberships, member)
+	}
+
+	return memberships, nil
+}
+
+func (repo *WorkspaceRepository) GetWorkspaceMember(ctx context.Context, workspaceID, userID uuid.UUID) (*entities.WorkspaceMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetWorkspaceMember(ctx, models.GetWorkspaceMemberParams{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+	})
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting workspace member")
+			return nil, err
+		}
+	}
+
+	return memberModelToEntity(row), nil
+}
+
+func (repo *WorkspaceRepository) GetWorkspaceMembers(ctx context.Context, workspaceID uuid.UUID) ([]*entities.WorkspaceMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting workspace members")
+		return nil, err
+	}
+
+	members := []*entities.WorkspaceMember{}
+
+	for _, row := range rows {
+		members = append(members, memberModelToEntity(row))
+	}
+
+	return members, nil
+}
+
+type SetWorkspaceMemberArgs struct {
+	WorkspaceID uuid.UUID
+	UserID      uuid.UUID
+	Role        string
+}
+
+// SetWorkspaceMember adds the user to the workspace, or changes their role if
+// they are already a member. Demoting the last owner is refused.
+func (repo *WorkspaceRepository) SetWorkspaceMember(ctx context.Context, args SetWorkspaceMemberArgs) (*entities.WorkspaceMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction setting workspace member")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	row, err := qtx.UpsertWorkspaceMember(ctx, models.UpsertWorkspaceMemberParams{
+		WorkspaceID: args.WorkspaceID,
+		UserID:      args.UserID,
+		Role:        args.Role,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error setting workspace member")
+		return nil, err
+	}
+
+	owners, err := qtx.CountWorkspaceOwners(ctx, args.WorkspaceID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error counting workspace owners")
+		return nil, err
+	}
+	if owners == 0 {
+		return nil, entities.ErrLastWorkspaceOwner
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	return memberModelToEntity(row), nil
+}
+
+// RemoveWorkspaceMember takes the user out of the workspace. The workspace's
+// links stay with the workspace. Removing the last owner is refused.
+func (repo *WorkspaceRepository) RemoveWorkspaceMember(ctx context.Context, workspaceID, userID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction removing workspace member")
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	affected, err := qtx.DeleteWorkspaceMember(ctx, models.DeleteWorkspaceMemberParams{
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error removing workspace member")
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	owners, err := qtx.CountWorkspaceOwners(ctx, workspaceID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error counting workspace owners")
+		return err
+	}
+	if owners == 0 {
+		return entities.ErrLastWorkspaceOwner
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return err
+	}
+
+	return nil
+}
+
+type CreateWorkspaceInviteArgs struct {
+	WorkspaceID uuid.UUID
+	Email       string
+	Role        string
+	InvitedBy   uuid.UUID
+	ExpiresAt   time.Time
+}
+
+func (repo *WorkspaceRepository) CreateWorkspaceInvite(ctx context.Context, args CreateWorkspaceInviteArgs) (*entities.WorkspaceInvite, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.CreateWorkspaceInvite(ctx, models.CreateWorkspaceInviteParams{
+		WorkspaceID: args.WorkspaceID,
+		Email:       args.Email,
+		Role:        args.Role,
+		InvitedBy:   &args.InvitedBy,
+		ExpiresAt:   toTimestamptz(&args.ExpiresAt),
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error creating workspace invite")
+		return nil, err
+	}
+
+	return inviteModelToEntity(row), nil
+}
+
+func (repo *WorkspaceRepository) GetWorkspaceInviteByID(ctx context.Context, inviteID uuid.UUID) (*entities.WorkspaceInvite, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	row, err := repo.queries.GetWorkspaceInviteByID(ctx, inviteID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		} else {
+			repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting workspace invite by id")
+			return nil, err
+		}
+	}
+
+	return inviteModelToEntity(row), nil
+}
+
+func (repo *WorkspaceRepository) GetPendingWorkspaceInvites(ctx context.Context, workspaceID uuid.UUID) ([]*entities.WorkspaceInvite, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	rows, err := repo.queries.GetPendingWorkspaceInvites(ctx, workspaceID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error getting pending workspace invites")
+		return nil, err
+	}
+
+	invites := []*entities.WorkspaceInvite{}
+
+	for _, row := range rows {
+		invites = append(invites, inviteModelToEntity(row))
+	}
+
+	return invites, nil
+}
+
+// AcceptWorkspaceInvite marks the invite accepted and adds the user to the
+// workspace with the invited role. Invites that have expired, been revoked or
+// already been used are refused with entities.ErrInviteUnavailable.
+func (repo *WorkspaceRepository) AcceptWorkspaceInvite(ctx context.Context, invite *entities.WorkspaceInvite, userID uuid.UUID) (*entities.WorkspaceMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	tx, err := repo.DB.Begin(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error with transaction accepting workspace invite")
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	qtx := repo.queries.WithTx(tx)
+
+	affected, err := qtx.AcceptWorkspaceInvite(ctx, models.AcceptWorkspaceInviteParams{
+		ID:         invite.ID,
+		AcceptedBy: &userID,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error accepting workspace invite")
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, entities.ErrInviteUnavailable
+	}
+
+	row, err := qtx.UpsertWorkspaceMember(ctx, models.UpsertWorkspaceMemberParams{
+		WorkspaceID: invite.WorkspaceID,
+		UserID:      userID,
+		Role:        invite.Role,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error adding invited workspace member")
+		return nil, err
+	}
+
+	owners, err := qtx.CountWorkspaceOwners(ctx, invite.WorkspaceID)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error counting workspace owners")
+		return nil, err
+	}
+	if owners == 0 {
+		return nil, entities.ErrLastWorkspaceOwner
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error committing transaction")
+		return nil, err
+	}
+
+	return memberModelToEntity(row), nil
+}
+
+func (repo *WorkspaceRepository) RevokeWorkspaceInvite(ctx context.Context, workspaceID, inviteID uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	affected, err := repo.queries.RevokeWorkspaceInvite(ctx, models.RevokeWorkspaceInviteParams{
+		ID:          inviteID,
+		WorkspaceID: workspaceID,
+	})
+	if err != nil {
+		repo.utils.logger.Err(err).Ctx(ctx).Msg("Error revoking workspace invite")
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func workspaceModelToEntity(model models.Workspace) *entities.Workspace {
+	return &entities.Workspace{
+		ID:        model.ID,
+		Name:      model.Name,
+		CreatedBy: model.CreatedBy,
+		CreatedAt: model.CreatedAt.Time,
+	}
+}
+
+func memberModelToEntity(model models.WorkspaceMember) *entities.WorkspaceMember {
+	return &entities.WorkspaceMember{
+		WorkspaceID: model.WorkspaceID,
+		UserID:      model.UserID,
+		Role:        model.Role,
+		CreatedAt:   model.CreatedAt.Time,
+	}
+}
+
+func inviteModelToEntity(model models.WorkspaceInvite) *entities.WorkspaceInvite {
+	return &entities.WorkspaceInvite{
+		ID:          model.ID,
+		WorkspaceID: model.WorkspaceID,
+		Email:       model.Email,
+		Role:        model.Role,
+		InvitedBy:   model.InvitedBy,
+		ExpiresAt:   model.ExpiresAt.Time,
+		AcceptedAt:  fromTimestamptz(model.AcceptedAt),
+		RevokedAt:   fromTimestamptz(model.RevokedAt),
+		CreatedAt:   model.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 10000
+)
+
+type AuditService struct {
+	utils           ServicesUtils
+	auditRepository *repositories.AuditRepository
+	userRepository  *repositories.UserRepository
+}
+
+func NewAuditService(utils ServicesUtils, repos *repositories.Repositories) *AuditService {
+	return &AuditService{
+		utils:           utils,
+		auditRepository: repos.AuditRepository,
+		userRepository:  repos.UserRepository,
+	}
+}
+
+type auditArgs struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	// ActorID overrides the user signed in on ctx, for events such as a login
+	// where nobody is signed in yet.
+	ActorID  *uuid.UUID
+	Metadata map[string]any
+}
+
+// audit appends an event to the audit log. The actor, impersonator and request
+// details come from ctx. Failures are logged rather than returned so the
+// action being audited is never undone by the audit log being unavailable.
+func (utils *ServicesUtils) audit(ctx context.Context, args auditArgs) {
+	if utils.auditRepository == nil {
+		return
+	}
+
+	createArgs := repositories.CreateAuditEventArgs{
+		Action:   args.Action,
+		ActorID:  args.ActorID,
+		Metadata: args.Metadata,
+	}
+
+	if createArgs.ActorID == nil {
+		if user := usercontext.ContextGetUser(ctx); user != nil {
+			createArgs.ActorID = &user.ID
+		}
+	}
+
+	if session := usercontext.ContextGetSession(ctx); session != nil && session.IsImpersonation() {
+		createArgs.ImpersonatorID = session.ImpersonatorID
+	}
+
+	if args.TargetType != "" {
+		createArgs.TargetType = &args.TargetType
+		createArgs.TargetID = &args.TargetID
+	}
+
+	if info := usercontext.ContextGetRequestInfo(ctx); info != nil {
+		createArgs.IPAddress = &info.IPAddress
+		createArgs.UserAgent = &info.UserAgent
+		createArgs.CorrelationID = &info.CorrelationID
+	}
+
+	_, err := utils.auditRepository.CreateAuditEvent(ctx, createArgs)
+	if err != nil {
+		utils.logger.Err(err).Ctx(ctx).Str("action", args.Action).Msg("Error recording audit event")
+	}
+}
+
+// auditLogin records a sign in and the session it opened. Nobody is signed in
+// on ctx yet, so the user is passed in.
+func (utils *ServicesUtils) auditLogin(ctx context.Context, userID uuid.UUID, session *entities.UserSession, method string) {
+	utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLogin,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+		ActorID:    &userID,
+		Metadata:   map[string]any{"method": method},
+	})
+	utils.audit(ctx, auditArgs{
+		Action:     entities.AuditSessionCreated,
+		TargetType: entities.AuditTargetSession,
+		TargetID:   session.ID.String(),
+		ActorID:    &userID,
+		Metadata:   map[string]any{"expires_at": session.ExpiresAt},
+	})
+}
+
+func (utils *ServicesUtils) auditSessionExpired(ctx context.Context, session *entities.UserSession, reason string) {
+	utils.audit(ctx, auditArgs{
+		Action:     entities.AuditSessionExpired,
+		TargetType: entities.AuditTargetSession,
+		TargetID:   session.ID.String(),
+		Metadata:   map[string]any{"user_id": session.UserID.String(), "reason": reason},
+	})
+}
+
+type GetAuditEventsArgs struct {
+	Action        string
+	ActorEmail    string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Limit         int
+}
+
+// GetEvents returns the newest audit events matching args. An actor matches
+// events they performed directly or while impersonating someone else.
+func (service *AuditService) GetEvents(ctx context.Context, args GetAuditEventsArgs) ([]*entities.AuditEvent, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Getting audit events")
+
+	err := service.utils.authorize(ctx, entities.PermissionViewAuditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := entities.AuditFilter{
+		CreatedAfter:  args.CreatedAfter,
+		CreatedBefore: args.CreatedBefore,
+		Limit:         args.Limit,
+	}
+
+	if args.Action != "" {
+		filter.Action = &args.Action
+	}
+
+	if email := strings.TrimSpace(args.ActorEmail); email != "" {
+		actor, err := service.userRepository.GetUserByEmail(ctx, email)
+		if err != nil {
+			return nil, err
+		}
+		filter.ActorID = &actor.ID
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	return service.auditRepository.GetAuditEvents(ctx, filter)
+}
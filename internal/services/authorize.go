@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+)
+
+// authorize checks that the user signed in on ctx holds permission. Services
+// call it at the top of every privileged operation so the check holds no matter
+// which handler, command or job reaches them.
+func (utils *ServicesUtils) authorize(ctx context.Context, permission entities.Permission) error {
+	user := usercontext.ContextGetUser(ctx)
+
+	if !user.Can(permission) {
+		userID := "anonymous"
+		if user != nil {
+			userID = user.ID.String()
+		}
+		utils.logger.Warn().Ctx(ctx).Str("userID", userID).Str("permission", string(permission)).Msg("Permission denied")
+		return entities.ErrForbidden
+	}
+
+	return nil
+}
+
+// actorID returns who should be recorded as making a change on behalf of
+// userID. While an admin is impersonating, that is the admin.
+func actorID(ctx context.Context, userID uuid.UUID) uuid.UUID {
+	session := usercontext.ContextGetSession(ctx)
+	if session != nil && session.IsImpersonation() {
+		return *session.ImpersonatorID
+	}
+	return userID
+}
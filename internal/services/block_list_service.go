@@ -0,0 +1,349 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/threatfeed"
+)
+
+var (
+	ErrCannotBlockSelf = errors.New("cannot block yourself")
+)
+
+type BlockListService struct {
+	utils             ServicesUtils
+	blockedRepository *repositories.BlockedRepository
+	linkRepository    *repositories.LinkRepository
+	userRepository    *repositories.UserRepository
+	moderationService *ModerationService
+}
+
+func NewBlockListService(utils ServicesUtils, repos *repositories.Repositories, moderationService *ModerationService) *BlockListService {
+	return &BlockListService{
+		utils:             utils,
+		blockedRepository: repos.BlockedRepository,
+		linkRepository:    repos.LinkRepository,
+		userRepository:    repos.UserRepository,
+		moderationService: moderationService,
+	}
+}
+
+type ImportFeedArgs struct {
+	Source string
+	Format string
+	Feed   io.Reader
+	// AllowEmpty lets a feed with no rows at all remove every domain from the
+	// source. Without it, empty feeds return threatfeed.ErrEmptyFeed.
+	AllowEmpty bool
+}
+
+// ImportFeed replaces the domains tagged with args.Source with the contents of
+// the feed, then quarantines any live links that the new list now covers.
+// Feeds that look empty or in the wrong format are refused before anything is
+// removed.
+func (service *BlockListService) ImportFeed(ctx context.Context, args ImportFeedArgs) (*entities.BlockListImport, error) {
+	source := strings.ToLower(strings.TrimSpace(args.Source))
+
+	service.utils.logger.Info().Ctx(ctx).Str("source", source).Str("format", args.Format).Msg("Importing threat feed")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageBlockList)
+	if err != nil {
+		return nil, err
+	}
+
+	if source == "" || source == entities.BlockListSourceManual {
+		return nil, entities.ErrReservedSource
+	}
+
+	feed, err := threatfeed.Parse(args.Feed, args.Format)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error parsing threat feed")
+		return nil, err
+	}
+
+	err = feed.Check()
+	if err != nil && !(args.AllowEmpty && len(feed.Entries) == 0 && feed.Skipped == 0) {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Refusing threat feed")
+		return nil, err
+	}
+
+	result, err := service.blockedRepository.SyncBlockedDomains(ctx, repositories.SyncBlockedDomainsArgs{
+		Source:  source,
+		Entries: feed.Entries,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error syncing blocked domains")
+		return nil, err
+	}
+	result.Skipped += feed.Skipped
+
+	if result.Added > 0 {
+		result.Quarantined, err = service.RescanLinks(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	service.utils.logger.Info().Ctx(ctx).Interface("result", result).Msg("Imported threat feed")
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditFeedImported,
+		TargetType: entities.AuditTargetFeed,
+		TargetID:   source,
+		Metadata: map[string]any{
+			"format":      args.Format,
+			"added":       result.Added,
+			"updated":     result.Updated,
+			"removed":     result.Removed,
+			"skipped":     result.Skipped,
+			"quarantined": result.Quarantined,
+		},
+	})
+
+	return result, nil
+}
+
+// RescanLinks checks every live link against the block list and quarantines
+// the ones whose destination is now blocked. It returns how many were
+// quarantined.
+func (service *BlockListService) RescanLinks(ctx context.Context) (int, error) {
+	links, err := service.linkRepository.GetActiveLinks(ctx)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting links to rescan")
+		return 0, err
+	}
+
+	quarantined := 0
+	for _, link := range links {
+		linkURL, err := url.Parse(link.LinkURL)
+		if err != nil {
+			continue
+		}
+
+		blocked, err := service.blockedRepository.IsDomainBlocked(ctx, linkURL.Host)
+		if err != nil {
+			service.utils.logger.Err(err).Ctx(ctx).Str("linkID", link.ID.String()).Msg("Error checking link against block list")
+			return quarantined, err
+		}
+		if !blocked {
+			continue
+		}
+
+		_, err = service.moderationService.QuarantineLink(ctx, QuarantineLinkArgs{
+			LinkID:  link.ID,
+			Source:  entities.QuarantineSourceBlockList,
+			Reason:  fmt.Sprintf("%s is on the block list", linkURL.Hostname()),
+			ActorID: systemActorID,
+		})
+		if err != nil {
+			if errors.Is(err, ErrAlreadyQuarantined) {
+				continue
+			}
+			service.utils.logger.Err(err).Ctx(ctx).Str("linkID", link.ID.String()).Msg("Error quarantining blocked link")
+			return quarantined, err
+		}
+		quarantined++
+	}
+
+	return quarantined, nil
+}
+
+func (service *BlockListService) GetBlockList(ctx context.Context) (*entities.BlockList, error) {
+	service.utils.logger.Info().Ctx(ctx).Msg("Getting block list")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageBlockList)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := service.blockedRepository.GetBlockedUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, blockedUser := range users {
+		user, err := service.userRepository.GetUserByID(ctx, blockedUser.UserID)
+		if err == nil {
+			blockedUser.User = user
+		}
+	}
+
+	domains, err := service.blockedRepository.GetBlockedDomainsBySource(ctx, entities.BlockListSourceManual)
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := service.blockedRepository.CountBlockedDomainsBySource(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.BlockList{
+		Users:   users,
+		Domains: domains,
+		Sources: sources,
+	}, nil
+}
+
+type BlockUserArgs struct {
+	Email           string
+	Reason          *string
+	ExpiresAt       *time.Time
+	AdminID         uuid.UUID
+	DeactivateLinks bool
+}
+
+// BlockUser stops the user creating links and, when asked, switches off the
+// links they already have. It returns how many links were deactivated.
+func (service *BlockListService) BlockUser(ctx context.Context, args BlockUserArgs) (int, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("email", args.Email).Bool("deactivateLinks", args.DeactivateLinks).Msg("Blocking user")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageBlockList)
+	if err != nil {
+		return 0, err
+	}
+
+	user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(args.Email))
+	if err != nil {
+		return 0, err
+	}
+
+	if user.ID == args.AdminID {
+		return 0, ErrCannotBlockSelf
+	}
+
+	err = service.blockedRepository.BlockUser(ctx, repositories.BlockUserArgs{
+		UserID:    user.ID,
+		Reason:    args.Reason,
+		ExpiresAt: args.ExpiresAt,
+		CreatedBy: &args.AdminID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditUserBlocked,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Metadata:   map[string]any{"reason": args.Reason, "expires_at": args.ExpiresAt, "deactivate_links": args.DeactivateLinks},
+	})
+
+	if !args.DeactivateLinks {
+		return 0, nil
+	}
+
+	reason := "Owner blocked by admin"
+	if args.Reason != nil {
+		reason = fmt.Sprintf("Owner blocked by admin: %s", *args.Reason)
+	}
+
+	deactivated, err := service.linkRepository.DeactivateLinksByOwner(ctx, repositories.DeactivateLinksByOwnerArgs{
+		OwnerID:   user.ID,
+		UpdatedBy: args.AdminID,
+		Reason:    reason,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error deactivating links of blocked user")
+		return 0, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkDeactivated,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Metadata:   map[string]any{"reason": reason, "links": deactivated},
+	})
+
+	return deactivated, nil
+}
+
+func (service *BlockListService) UnblockUser(ctx context.Context, userID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Unblocking user")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageBlockList)
+	if err != nil {
+		return err
+	}
+
+	err = service.blockedRepository.UnblockUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditUserUnblocked,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+	})
+
+	return nil
+}
+
+type BlockDomainArgs struct {
+	Domain    string
+	Reason    *string
+	ExpiresAt *time.Time
+	AdminID   uuid.UUID
+}
+
+// BlockDomain adds a manual block list entry and quarantines live links that
+// it now covers. It returns how many were quarantined.
+func (service *BlockListService) BlockDomain(ctx context.Context, args BlockDomainArgs) (int, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("domain", args.Domain).Msg("Blocking domain")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageBlockList)
+	if err != nil {
+		return 0, err
+	}
+
+	domain, err := service.blockedRepository.BlockDomain(ctx, repositories.BlockDomainArgs{
+		Domain:    args.Domain,
+		Reason:    args.Reason,
+		ExpiresAt: args.ExpiresAt,
+		CreatedBy: &args.AdminID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditDomainBlocked,
+		TargetType: entities.AuditTargetDomain,
+		TargetID:   domain,
+		Metadata:   map[string]any{"reason": args.Reason, "expires_at": args.ExpiresAt},
+	})
+
+	return service.RescanLinks(ctx)
+}
+
+func (service *BlockListService) UnblockDomain(ctx context.Context, domain string) error {
+	service.utils.logger.Info().Ctx(ctx).Str("domain", domain).Msg("Unblocking domain")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageBlockList)
+	if err != nil {
+		return err
+	}
+
+	err = service.blockedRepository.UnblockDomain(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditDomainUnblocked,
+		TargetType: entities.AuditTargetDomain,
+		TargetID:   domain,
+	})
+
+	return nil
+}
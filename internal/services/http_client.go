@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrPrivateAddress   = errors.New("destination is not a public address")
+)
+
+const userAgent = "url_shortener/1.0 (+https://url.corrigan.io)"
+
+// HTTPClient is the part of *http.Client the services depend on for outbound
+// requests. Tests can provide a stub in its place.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// newRedirectlessHTTPClient returns a client for requests to user supplied
+// URLs. It hands redirects back to the caller so every hop can be inspected,
+// and refuses to connect to anything but public addresses so links can't be
+// used to reach internal services. There is no proxy, since the guard has to
+// see the address actually dialled.
+func newRedirectlessHTTPClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: publicAddressControl,
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// publicAddressControl runs after DNS resolution for every connection,
+// including each redirect hop, and rejects addresses that aren't public.
+func publicAddressControl(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddress(addr) {
+		return ErrPrivateAddress
+	}
+
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range from RFC 6598, which
+// netip doesn't treat as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	switch {
+	case !addr.IsValid(),
+		addr.IsUnspecified(),
+		addr.IsLoopback(),
+		addr.IsPrivate(),
+		addr.IsLinkLocalUnicast(),
+		addr.IsLinkLocalMulticast(),
+		addr.IsInterfaceLocalMulticast(),
+		addr.IsMulticast(),
+		sharedAddressSpace.Contains(addr):
+		return false
+	}
+
+	return true
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// resolveURL follows rawURL's redirect chain using HEAD, retrying with GET for
+// servers that don't support HEAD.
+func resolveURL(ctx context.Context, client HTTPClient, rawURL string, maxHops int, visit func(hop *url.URL) error) (*http.Response, []string, error) {
+	resp, hops, err := followRedirects(ctx, client, http.MethodHead, rawURL, maxHops, visit)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, hops, err = followRedirects(ctx, client, http.MethodGet, rawURL, maxHops, visit)
+	}
+
+	return resp, hops, err
+}
+
+// followRedirects requests rawURL and follows up to maxHops redirects. It
+// returns the final response along with every URL requested, starting with
+// rawURL. If visit is set it is called before each hop is requested and any
+// error it returns stops the walk. The caller is responsible for closing the
+// response body.
+func followRedirects(ctx context.Context, client HTTPClient, method, rawURL string, maxHops int, visit func(hop *url.URL) error) (*http.Response, []string, error) {
+	current, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hops := []string{current.String()}
+
+	for hop := 0; ; hop++ {
+		if visit != nil {
+			err := visit(current)
+			if err != nil {
+				return nil, hops, err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, current.String(), nil)
+		if err != nil {
+			return nil, hops, err
+		}
+		req.Header.Set("User-Agent", userAgent)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, hops, err
+		}
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			return resp, hops, nil
+		}
+		resp.Body.Close()
+
+		if hop >= maxHops {
+			return nil, hops, ErrTooManyRedirects
+		}
+
+		next, err := current.Parse(location)
+		if err != nil {
+			return nil, hops, err
+		}
+
+		current = next
+		hops = append(hops, current.String())
+	}
+}
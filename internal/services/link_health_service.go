@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+)
+
+type LinkHealthService struct {
+	utils                ServicesUtils
+	client               HTTPClient
+	linkRepository       *repositories.LinkRepository
+	linkHealthRepository *repositories.LinkHealthRepository
+	notificationService  *NotificationService
+	hostSlotsMu          sync.Mutex
+	hostSlots            map[string]*hostSlot
+}
+
+// hostSlot limits concurrent checks against one host. It is dropped once no
+// check is using or waiting for it, so the map only holds hosts in flight.
+type hostSlot struct {
+	slots chan struct{}
+	users int
+}
+
+func NewLinkHealthService(utils ServicesUtils, client HTTPClient, repos *repositories.Repositories, notificationService *NotificationService) *LinkHealthService {
+	return &LinkHealthService{
+		utils:                utils,
+		client:               client,
+		linkRepository:       repos.LinkRepository,
+		linkHealthRepository: repos.LinkHealthRepository,
+		notificationService:  notificationService,
+		hostSlots:            make(map[string]*hostSlot),
+	}
+}
+
+// Start checks every active link once per configured interval until ctx is
+// cancelled.
+func (service *LinkHealthService) Start(ctx context.Context) {
+	interval := service.utils.config.HealthCheck.Interval
+
+	service.utils.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			err := service.CheckAllLinks(ctx)
+			if err != nil {
+				service.utils.logger.Err(err).Ctx(ctx).Msg("Error checking link health")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func (service *LinkHealthService) CheckAllLinks(ctx context.Context) error {
+	service.utils.logger.Info().Ctx(ctx).Msg("Checking health of active links")
+
+	links, err := service.linkRepository.GetActiveLinks(ctx)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting active links")
+		return err
+	}
+
+	slots := make(chan struct{}, max(service.utils.config.HealthCheck.Concurrency, 1))
+	wg := sync.WaitGroup{}
+
+	for _, link := range links {
+		if ctx.Err() != nil {
+			break
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			_, err := service.CheckLink(ctx, link)
+			if err != nil {
+				service.utils.logger.Err(err).Ctx(ctx).Str("linkID", link.ID.String()).Msg("Error checking link")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (service *LinkHealthService) CheckLink(ctx context.Context, link *entities.LinkEntity) (*entities.LinkHealthCheck, error) {
+	release, err := service.acquireHost(ctx, link.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result := service.probe(ctx, link.LinkURL)
+	result.LinkID = link.ID
+
+	check, err := service.linkHealthRepository.CreateLinkHealthCheck(ctx, result)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error recording link health check")
+		return nil, err
+	}
+
+	err = service.updateBrokenState(ctx, link, check)
+	if err != nil {
+		return nil, err
+	}
+
+	return check, nil
+}
+
+// probe issues a HEAD request against linkURL, falling back to GET for servers
+// that don't support HEAD, and records what it found.
+func (service *LinkHealthService) probe(ctx context.Context, linkURL string) repositories.CreateLinkHealthCheckArgs {
+	ctx, cancel := context.WithTimeout(ctx, service.utils.config.HealthCheck.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	resp, hops, err := resolveURL(ctx, service.client, linkURL, service.utils.config.HealthCheck.MaxRedirects, nil)
+	latency := time.Since(start)
+
+	result := repositories.CreateLinkHealthCheckArgs{
+		Latency:       latency,
+		RedirectChain: hops,
+	}
+
+	if err != nil {
+		message := err.Error()
+		result.Error = &message
+		return result
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	statusCode := resp.StatusCode
+	result.StatusCode = &statusCode
+	result.TLSValid = resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 && time.Now().Before(resp.TLS.PeerCertificates[0].NotAfter)
+	result.Healthy = statusCode < http.StatusBadRequest
+
+	// Plain http destinations have no certificate to check; only links that
+	// end on https need a valid one.
+	final, err := url.Parse(hops[len(hops)-1])
+	if err == nil && final.Scheme == "https" && !result.TLSValid {
+		result.Healthy = false
+		message := "destination did not present a valid TLS certificate"
+		result.Error = &message
+	}
+
+	return result
+}
+
+// updateBrokenState flags a link as broken once the configured number of
+// consecutive checks have failed, and clears the flag on the next success.
+func (service *LinkHealthService) updateBrokenState(ctx context.Context, link *entities.LinkEntity, check *entities.LinkHealthCheck) error {
+	if check.Healthy {
+		if link.Broken {
+			service.utils.logger.Info().Ctx(ctx).Str("linkID", link.ID.String()).Msg("Link recovered")
+			return service.linkRepository.SetLinkBroken(ctx, link.ID, false)
+		}
+		return nil
+	}
+
+	if link.Broken {
+		return nil
+	}
+
+	threshold := max(service.utils.config.HealthCheck.FailureThreshold, 1)
+
+	checks, err := service.linkHealthRepository.GetRecentLinkHealthChecks(ctx, link.ID, threshold)
+	if err != nil {
+		return err
+	}
+
+	if len(checks) < threshold {
+		return nil
+	}
+
+	for _, recent := range checks {
+		if recent.Healthy {
+			return nil
+		}
+	}
+
+	service.utils.logger.Warn().Ctx(ctx).Str("linkID", link.ID.String()).Str("linkURL", link.LinkURL).Msg("Link marked as broken")
+
+	err = service.linkRepository.SetLinkBroken(ctx, link.ID, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = service.notificationService.Notify(ctx, NotifyArgs{
+		UserID:  link.OwnerID,
+		LinkID:  &link.ID,
+		Kind:    entities.NotificationLinkBroken,
+		Message: fmt.Sprintf("%s has failed %d health checks in a row and is marked as broken", link.ShortenedURL, threshold),
+	})
+
+	return err
+}
+
+// acquireHost blocks until a check slot for the link's host is free, so no
+// single destination is hit by more than the configured number of checks.
+func (service *LinkHealthService) acquireHost(ctx context.Context, linkURL string) (func(), error) {
+	host := linkURL
+	parsed, err := url.Parse(linkURL)
+	if err == nil {
+		host = strings.ToLower(parsed.Hostname())
+	}
+
+	service.hostSlotsMu.Lock()
+	slot, ok := service.hostSlots[host]
+	if !ok {
+		slot = &hostSlot{slots: make(chan struct{}, max(service.utils.config.HealthCheck.PerHostConcurrency, 1))}
+		service.hostSlots[host] = slot
+	}
+	slot.users++
+	service.hostSlotsMu.Unlock()
+
+	select {
+	case slot.slots <- struct{}{}:
+		return func() {
+			<-slot.slots
+			service.releaseHost(host, slot)
+		}, nil
+	case <-ctx.Done():
+		service.releaseHost(host, slot)
+		return nil, ctx.Err()
+	}
+}
+
+func (service *LinkHealthService) releaseHost(host string, slot *hostSlot) {
+	service.hostSlotsMu.Lock()
+	defer service.hostSlotsMu.Unlock()
+
+	slot.users--
+	if slot.users == 0 {
+		delete(service.hostSlots, host)
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/internal/config"
+)
+
+func newTestLinkHealthService(client HTTPClient) *LinkHealthService {
+	cfg := &config.Config{}
+	cfg.HealthCheck.Timeout = 5 * time.Second
+	cfg.HealthCheck.MaxRedirects = 3
+	cfg.HealthCheck.PerHostConcurrency = 1
+
+	return &LinkHealthService{
+		utils:     ServicesUtils{config: cfg},
+		client:    client,
+		hostSlots: make(map[string]*hostSlot),
+	}
+}
+
+func TestProbe(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+
+	tlsServer := httptest.NewTLSServer(mux)
+	defer tlsServer.Close()
+	plainServer := httptest.NewServer(mux)
+	defer plainServer.Close()
+
+	trusting := tlsServer.Client()
+	trusting.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	untrusting := &http.Client{}
+
+	tests := []struct {
+		name        string
+		client      HTTPClient
+		url         string
+		wantHealthy bool
+		wantStatus  int
+		wantTLS     bool
+		wantHops    int
+		wantError   bool
+	}{
+		{name: "healthy https", client: trusting, url: tlsServer.URL + "/ok", wantHealthy: true, wantStatus: 200, wantTLS: true, wantHops: 1},
+		{name: "healthy plain http", client: trusting, url: plainServer.URL + "/ok", wantHealthy: true, wantStatus: 200, wantHops: 1},
+		{name: "not found", client: trusting, url: tlsServer.URL + "/missing", wantStatus: 404, wantTLS: true, wantHops: 1},
+		{name: "server error", client: trusting, url: tlsServer.URL + "/error", wantStatus: 500, wantTLS: true, wantHops: 1},
+		{name: "redirect", client: trusting, url: tlsServer.URL + "/moved", wantHealthy: true, wantStatus: 200, wantTLS: true, wantHops: 2},
+		{name: "too many redirects", client: trusting, url: tlsServer.URL + "/loop", wantHops: 4, wantError: true},
+		{name: "untrusted certificate", client: untrusting, url: tlsServer.URL + "/ok", wantHops: 1, wantError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestLinkHealthService(tt.client)
+
+			result := service.probe(context.Background(), tt.url)
+
+			if result.Healthy != tt.wantHealthy {
+				t.Errorf("Healthy = %t, want %t", result.Healthy, tt.wantHealthy)
+			}
+			if result.TLSValid != tt.wantTLS {
+				t.Errorf("TLSValid = %t, want %t", result.TLSValid, tt.wantTLS)
+			}
+			if len(result.RedirectChain) != tt.wantHops {
+				t.Errorf("RedirectChain = %v, want %d hops", result.RedirectChain, tt.wantHops)
+			}
+			if (result.Error != nil) != tt.wantError {
+				t.Errorf("Error = %v, want error %t", result.Error, tt.wantError)
+			}
+			if tt.wantStatus == 0 {
+				if result.StatusCode != nil {
+					t.Errorf("StatusCode = %d, want none", *result.StatusCode)
+				}
+			} else if result.StatusCode == nil || *result.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %v, want %d", result.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRedirectlessClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := newRedirectlessHTTPClient(time.Second)
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request to loopback succeeded")
+	}
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Fatalf("err = %v, want ErrPrivateAddress", err)
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			got := isPublicAddress(netip.MustParseAddr(tt.addr))
+			if got != tt.want {
+				t.Errorf("isPublicAddress(%s) = %t, want %t", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAcquireHostReleasesIdleHosts(t *testing.T) {
+	service := newTestLinkHealthService(nil)
+	ctx := context.Background()
+
+	release, err := service.acquireHost(ctx, "https://example.com/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blocked, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = service.acquireHost(blocked, "https://EXAMPLE.com/b")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("second acquire err = %v, want deadline exceeded", err)
+	}
+
+	release()
+
+	if len(service.hostSlots) != 0 {
+		t.Fatalf("hostSlots has %d entries after release, want 0", len(service.hostSlots))
+	}
+}
@@ -3,10 +3,12 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"net/url"
 
 	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/blocklist"
 	"github.com/mcorrigan89/url_shortener/internal/entities"
 	"github.com/mcorrigan89/url_shortener/internal/repositories"
 )
@@ -17,19 +19,28 @@ var (
 	ErrBlockedUser        = errors.New("user is blocked")
 	ErrInvalidURL         = errors.New("invalid URL")
 	ErrInvalidURLProtocol = errors.New("invalid URL protocol")
+	ErrLinkQuotaExceeded  = errors.New("link quota exceeded")
 )
 
 type LinkService struct {
 	utils             ServicesUtils
+	client            HTTPClient
 	linkRepository    *repositories.LinkRepository
 	blockedRepository *repositories.BlockedRepository
+	userRepository    *repositories.UserRepository
+	moderationService *ModerationService
+	workspaceService  *WorkspaceService
 }
 
-func NewLinkService(utils ServicesUtils, repos *repositories.Repositories) *LinkService {
+func NewLinkService(utils ServicesUtils, client HTTPClient, repos *repositories.Repositories, moderationService *ModerationService, workspaceService *WorkspaceService) *LinkService {
 	return &LinkService{
 		utils:             utils,
+		client:            client,
 		linkRepository:    repos.LinkRepository,
 		blockedRepository: repos.BlockedRepository,
+		userRepository:    repos.UserRepository,
+		moderationService: moderationService,
+		workspaceService:  workspaceService,
 	}
 }
 
@@ -66,9 +77,28 @@ func (service *LinkService) GetLinksByUserID(ctx context.Context, userID uuid.UU
 	return links, nil
 }
 
+// GetLinksByWorkspaceID returns the workspace's links to any of its members.
+func (service *LinkService) GetLinksByWorkspaceID(ctx context.Context, workspaceID, userID uuid.UUID) ([]*entities.LinkEntity, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("workspaceID", workspaceID.String()).Msg("Getting links by workspace id")
+
+	_, err := service.workspaceService.GetMembership(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := service.linkRepository.GetLinksByWorkspaceID(ctx, workspaceID)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting links by workspace id")
+		return nil, err
+	}
+
+	return links, nil
+}
+
 type CreateLinkArgs struct {
-	UserID  uuid.UUID
-	LinkURL string
+	UserID      uuid.UUID
+	LinkURL     string
+	WorkspaceID *uuid.UUID
 }
 
 func (service *LinkService) CreateLink(ctx context.Context, args CreateLinkArgs) (*entities.LinkEntity, error) {
@@ -90,28 +120,97 @@ func (service *LinkService) CreateLink(ctx context.Context, args CreateLinkArgs)
 		return nil, ErrInvalidURL
 	}
 
+	_, err = blocklist.NormalizeHost(url.Host)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Str("linkURL", args.LinkURL).Msg("Invalid URL host")
+		return nil, ErrInvalidURL
+	}
+
+	if args.WorkspaceID != nil {
+		member, err := service.workspaceService.GetMembership(ctx, *args.WorkspaceID, args.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !member.CanEditLinks() {
+			return nil, entities.ErrForbidden
+		}
+	}
+
 	err = service.checkIfBlocked(ctx, checkBlockedArgs{
-		Domain: url.Host,
+		URL:    url,
 		UserID: args.UserID,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	err = service.checkQuota(ctx, args.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	quarantineReason, err := service.checkRedirectChain(ctx, args.LinkURL)
+	if err != nil {
+		return nil, err
+	}
+
 	shortendUrlSlug := service.generateShortenedURLSlug()
 	link, err := service.linkRepository.CreateLink(ctx, repositories.CreateLinkArgs{
-		LinkURL:    args.LinkURL,
-		ShortedURL: shortendUrlSlug,
-		CreatedBy:  args.UserID,
+		LinkURL:     args.LinkURL,
+		ShortedURL:  shortendUrlSlug,
+		CreatedBy:   args.UserID,
+		UpdatedBy:   actorID(ctx, args.UserID),
+		Quarantined: quarantineReason != "",
+		WorkspaceID: args.WorkspaceID,
 	})
 	if err != nil {
 		service.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link")
 		return nil, err
 	}
 
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkCreated,
+		TargetType: entities.AuditTargetLink,
+		TargetID:   link.ID.String(),
+		Metadata:   map[string]any{"link_url": link.LinkURL, "quarantined": link.Quarantined},
+	})
+
+	if link.Quarantined {
+		_, err = service.moderationService.OpenReview(ctx, link, entities.QuarantineSourceRedirectCheck, quarantineReason, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return link, nil
 }
 
+// checkQuota stops users who haven't verified their email from creating more
+// than the unverified link quota, which keeps throwaway accounts from being
+// used to spread spam links.
+func (service *LinkService) checkQuota(ctx context.Context, userID uuid.UUID) error {
+	user, err := service.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	count, err := service.linkRepository.CountActiveLinksCreatedBy(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if count >= service.utils.config.EmailVerification.UnverifiedLinkQuota {
+		service.utils.logger.Warn().Ctx(ctx).Str("userID", userID.String()).Int("links", count).Msg("Unverified user reached link quota")
+		return ErrLinkQuotaExceeded
+	}
+
+	return nil
+}
+
 func (service *LinkService) generateShortenedURLSlug() string {
 	var randomChars = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0987654321")
 
@@ -132,20 +231,66 @@ type UpdateLinkArgs struct {
 func (service *LinkService) UpdateLink(ctx context.Context, args UpdateLinkArgs) (*entities.LinkEntity, error) {
 	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Updating link")
 
+	existing, err := service.linkRepository.GetLinkByID(ctx, args.LinkID)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := service.CanEditLink(ctx, existing, args.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !canEdit {
+		return nil, entities.ErrForbidden
+	}
+
 	link, err := service.linkRepository.UpdateLink(ctx, repositories.UpdateLinkArgs{
 		ID:        args.LinkID,
 		LinkURL:   args.LinkURL,
 		Active:    args.Active,
-		UpdatedBy: args.UserID,
+		UpdatedBy: actorID(ctx, args.UserID),
 	})
 	if err != nil {
 		service.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link")
 		return nil, err
 	}
 
+	metadata := map[string]any{}
+	if args.LinkURL != nil {
+		metadata["link_url"] = *args.LinkURL
+	}
+	if args.Active != nil {
+		metadata["active"] = *args.Active
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkUpdated,
+		TargetType: entities.AuditTargetLink,
+		TargetID:   link.ID.String(),
+		Metadata:   metadata,
+	})
+
 	return link, nil
 }
 
+// CanEditLink reports whether the user may change the link: its creator can,
+// and so can owners and editors of the workspace it belongs to.
+func (service *LinkService) CanEditLink(ctx context.Context, link *entities.LinkEntity, userID uuid.UUID) (bool, error) {
+	if link.WorkspaceID == nil {
+		return link.OwnerID == userID, nil
+	}
+
+	member, err := service.workspaceService.GetMembership(ctx, *link.WorkspaceID, userID)
+	if err != nil {
+		if errors.Is(err, entities.ErrForbidden) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return member.CanEditLinks(), nil
+}
+
 func (service *LinkService) IsDomainBlocked(ctx context.Context, linkUrl string) error {
 
 	url, err := url.Parse(linkUrl)
@@ -154,7 +299,7 @@ func (service *LinkService) IsDomainBlocked(ctx context.Context, linkUrl string)
 		return err
 	}
 
-	blocked, err := service.blockedRepository.IsDomainBlocked(ctx, url.Host)
+	blocked, err := service.isURLBlocked(ctx, url)
 	if err != nil {
 		return err
 	}
@@ -166,15 +311,41 @@ func (service *LinkService) IsDomainBlocked(ctx context.Context, linkUrl string)
 	return nil
 }
 
+// isURLBlocked checks u's host against the blocked domains, including parent
+// domains and wildcards, and the full URL against the regex and path prefix
+// rules.
+func (service *LinkService) isURLBlocked(ctx context.Context, u *url.URL) (bool, error) {
+	blocked, err := service.blockedRepository.IsDomainBlocked(ctx, u.Host)
+	if err != nil {
+		return true, err
+	}
+	if blocked {
+		return true, nil
+	}
+
+	rules, err := service.blockedRepository.GetBlockedPatterns(ctx)
+	if err != nil {
+		return true, err
+	}
+
+	for _, rule := range rules {
+		if rule.Matches(u) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
 type checkBlockedArgs struct {
-	Domain  string
+	URL     *url.URL
 	UserID  uuid.UUID
 	EventID *uuid.UUID
 }
 
 func (service *LinkService) checkIfBlocked(ctx context.Context, args checkBlockedArgs) error {
 	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Checking if blocked")
-	blocked, err := service.blockedRepository.IsDomainBlocked(ctx, args.Domain)
+	blocked, err := service.isURLBlocked(ctx, args.URL)
 	if err != nil {
 		return err
 	}
@@ -194,3 +365,53 @@ func (service *LinkService) checkIfBlocked(ctx context.Context, args checkBlocke
 
 	return nil
 }
+
+// checkRedirectChain follows the destination's redirects so that wrapping a
+// blocked domain in another shortener can't get around the block list. A hop
+// on a blocked domain rejects the link; a chain too long to follow returns
+// the reason the link should be quarantined instead. The client only dials
+// public addresses.
+func (service *LinkService) checkRedirectChain(ctx context.Context, linkURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, service.utils.config.RedirectCheck.Timeout)
+	defer cancel()
+
+	var lookupErr error
+
+	resp, hops, err := resolveURL(ctx, service.client, linkURL, service.utils.config.RedirectCheck.MaxHops, func(hop *url.URL) error {
+		blocked, err := service.isURLBlocked(ctx, hop)
+		if err != nil {
+			lookupErr = err
+			return err
+		}
+		if blocked {
+			return ErrBlockedDomain
+		}
+		return nil
+	})
+
+	if lookupErr != nil {
+		return "", lookupErr
+	}
+
+	if errors.Is(err, ErrBlockedDomain) {
+		service.utils.logger.Err(ErrBlockedDomain).Ctx(ctx).Strs("redirectChain", hops).Msg("Attempt to create link that redirects to a blocked domain")
+		return "", ErrBlockedDomain
+	}
+
+	// A chain longer than we follow could be hiding a blocked hop. Anything
+	// else that stops the walk is a network failure, which says nothing about
+	// the link and would tell the creator what the server can reach, so the
+	// link is created as normal and left to the health checks.
+	if errors.Is(err, ErrTooManyRedirects) {
+		service.utils.logger.Warn().Ctx(ctx).Strs("redirectChain", hops).Msg("Redirect chain too long, quarantining link")
+		return fmt.Sprintf("Redirect chain is longer than %d hops", service.utils.config.RedirectCheck.MaxHops), nil
+	}
+
+	if err != nil {
+		service.utils.logger.Warn().Err(err).Ctx(ctx).Strs("redirectChain", hops).Msg("Unable to resolve redirect chain")
+		return "", nil
+	}
+	resp.Body.Close()
+
+	return "", nil
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/blocklist"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+)
+
+var (
+	ErrAlreadyQuarantined = errors.New("link is already quarantined")
+)
+
+// systemActorID is recorded as the actor for changes made by the service itself
+// rather than by a signed in user.
+var systemActorID = uuid.Nil
+
+type ModerationService struct {
+	utils                ServicesUtils
+	linkRepository       *repositories.LinkRepository
+	quarantineRepository *repositories.QuarantineRepository
+	blockedRepository    *repositories.BlockedRepository
+	userRepository       *repositories.UserRepository
+	linkHealthRepository *repositories.LinkHealthRepository
+	notificationService  *NotificationService
+}
+
+func NewModerationService(utils ServicesUtils, repos *repositories.Repositories, notificationService *NotificationService) *ModerationService {
+	return &ModerationService{
+		utils:                utils,
+		linkRepository:       repos.LinkRepository,
+		quarantineRepository: repos.QuarantineRepository,
+		blockedRepository:    repos.BlockedRepository,
+		userRepository:       repos.UserRepository,
+		linkHealthRepository: repos.LinkHealthRepository,
+		notificationService:  notificationService,
+	}
+}
+
+type QuarantineLinkArgs struct {
+	LinkID     uuid.UUID
+	Source     string
+	Reason     string
+	ReporterID *uuid.UUID
+	ActorID    uuid.UUID
+}
+
+// QuarantineLink takes a link out of service and puts it in the review queue.
+func (service *ModerationService) QuarantineLink(ctx context.Context, args QuarantineLinkArgs) (*entities.LinkQuarantine, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Quarantining link")
+
+	link, err := service.linkRepository.GetLinkByID(ctx, args.LinkID)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting link to quarantine")
+		return nil, err
+	}
+
+	if link.Quarantined {
+		return nil, ErrAlreadyQuarantined
+	}
+
+	quarantined := true
+	link, err = service.linkRepository.UpdateLink(ctx, repositories.UpdateLinkArgs{
+		ID:          link.ID,
+		UpdatedBy:   args.ActorID,
+		Quarantined: &quarantined,
+		Reason:      &args.Reason,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error quarantining link")
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkUpdated,
+		TargetType: entities.AuditTargetLink,
+		TargetID:   link.ID.String(),
+		ActorID:    &args.ActorID,
+		Metadata:   map[string]any{"quarantined": true, "source": args.Source, "reason": args.Reason},
+	})
+
+	return service.OpenReview(ctx, link, args.Source, args.Reason, args.ReporterID)
+}
+
+// OpenReview adds an already quarantined link to the review queue and lets the
+// owner know.
+func (service *ModerationService) OpenReview(ctx context.Context, link *entities.LinkEntity, source, reason string, reporterID *uuid.UUID) (*entities.LinkQuarantine, error) {
+	quarantine, err := service.quarantineRepository.CreateLinkQuarantine(ctx, repositories.CreateLinkQuarantineArgs{
+		LinkID:     link.ID,
+		Source:     source,
+		Reason:     reason,
+		ReporterID: reporterID,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error opening quarantine review")
+		return nil, err
+	}
+
+	service.notifyOwner(ctx, link, entities.NotificationLinkQuarantined, fmt.Sprintf("%s has been quarantined pending review: %s", link.ShortenedURL, reason))
+
+	return quarantine, nil
+}
+
+func (service *ModerationService) GetQuarantineQueue(ctx context.Context) ([]*entities.QuarantineReview, error) {
+	service.utils.logger.Info().Ctx(ctx).Msg("Getting quarantine queue")
+
+	err := service.utils.authorize(ctx, entities.PermissionModerateLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	reviews, err := service.quarantineRepository.GetPendingLinkQuarantines(ctx)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting quarantine queue")
+		return nil, err
+	}
+
+	for _, review := range reviews {
+		owner, err := service.userRepository.GetUserByID(ctx, review.Link.OwnerID)
+		if err == nil {
+			review.Owner = owner
+		}
+
+		if review.Quarantine.ReporterID != nil {
+			reporter, err := service.userRepository.GetUserByID(ctx, *review.Quarantine.ReporterID)
+			if err == nil {
+				review.Reporter = reporter
+			}
+		}
+
+		checks, err := service.linkHealthRepository.GetRecentLinkHealthChecks(ctx, review.Link.ID, 1)
+		if err == nil && len(checks) > 0 {
+			review.LatestCheck = checks[0]
+		}
+	}
+
+	return reviews, nil
+}
+
+// ApproveQuarantine returns the link to service.
+func (service *ModerationService) ApproveQuarantine(ctx context.Context, quarantineID uuid.UUID, moderatorID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("quarantineID", quarantineID.String()).Msg("Approving quarantined link")
+
+	err := service.utils.authorize(ctx, entities.PermissionModerateLinks)
+	if err != nil {
+		return err
+	}
+
+	quarantined := false
+	reason := "Approved by moderator"
+	_, link, err := service.quarantineRepository.ResolveLinkQuarantine(ctx, repositories.ResolveLinkQuarantineArgs{
+		ID:          quarantineID,
+		Status:      entities.QuarantineStatusApproved,
+		DecidedBy:   moderatorID,
+		Quarantined: &quarantined,
+		Reason:      &reason,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error approving quarantined link")
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkUpdated,
+		TargetType: entities.AuditTargetLink,
+		TargetID:   link.ID.String(),
+		Metadata:   map[string]any{"quarantined": false, "reason": reason},
+	})
+
+	service.notifyOwner(ctx, link, entities.NotificationLinkRestored, fmt.Sprintf("%s has been reviewed and is live again", link.ShortenedURL))
+
+	return nil
+}
+
+// RejectQuarantine deactivates the link and leaves it quarantined.
+func (service *ModerationService) RejectQuarantine(ctx context.Context, quarantineID uuid.UUID, moderatorID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("quarantineID", quarantineID.String()).Msg("Rejecting quarantined link")
+
+	err := service.utils.authorize(ctx, entities.PermissionModerateLinks)
+	if err != nil {
+		return err
+	}
+
+	return service.reject(ctx, quarantineID, moderatorID, "Rejected by moderator")
+}
+
+// BlockQuarantinedDomain adds the link's destination domain to the block list
+// and rejects the link.
+func (service *ModerationService) BlockQuarantinedDomain(ctx context.Context, quarantineID uuid.UUID, moderatorID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("quarantineID", quarantineID.String()).Msg("Blocking domain of quarantined link")
+
+	err := service.utils.authorize(ctx, entities.PermissionModerateLinks)
+	if err != nil {
+		return err
+	}
+
+	quarantine, err := service.quarantineRepository.GetLinkQuarantineByID(ctx, quarantineID)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting quarantine")
+		return err
+	}
+
+	link, err := service.linkRepository.GetLinkByID(ctx, quarantine.LinkID)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting quarantined link")
+		return err
+	}
+
+	linkURL, err := url.Parse(link.LinkURL)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error parsing URL")
+		return err
+	}
+
+	// Block the normalised host so the entry matches however the domain is
+	// spelled, with or without a port.
+	host, err := blocklist.NormalizeHost(linkURL.Host)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error normalizing host")
+		return err
+	}
+
+	reason := fmt.Sprintf("Blocked while reviewing %s", link.ShortenedURL)
+	domain, err := service.blockedRepository.BlockDomain(ctx, repositories.BlockDomainArgs{
+		Domain:    host,
+		Reason:    &reason,
+		CreatedBy: &moderatorID,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error blocking domain")
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditDomainBlocked,
+		TargetType: entities.AuditTargetDomain,
+		TargetID:   domain,
+		Metadata:   map[string]any{"reason": reason},
+	})
+
+	return service.reject(ctx, quarantineID, moderatorID, fmt.Sprintf("Rejected by moderator, %s blocked", domain))
+}
+
+func (service *ModerationService) reject(ctx context.Context, quarantineID uuid.UUID, moderatorID uuid.UUID, reason string) error {
+	active := false
+	_, link, err := service.quarantineRepository.ResolveLinkQuarantine(ctx, repositories.ResolveLinkQuarantineArgs{
+		ID:        quarantineID,
+		Status:    entities.QuarantineStatusRejected,
+		DecidedBy: moderatorID,
+		Active:    &active,
+		Reason:    &reason,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error rejecting quarantined link")
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkDeactivated,
+		TargetType: entities.AuditTargetLink,
+		TargetID:   link.ID.String(),
+		Metadata:   map[string]any{"reason": reason},
+	})
+
+	service.notifyOwner(ctx, link, entities.NotificationLinkRejected, fmt.Sprintf("%s has been reviewed and deactivated", link.ShortenedURL))
+
+	return nil
+}
+
+func (service *ModerationService) notifyOwner(ctx context.Context, link *entities.LinkEntity, kind, message string) {
+	_, err := service.notificationService.Notify(ctx, NotifyArgs{
+		UserID:  link.OwnerID,
+		LinkID:  &link.ID,
+		Kind:    kind,
+		Message: message,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Str("linkID", link.ID.String()).Msg("Error notifying link owner")
+	}
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+)
+
+type NotificationService struct {
+	utils                  ServicesUtils
+	notificationRepository *repositories.NotificationRepository
+}
+
+func NewNotificationService(utils ServicesUtils, notificationRepo *repositories.NotificationRepository) *NotificationService {
+	return &NotificationService{
+		utils:                  utils,
+		notificationRepository: notificationRepo,
+	}
+}
+
+type NotifyArgs struct {
+	UserID  uuid.UUID
+	LinkID  *uuid.UUID
+	Kind    string
+	Message string
+}
+
+func (service *NotificationService) Notify(ctx context.Context, args NotifyArgs) (*entities.Notification, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Notifying user")
+
+	notification, err := service.notificationRepository.CreateNotification(ctx, repositories.CreateNotificationArgs{
+		UserID:  args.UserID,
+		LinkID:  args.LinkID,
+		Kind:    args.Kind,
+		Message: args.Message,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error notifying user")
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+func (service *NotificationService) GetUnreadNotifications(ctx context.Context, userID uuid.UUID) ([]*entities.Notification, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Getting unread notifications")
+
+	notifications, err := service.notificationRepository.GetUnreadNotificationsByUserID(ctx, userID)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting unread notifications")
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (service *NotificationService) MarkRead(ctx context.Context, notificationID, userID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("notificationID", notificationID.String()).Msg("Marking notification read")
+
+	err := service.notificationRepository.MarkNotificationRead(ctx, notificationID, userID)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error marking notification read")
+		return err
+	}
+
+	return nil
+}
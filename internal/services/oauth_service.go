@@ -2,170 +2,509 @@ package services
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"fmt"
-	"io"
-	"net/http"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/oidc"
 	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
 )
 
+var (
+	ErrUnknownProvider = errors.New("unknown login provider")
+	ErrLoginAttempt    = errors.New("login attempt is invalid or expired")
+)
+
+// loginAttemptTTL is how long someone has to finish signing in at the
+// provider.
+const loginAttemptTTL = 10 * time.Minute
+
+// loginAttemptPrefix keeps attempt tokens from being accepted as any other
+// kind of signed token.
+const loginAttemptPrefix = "login-attempt:"
+
+// loginProvider is an identity provider users can log in with: an OpenID
+// Connect provider, or GitHub, which has its own OAuth flow.
+type loginProvider interface {
+	Name() string
+	AuthCodeURL(ctx context.Context, req oidc.AuthRequest) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier string) (*oidc.Token, error)
+	Identity(ctx context.Context, token *oidc.Token, nonce string) (*oidc.Identity, error)
+}
+
 type OAuthService struct {
 	utils          ServicesUtils
 	userService    *UserService
 	userRepository *repositories.UserRepository
+	providers      map[string]loginProvider
+	signer         *tokens.Signer
 }
 
-func NewOAuthService(utils ServicesUtils, userService *UserService, userRepo *repositories.UserRepository) *OAuthService {
+func NewOAuthService(utils ServicesUtils, userService *UserService, userRepo *repositories.UserRepository, providers []loginProvider, signer *tokens.Signer) *OAuthService {
+	byName := make(map[string]loginProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
 	return &OAuthService{
 		utils:          utils,
 		userService:    userService,
 		userRepository: userRepo,
+		providers:      byName,
+		signer:         signer,
+	}
+}
+
+// loginAttempt is what the callback needs to check that the provider's
+// response belongs to the browser that started the login.
+type loginAttempt struct {
+	Provider     string `json:"p"`
+	State        string `json:"s"`
+	Nonce        string `json:"n"`
+	CodeVerifier string `json:"v"`
+	ReturnTo     string `json:"r,omitempty"`
+	// LinkUserID is set when a signed in user is connecting the provider to
+	// their account rather than logging in.
+	LinkUserID *uuid.UUID `json:"u,omitempty"`
+}
+
+// pendingLink is a provider account waiting for its owner to confirm that it
+// should be joined to the existing user with the same email.
+type pendingLink struct {
+	UserID     uuid.UUID `json:"u"`
+	Email      string    `json:"e"`
+	Provider   string    `json:"p"`
+	ProviderID string    `json:"i"`
+	ReturnTo   string    `json:"r,omitempty"`
+}
+
+// pendingLinkPrefix keeps pending link tokens from being accepted as any
+// other kind of signed token.
+const pendingLinkPrefix = "link:"
+
+// pendingLinkTTL is how long someone has to confirm joining accounts.
+const pendingLinkTTL = 15 * time.Minute
+
+type LoginStart struct {
+	AuthURL string
+	// Attempt is a signed token for the browser to hold until the callback.
+	Attempt   string
+	ExpiresAt time.Time
+}
+
+func (service *OAuthService) provider(name string) (loginProvider, error) {
+	provider, ok := service.providers[name]
+	if !ok {
+		return nil, ErrUnknownProvider
 	}
+	return provider, nil
 }
 
-type GoogleTokenResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int    `json:"expires_in"`
-	TokenType    string `json:"token_type"`
-	Scope        string `json:"scope"`
-	IDToken      string `json:"id_token"`
+// StartLogin begins a login with the provider. It returns where to send the
+// user and an attempt token holding a fresh state, nonce and PKCE verifier,
+// which must come back to LoginWithCode. returnTo must already be validated.
+func (service *OAuthService) StartLogin(ctx context.Context, providerName, returnTo string) (*LoginStart, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("provider", providerName).Msg("Starting OAuth login")
+
+	return service.start(ctx, loginAttempt{Provider: providerName, ReturnTo: returnTo})
 }
 
-func (service *OAuthService) LoginWithGoogleCode(ctx context.Context, code string) (*entities.UserSession, error) {
-	tokenResponse, err := service.getGoogleTokenFromCode(ctx, code)
+// StartLink begins connecting the provider to the user's account. It works
+// like StartLogin, but the callback adds the provider account to the user
+// instead of logging in.
+func (service *OAuthService) StartLink(ctx context.Context, providerName string, userID uuid.UUID) (*LoginStart, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("provider", providerName).Str("userID", userID.String()).Msg("Starting OAuth link")
+
+	return service.start(ctx, loginAttempt{Provider: providerName, LinkUserID: &userID})
+}
+
+func (service *OAuthService) start(ctx context.Context, attempt loginAttempt) (*LoginStart, error) {
+	provider, err := service.provider(attempt.Provider)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get Google Token")
 		return nil, err
 	}
 
-	user, err := service.greateGoogleUser(ctx, tokenResponse)
+	for _, value := range []*string{&attempt.State, &attempt.Nonce, &attempt.CodeVerifier} {
+		*value, err = tokens.Generate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, oidc.AuthRequest{
+		State:        attempt.State,
+		Nonce:        attempt.Nonce,
+		CodeVerifier: attempt.CodeVerifier,
+	})
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create Google User")
+		service.utils.logger.Err(err).Ctx(ctx).Str("provider", attempt.Provider).Msg("Failed to build authorization URL")
 		return nil, err
 	}
 
-	tokenJson, err := json.Marshal(tokenResponse)
+	payload, err := json.Marshal(attempt)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to marshal Google Token")
 		return nil, err
 	}
 
-	var userEntity *entities.User
+	expiresAt := time.Now().Add(loginAttemptTTL)
+
+	return &LoginStart{
+		AuthURL:   authURL,
+		Attempt:   service.signer.Sign(loginAttemptPrefix+string(payload), expiresAt),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+type LoginWithCodeArgs struct {
+	Provider string
+	Code     string
+	State    string
+	// Attempt is the token StartLogin or StartLink returned.
+	Attempt string
+	// UserID is the signed in user, or uuid.Nil when nobody is.
+	UserID uuid.UUID
+}
+
+// PendingLink asks the user to confirm joining a provider account to the
+// existing user with the same email. Token goes back to ConfirmLink.
+type PendingLink struct {
+	Token     string
+	Email     string
+	Provider  string
+	ExpiresAt time.Time
+}
 
-	userEntity, err = service.userRepository.GetUserByProviderID(ctx, user.ID, "google")
+// LoginResult is how a provider callback ended. Exactly one of Session,
+// Linked and PendingLink is set.
+type LoginResult struct {
+	// Session is the new session when the user logged in.
+	Session *entities.UserSession
+	// Linked is set when the provider was connected to the signed in user.
+	Linked      bool
+	PendingLink *PendingLink
+	ReturnTo    string
+}
+
+// LoginWithCode completes a login or link with the authorization code the
+// provider sent back. Logins create the user on their first visit, unless the
+// email already belongs to a user, in which case joining the two has to be
+// confirmed first.
+func (service *OAuthService) LoginWithCode(ctx context.Context, args LoginWithCodeArgs) (*LoginResult, error) {
+	providerName := args.Provider
+
+	service.utils.logger.Info().Ctx(ctx).Str("provider", providerName).Msg("Logging in with OAuth code")
+
+	provider, err := service.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	attempt, err := service.verifyAttempt(args)
+	if err != nil {
+		service.utils.logger.Warn().Ctx(ctx).Err(err).Str("provider", providerName).Msg("Rejected OAuth callback")
+		return nil, err
+	}
+
+	token, err := provider.Exchange(ctx, args.Code, attempt.CodeVerifier)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Str("provider", providerName).Msg("Failed to exchange code")
+		return nil, err
+	}
+
+	identity, err := provider.Identity(ctx, token, attempt.Nonce)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Str("provider", providerName).Msg("Failed to verify identity")
+		return nil, err
+	}
+
+	if attempt.LinkUserID != nil {
+		err = service.link(ctx, *attempt.LinkUserID, providerName, identity.Subject)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{Linked: true, ReturnTo: attempt.ReturnTo}, nil
+	}
+
+	userEntity, err := service.userRepository.GetUserByProviderID(ctx, identity.Subject, providerName)
 	if err != nil {
 		if err != entities.ErrUserNotFound {
-			service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get User by Google ID")
+			service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get User by provider ID")
+			return nil, err
+		}
+
+		pending, err := service.offerLink(ctx, providerName, identity, attempt.ReturnTo)
+		if err != nil {
+			return nil, err
+		}
+		if pending != nil {
+			return &LoginResult{PendingLink: pending, ReturnTo: attempt.ReturnTo}, nil
+		}
+
+		claims, err := json.Marshal(identity.Claims)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the verified claims are kept; the provider's tokens are not
+		// needed after login and are not stored.
+		// Only addresses the provider vouches for count as verified.
+		userEntity, err = service.userRepository.CreateUserOAuth(ctx, repositories.CreateUserOAuthArgs{
+			GivenName:     identity.GivenName,
+			FamilyName:    identity.FamilyName,
+			Email:         identity.Email,
+			EmailVerified: identity.EmailVerified,
+			AvatarUrl:     identity.Picture,
+			Provider:      providerName,
+			ProviderID:    identity.Subject,
+			ProviderData:  claims,
+		})
+		if err != nil {
+			service.utils.logger.Err(err).Ctx(ctx).Str("provider", providerName).Msg("Failed to create User from provider identity")
+			return nil, err
+		}
+	} else if !userEntity.EmailVerified && identity.EmailVerified && strings.EqualFold(userEntity.Email, identity.Email) {
+		_, err = service.userRepository.SetUserEmailVerified(ctx, userEntity.ID, userEntity.Email)
+		if err != nil {
 			return nil, err
-		} else {
-			userEntity, err = service.userRepository.CreateUserOAuth(ctx, repositories.CreateUserOAuthArgs{
-				GivenName:    user.GivenName,
-				FamilyName:   user.FamilyName,
-				Email:        user.Email,
-				AvatarUrl:    user.Picture,
-				Value:        tokenResponse.AccessToken,
-				Provider:     "google",
-				ProviderID:   user.ID,
-				ProviderData: tokenJson,
-			})
-
-			if err != nil {
-				service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create User from Google")
-				return nil, err
-			}
 		}
+		service.utils.audit(ctx, auditArgs{
+			Action:     entities.AuditEmailVerified,
+			TargetType: entities.AuditTargetUser,
+			TargetID:   userEntity.ID.String(),
+			ActorID:    &userEntity.ID,
+			Metadata:   map[string]any{"method": providerName},
+		})
 	}
 
-	userSessionEntity, err := service.userRepository.CreateUserSession(ctx, repositories.CreateUserSessionArgs{
-		UserID: userEntity.ID,
-	})
+	userSessionEntity, err := service.login(ctx, userEntity.ID, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LoginResult{Session: userSessionEntity, ReturnTo: attempt.ReturnTo}, nil
+}
 
+func (service *OAuthService) login(ctx context.Context, userID uuid.UUID, providerName string) (*entities.UserSession, error) {
+	sessionArgs, err := service.userService.loginSessionArgs(ctx, userID)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create User Session from Google")
 		return nil, err
 	}
 
+	userSessionEntity, err := service.userRepository.CreateUserSession(ctx, sessionArgs)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create User Session from OAuth login")
+		return nil, err
+	}
+
+	service.utils.auditLogin(ctx, userID, userSessionEntity, providerName)
+
 	return userSessionEntity, nil
 }
 
-func (service *OAuthService) getGoogleTokenFromCode(ctx context.Context, code string) (*GoogleTokenResponse, error) {
-	service.utils.logger.Info().Ctx(ctx).Str("code", code).Msg("Getting Google Auth with Code")
-	clientID := service.utils.config.OAuth.Google.ClientID
-	clientSecret := service.utils.config.OAuth.Google.ClientSecret
-	redirectUrl := fmt.Sprintf("%s/callback/google", service.utils.config.ClientURL)
+// link adds the provider account to the user. Linking an account the user
+// already has is not an error.
+func (service *OAuthService) link(ctx context.Context, userID uuid.UUID, providerName, providerID string) error {
+	owner, err := service.userRepository.GetUserByProviderID(ctx, providerID, providerName)
+	if err == nil {
+		if owner.ID == userID {
+			return nil
+		}
+		return entities.ErrIdentityInUse
+	}
+	if err != entities.ErrUserNotFound {
+		return err
+	}
+
+	created, err := service.userRepository.CreateUserIdentity(ctx, repositories.CreateUserIdentityArgs{
+		UserID:     userID,
+		Provider:   providerName,
+		ProviderID: providerID,
+	})
+	if err != nil {
+		return err
+	}
 
-	url := fmt.Sprintf("https://oauth2.googleapis.com/token?code=%s&client_id=%s&client_secret=%s&redirect_uri=%s&grant_type=authorization_code", code, clientID, clientSecret, redirectUrl)
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditIdentityLinked,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+		Metadata:   map[string]any{"provider": providerName, "identity_id": created.ID.String()},
+	})
 
-	resp, err := http.Post(url, "application/json", nil)
+	return nil
+}
+
+// offerLink returns a pending link when the identity's email belongs to an
+// existing user, and nil when it is free. Joining is only offered when both
+// sides have verified the address; otherwise it is ErrDuplicateEmail and the
+// user has to log in and connect the provider from settings.
+func (service *OAuthService) offerLink(ctx context.Context, providerName string, identity *oidc.Identity, returnTo string) (*PendingLink, error) {
+	existing, err := service.userRepository.GetUserByEmail(ctx, identity.Email)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get Google Token")
+		if err == entities.ErrUserNotFound {
+			return nil, nil
+		}
 		return nil, err
 	}
 
-	defer resp.Body.Close()
+	if !identity.EmailVerified || !existing.EmailVerified {
+		return nil, entities.ErrDuplicateEmail
+	}
+	if existing.Identity(providerName) != nil {
+		// The user already has a different account with this provider.
+		return nil, entities.ErrProviderAlreadyLinked
+	}
 
-	body, err := io.ReadAll(resp.Body)
+	payload, err := json.Marshal(pendingLink{
+		UserID:     existing.ID,
+		Email:      existing.Email,
+		Provider:   providerName,
+		ProviderID: identity.Subject,
+		ReturnTo:   returnTo,
+	})
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to read Google Token response")
 		return nil, err
 	}
 
-	var tokenResponse GoogleTokenResponse
-	err = json.Unmarshal(body, &tokenResponse)
+	expiresAt := time.Now().Add(pendingLinkTTL)
+
+	return &PendingLink{
+		Token:     service.signer.Sign(pendingLinkPrefix+string(payload), expiresAt),
+		Email:     existing.Email,
+		Provider:  providerName,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// GetPendingLink reads a pending link token back, for the confirmation page.
+func (service *OAuthService) GetPendingLink(ctx context.Context, token string) (*PendingLink, error) {
+	link, err := service.verifyPendingLink(token)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to unmarshal Google Token response")
 		return nil, err
 	}
 
-	return &tokenResponse, nil
+	return &PendingLink{
+		Token:    token,
+		Email:    link.Email,
+		Provider: link.Provider,
+	}, nil
 }
 
-type googleUser struct {
-	ID            string  `json:"id"`
-	Email         string  `json:"email"`
-	VerifiedEmail bool    `json:"verified_email"`
-	Name          *string `json:"name,omitempty"`
-	GivenName     *string `json:"given_name,omitempty"`
-	FamilyName    *string `json:"family_name,omitempty"`
-	Picture       *string `json:"picture,omitempty"`
+// ConfirmLink joins the provider account in a pending link to the existing
+// user and logs them in. It returns the new session and where the user asked
+// to go after the original login.
+func (service *OAuthService) ConfirmLink(ctx context.Context, token string) (*entities.UserSession, string, error) {
+	link, err := service.verifyPendingLink(token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	service.utils.logger.Info().Ctx(ctx).Str("provider", link.Provider).Str("userID", link.UserID.String()).Msg("Confirming account link")
+
+	// The user may have changed their email since the link was offered.
+	userEntity, err := service.userRepository.GetUserByID(ctx, link.UserID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !strings.EqualFold(userEntity.Email, link.Email) || !userEntity.EmailVerified {
+		return nil, "", ErrLoginAttempt
+	}
+
+	err = service.link(ctx, userEntity.ID, link.Provider, link.ProviderID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session, err := service.login(ctx, userEntity.ID, link.Provider)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return session, link.ReturnTo, nil
 }
 
-func (service *OAuthService) greateGoogleUser(ctx context.Context, tokenResponse *GoogleTokenResponse) (*googleUser, error) {
-	service.utils.logger.Info().Ctx(ctx).Msg("Creating Google User")
+func (service *OAuthService) verifyPendingLink(token string) (*pendingLink, error) {
+	payload, err := service.signer.Verify(token)
+	if err != nil {
+		return nil, ErrLoginAttempt
+	}
+
+	data, ok := strings.CutPrefix(payload, pendingLinkPrefix)
+	if !ok {
+		return nil, ErrLoginAttempt
+	}
 
-	client := &http.Client{}
+	var link pendingLink
+	err = json.Unmarshal([]byte(data), &link)
+	if err != nil || link.UserID == uuid.Nil {
+		return nil, ErrLoginAttempt
+	}
 
-	req, err := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v1/userinfo", nil)
+	return &link, nil
+}
+
+// Disconnect removes one of the user's ways to log in. The last one can't be
+// removed.
+func (service *OAuthService) Disconnect(ctx context.Context, userID, identityID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Str("identityID", identityID.String()).Msg("Disconnecting identity")
+
+	userEntity, err := service.userRepository.GetUserByID(ctx, userID)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create Google User request")
-		return nil, err
+		return err
 	}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", tokenResponse.AccessToken))
+	if len(userEntity.Identities) <= 1 {
+		return entities.ErrLastIdentity
+	}
 
-	resp, err := client.Do(req)
+	identity, err := service.userRepository.DeleteUserIdentity(ctx, userID, identityID)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get Google User")
-		return nil, err
+		return err
 	}
 
-	defer resp.Body.Close()
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditIdentityUnlinked,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+		Metadata:   map[string]any{"provider": identity.Provider, "identity_id": identity.ID.String()},
+	})
+
+	return nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// verifyAttempt checks that the callback carries the state of a login this
+// browser started with the same provider, which stops login CSRF and codes
+// injected from another session. A link must also come back to the user who
+// started it.
+func (service *OAuthService) verifyAttempt(args LoginWithCodeArgs) (*loginAttempt, error) {
+	payload, err := service.signer.Verify(args.Attempt)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to read Google User response")
-		return nil, err
+		return nil, ErrLoginAttempt
+	}
+
+	data, ok := strings.CutPrefix(payload, loginAttemptPrefix)
+	if !ok {
+		return nil, ErrLoginAttempt
 	}
 
-	var user googleUser
-	err = json.Unmarshal(body, &user)
+	var attempt loginAttempt
+	err = json.Unmarshal([]byte(data), &attempt)
 	if err != nil {
-		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to unmarshal Google User response")
-		return nil, err
+		return nil, ErrLoginAttempt
+	}
+
+	if attempt.Provider != args.Provider || subtle.ConstantTimeCompare([]byte(attempt.State), []byte(args.State)) != 1 {
+		return nil, ErrLoginAttempt
+	}
+
+	if attempt.LinkUserID != nil && *attempt.LinkUserID != args.UserID {
+		return nil, ErrLoginAttempt
 	}
 
-	return &user, nil
+	return &attempt, nil
 }
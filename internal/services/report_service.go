@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+)
+
+type ReportService struct {
+	utils             ServicesUtils
+	linkRepository    *repositories.LinkRepository
+	reportRepository  *repositories.ReportRepository
+	moderationService *ModerationService
+}
+
+func NewReportService(utils ServicesUtils, repos *repositories.Repositories, moderationService *ModerationService) *ReportService {
+	return &ReportService{
+		utils:             utils,
+		linkRepository:    repos.LinkRepository,
+		reportRepository:  repos.ReportRepository,
+		moderationService: moderationService,
+	}
+}
+
+type ReportLinkArgs struct {
+	Slug       string
+	ReporterID *uuid.UUID
+	IPAddress  string
+	Category   string
+	Comment    *string
+}
+
+// ReportLink records an abuse report, one per address per link, and sends the
+// link to the moderation queue once enough distinct addresses have reported it.
+func (service *ReportService) ReportLink(ctx context.Context, args ReportLinkArgs) (*entities.LinkReport, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("slug", args.Slug).Str("category", args.Category).Msg("Reporting link")
+
+	link, err := service.linkRepository.GetLinkByShortenedURL(ctx, args.Slug)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error getting reported link")
+		return nil, err
+	}
+
+	report, err := service.reportRepository.CreateLinkReport(ctx, repositories.CreateLinkReportArgs{
+		LinkID:     link.ID,
+		ReporterID: args.ReporterID,
+		IPAddress:  args.IPAddress,
+		Category:   args.Category,
+		Comment:    args.Comment,
+	})
+	if err != nil {
+		if !errors.Is(err, entities.ErrDuplicateReport) {
+			service.utils.logger.Err(err).Ctx(ctx).Msg("Error creating link report")
+		}
+		return nil, err
+	}
+
+	count, err := service.reportRepository.CountLinkReports(ctx, link.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := service.utils.config.Reports.QuarantineThreshold
+	if threshold > 0 && count >= threshold && !link.Quarantined {
+		_, err = service.moderationService.QuarantineLink(ctx, QuarantineLinkArgs{
+			LinkID:     link.ID,
+			Source:     entities.QuarantineSourceReport,
+			Reason:     fmt.Sprintf("Reported by %d people, latest report: %s", count, args.Category),
+			ReporterID: args.ReporterID,
+			ActorID:    systemActorID,
+		})
+		if err != nil && !errors.Is(err, ErrAlreadyQuarantined) {
+			service.utils.logger.Err(err).Ctx(ctx).Msg("Error quarantining reported link")
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
@@ -1,24 +1,45 @@
 package services
 
 import (
+	"fmt"
+	"net/http"
 	"sync"
+	"time"
 
 	"github.com/mcorrigan89/url_shortener/internal/config"
+	"github.com/mcorrigan89/url_shortener/internal/github"
+	"github.com/mcorrigan89/url_shortener/internal/mailer"
+	"github.com/mcorrigan89/url_shortener/internal/oidc"
 	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
 	"github.com/rs/zerolog"
 )
 
 type ServicesUtils struct {
-	logger *zerolog.Logger
-	wg     *sync.WaitGroup
-	config *config.Config
+	logger          *zerolog.Logger
+	wg              *sync.WaitGroup
+	config          *config.Config
+	auditRepository *repositories.AuditRepository
 }
 
 type Services struct {
-	utils        ServicesUtils
-	UserService  *UserService
-	OAuthService *OAuthService
-	LinkService  *LinkService
+	utils               ServicesUtils
+	UserService         *UserService
+	OAuthService        *OAuthService
+	TwoFactorService    *TwoFactorService
+	LinkService         *LinkService
+	NotificationService *NotificationService
+	LinkHealthService   *LinkHealthService
+	ModerationService   *ModerationService
+	ReportService       *ReportService
+	BlockListService    *BlockListService
+	WorkspaceService    *WorkspaceService
+	TransferService     *TransferService
+	AuditService        *AuditService
+	// Mailer sends every email the services write. In development without
+	// SMTP_HOST it is a *mailer.Memory, so tests can read the links that were
+	// sent.
+	Mailer mailer.Mailer
 }
 
 func (utils *ServicesUtils) background(fn func()) {
@@ -39,19 +60,86 @@ func (utils *ServicesUtils) background(fn func()) {
 
 func NewServices(repositories *repositories.Repositories, cfg *config.Config, logger *zerolog.Logger, wg *sync.WaitGroup) Services {
 	utils := ServicesUtils{
-		logger: logger,
-		wg:     wg,
-		config: cfg,
+		logger:          logger,
+		wg:              wg,
+		config:          cfg,
+		auditRepository: repositories.AuditRepository,
 	}
 
-	userService := NewUserService(utils, repositories.UserRepository)
-	oAuthService := NewOAuthService(utils, userService, repositories.UserRepository)
-	linkService := NewLinkService(utils, repositories)
+	auditService := NewAuditService(utils, repositories)
+
+	signer := tokens.NewSigner(cfg.Tokens.Secret)
+	mailer := newMailer(cfg, logger)
+	userService := NewUserService(utils, repositories.UserRepository, mailer, signer)
+	twoFactorService := NewTwoFactorService(utils, repositories.UserRepository)
+	oAuthService := NewOAuthService(utils, userService, repositories.UserRepository, newLoginProviders(cfg), signer)
+	notificationService := NewNotificationService(utils, repositories.NotificationRepository)
+	moderationService := NewModerationService(utils, repositories, notificationService)
+	workspaceService := NewWorkspaceService(utils, repositories, mailer, signer)
+	redirectCheckClient := newRedirectlessHTTPClient(cfg.RedirectCheck.Timeout)
+	linkService := NewLinkService(utils, redirectCheckClient, repositories, moderationService, workspaceService)
+	transferService := NewTransferService(utils, repositories, workspaceService, notificationService)
+	reportService := NewReportService(utils, repositories, moderationService)
+	blockListService := NewBlockListService(utils, repositories, moderationService)
+	healthCheckClient := newRedirectlessHTTPClient(cfg.HealthCheck.Timeout)
+	linkHealthService := NewLinkHealthService(utils, healthCheckClient, repositories, notificationService)
 
 	return Services{
-		utils:        utils,
-		UserService:  userService,
-		OAuthService: oAuthService,
-		LinkService:  linkService,
+		utils:               utils,
+		UserService:         userService,
+		OAuthService:        oAuthService,
+		TwoFactorService:    twoFactorService,
+		LinkService:         linkService,
+		NotificationService: notificationService,
+		LinkHealthService:   linkHealthService,
+		ModerationService:   moderationService,
+		ReportService:       reportService,
+		BlockListService:    blockListService,
+		WorkspaceService:    workspaceService,
+		TransferService:     transferService,
+		AuditService:        auditService,
+		Mailer:              mailer,
 	}
 }
+
+// oidcTimeout bounds each request to an identity provider.
+const oidcTimeout = 10 * time.Second
+
+func newLoginProviders(cfg *config.Config) []loginProvider {
+	client := &http.Client{Timeout: oidcTimeout}
+
+	providers := make([]loginProvider, 0, len(cfg.OAuth.Providers)+1)
+	for _, provider := range cfg.OAuth.Providers {
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  fmt.Sprintf("%s/callback/%s", cfg.ClientURL, provider.Name),
+			Scopes:       provider.Scopes,
+			Claims:       oidc.ClaimMapping(provider.Claims),
+		}, client))
+	}
+
+	if cfg.OAuth.GitHub.ClientID != "" {
+		providers = append(providers, github.NewProvider(github.Config{
+			ClientID:     cfg.OAuth.GitHub.ClientID,
+			ClientSecret: cfg.OAuth.GitHub.ClientSecret,
+			RedirectURL:  fmt.Sprintf("%s/callback/%s", cfg.ClientURL, github.Name),
+		}, client))
+	}
+
+	return providers
+}
+
+func newMailer(cfg *config.Config, logger *zerolog.Logger) mailer.Mailer {
+	if cfg.Mail.Host == "" {
+		if !cfg.IsDevelopment() {
+			logger.Fatal().Str("env", cfg.Env).Msg("SMTP_HOST is required outside development")
+		}
+		logger.Warn().Msg("SMTP_HOST is not set, email will not be sent")
+		return mailer.NewMemory()
+	}
+
+	return mailer.NewSMTP(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+}
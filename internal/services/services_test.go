@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/internal/config"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/mailer"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/testdb"
+	"github.com/rs/zerolog"
+)
+
+type testServices struct {
+	Services
+	repos  *repositories.Repositories
+	config *config.Config
+	mail   *mailer.Memory
+}
+
+// newTestServices wires the services to a migrated test database and an
+// in-memory mailer, skipping the test when no database is configured.
+func newTestServices(t *testing.T) *testServices {
+	t.Helper()
+
+	db := testdb.New(t)
+
+	cfg := &config.Config{Env: "test", ClientURL: "http://localhost:8080"}
+	cfg.Tokens.Secret = "test-secret"
+	cfg.Invites.TTL = time.Hour
+	cfg.Sessions.IdleTimeout = time.Hour
+	cfg.Sessions.MaxLifetime = 24 * time.Hour
+	cfg.PasswordReset.TTL = time.Hour
+	cfg.MagicLink.TTL = 15 * time.Minute
+	cfg.TwoFactor.Issuer = "URL Shortener"
+
+	logger := zerolog.Nop()
+	wg := &sync.WaitGroup{}
+	t.Cleanup(wg.Wait)
+
+	repos := repositories.NewRepositories(db, cfg, &logger, wg)
+	svcs := NewServices(&repos, cfg, &logger, wg)
+
+	mail, ok := svcs.Mailer.(*mailer.Memory)
+	if !ok {
+		t.Fatalf("Mailer is %T, want *mailer.Memory", svcs.Mailer)
+	}
+
+	return &testServices{
+		Services: svcs,
+		repos:    &repos,
+		config:   cfg,
+		mail:     mail,
+	}
+}
+
+func (s *testServices) createUser(t *testing.T, email string) *entities.User {
+	t.Helper()
+
+	user, err := s.repos.UserRepository.CreateUserPassword(context.Background(), repositories.CreateUserPasswordArgs{
+		Email:    email,
+		Password: "correct horse battery staple",
+	})
+	if err != nil {
+		t.Fatalf("creating user %s: %v", email, err)
+	}
+
+	return user
+}
+
+// lastLinkToken returns the final path segment of the last link under path
+// emailed to address.
+func (s *testServices) lastLinkToken(t *testing.T, address, path string) string {
+	t.Helper()
+
+	pattern := regexp.MustCompile(regexp.QuoteMeta(s.config.ClientURL+path) + `([^\s/]+)`)
+
+	sent := s.mail.Sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].To != address {
+			continue
+		}
+		match := pattern.FindStringSubmatch(sent[i].Body)
+		if match != nil {
+			return match[1]
+		}
+	}
+
+	t.Fatalf("no email to %s with a link under %s", address, path)
+	return ""
+}
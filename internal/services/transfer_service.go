@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+)
+
+type TransferService struct {
+	utils               ServicesUtils
+	transferRepository  *repositories.TransferRepository
+	linkRepository      *repositories.LinkRepository
+	userRepository      *repositories.UserRepository
+	workspaceService    *WorkspaceService
+	notificationService *NotificationService
+}
+
+func NewTransferService(utils ServicesUtils, repos *repositories.Repositories, workspaceService *WorkspaceService, notificationService *NotificationService) *TransferService {
+	return &TransferService{
+		utils:               utils,
+		transferRepository:  repos.TransferRepository,
+		linkRepository:      repos.LinkRepository,
+		userRepository:      repos.UserRepository,
+		workspaceService:    workspaceService,
+		notificationService: notificationService,
+	}
+}
+
+type RequestTransferArgs struct {
+	UserID        uuid.UUID
+	LinkIDs       []uuid.UUID
+	ToEmail       string
+	ToWorkspaceID *uuid.UUID
+}
+
+// RequestTransfer offers links to another user, or to a workspace the
+// requester belongs to. Nothing moves until the recipient accepts. Personal
+// links can be offered by their owner and workspace links by the workspace's
+// owners.
+func (service *TransferService) RequestTransfer(ctx context.Context, args RequestTransferArgs) ([]*entities.LinkTransfer, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Requesting link transfer")
+
+	var toUser *entities.User
+	if args.ToWorkspaceID != nil {
+		_, err := service.workspaceService.GetMembership(ctx, *args.ToWorkspaceID, args.UserID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(args.ToEmail))
+		if err != nil {
+			return nil, err
+		}
+		toUser = user
+	}
+
+	for _, linkID := range args.LinkIDs {
+		link, err := service.linkRepository.GetLinkByID(ctx, linkID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = service.checkCanTransfer(ctx, link, args.UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		if toUser != nil && link.WorkspaceID == nil && link.OwnerID == toUser.ID {
+			return nil, entities.ErrTransferToOwner
+		}
+		if args.ToWorkspaceID != nil && link.WorkspaceID != nil && *link.WorkspaceID == *args.ToWorkspaceID {
+			return nil, entities.ErrTransferToOwner
+		}
+	}
+
+	createArgs := repositories.CreateLinkTransfersArgs{
+		LinkIDs:       args.LinkIDs,
+		FromUserID:    args.UserID,
+		ToWorkspaceID: args.ToWorkspaceID,
+	}
+	if toUser != nil {
+		createArgs.ToUserID = &toUser.ID
+	}
+
+	transfers, err := service.transferRepository.CreateLinkTransfers(ctx, createArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	if toUser != nil {
+		_, err = service.notificationService.Notify(ctx, NotifyArgs{
+			UserID:  toUser.ID,
+			Kind:    entities.NotificationLinkTransfer,
+			Message: fmt.Sprintf("You have been offered %d link(s). Review them on the transfers page.", len(transfers)),
+		})
+		if err != nil {
+			service.utils.logger.Err(err).Ctx(ctx).Msg("Error notifying transfer recipient")
+		}
+	}
+
+	return transfers, nil
+}
+
+func (service *TransferService) checkCanTransfer(ctx context.Context, link *entities.LinkEntity, userID uuid.UUID) error {
+	if link.WorkspaceID == nil {
+		if link.OwnerID != userID {
+			return entities.ErrForbidden
+		}
+		return nil
+	}
+
+	member, err := service.workspaceService.GetMembership(ctx, *link.WorkspaceID, userID)
+	if err != nil {
+		return err
+	}
+	if !member.CanManageMembers() {
+		return entities.ErrForbidden
+	}
+
+	return nil
+}
+
+// GetTransfers returns the pending transfers waiting on the user and the ones
+// they have offered.
+func (service *TransferService) GetTransfers(ctx context.Context, userID uuid.UUID) (*entities.LinkTransfers, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Getting link transfers")
+
+	incoming, err := service.transferRepository.GetIncomingLinkTransfers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	outgoing, err := service.transferRepository.GetOutgoingLinkTransfers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, transfer := range incoming {
+		service.enrich(ctx, transfer)
+	}
+	for _, transfer := range outgoing {
+		service.enrich(ctx, transfer)
+	}
+
+	return &entities.LinkTransfers{
+		Incoming: incoming,
+		Outgoing: outgoing,
+	}, nil
+}
+
+func (service *TransferService) enrich(ctx context.Context, transfer *entities.LinkTransfer) {
+	fromUser, err := service.userRepository.GetUserByID(ctx, transfer.FromUserID)
+	if err == nil {
+		transfer.FromUser = fromUser
+	}
+
+	if transfer.ToUserID != nil {
+		toUser, err := service.userRepository.GetUserByID(ctx, *transfer.ToUserID)
+		if err == nil {
+			transfer.ToUser = toUser
+		}
+	}
+
+	if transfer.ToWorkspaceID != nil {
+		workspace, err := service.workspaceService.workspaceRepository.GetWorkspaceByID(ctx, *transfer.ToWorkspaceID)
+		if err == nil {
+			transfer.ToWorkspace = workspace
+		}
+	}
+}
+
+// getIncomingTransfer loads a pending transfer that userID may decide: one
+// sent to them, or to a workspace they own.
+func (service *TransferService) getIncomingTransfer(ctx context.Context, transferID, userID uuid.UUID) (*entities.LinkTransfer, error) {
+	transfer, err := service.transferRepository.GetLinkTransferByID(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+
+	if transfer.Status != entities.TransferStatusPending {
+		return nil, entities.ErrTransferUnavailable
+	}
+
+	if transfer.ToUserID != nil {
+		if *transfer.ToUserID != userID {
+			return nil, entities.ErrForbidden
+		}
+		return transfer, nil
+	}
+
+	member, err := service.workspaceService.GetMembership(ctx, *transfer.ToWorkspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !member.CanManageMembers() {
+		return nil, entities.ErrForbidden
+	}
+	transfer.ToWorkspace = member.Workspace
+
+	return transfer, nil
+}
+
+func (service *TransferService) AcceptTransfer(ctx context.Context, transferID, userID uuid.UUID) (*entities.LinkEntity, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("transferID", transferID.String()).Msg("Accepting link transfer")
+
+	transfer, err := service.getIncomingTransfer(ctx, transferID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	service.enrich(ctx, transfer)
+
+	from := transfer.FromUserID.String()
+	if transfer.FromUser != nil {
+		from = transfer.FromUser.Email
+	}
+
+	reason := fmt.Sprintf("Transferred from %s to %s", from, userID)
+	if transfer.ToUser != nil {
+		reason = fmt.Sprintf("Transferred from %s to %s", from, transfer.ToUser.Email)
+	}
+	if transfer.ToWorkspace != nil {
+		reason = fmt.Sprintf("Transferred from %s to workspace %s", from, transfer.ToWorkspace.Name)
+	}
+
+	link, err := service.transferRepository.AcceptLinkTransfer(ctx, repositories.AcceptLinkTransferArgs{
+		Transfer:  transfer,
+		DecidedBy: actorID(ctx, userID),
+		Reason:    reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinkTransferred,
+		TargetType: entities.AuditTargetLink,
+		TargetID:   link.ID.String(),
+		Metadata:   map[string]any{"transfer_id": transfer.ID.String(), "reason": reason},
+	})
+
+	return link, nil
+}
+
+func (service *TransferService) DeclineTransfer(ctx context.Context, transferID, userID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("transferID", transferID.String()).Msg("Declining link transfer")
+
+	transfer, err := service.getIncomingTransfer(ctx, transferID, userID)
+	if err != nil {
+		return err
+	}
+
+	return service.transferRepository.DecideLinkTransfer(ctx, transfer.ID, entities.TransferStatusDeclined, actorID(ctx, userID))
+}
+
+func (service *TransferService) CancelTransfer(ctx context.Context, transferID, userID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("transferID", transferID.String()).Msg("Cancelling link transfer")
+
+	transfer, err := service.transferRepository.GetLinkTransferByID(ctx, transferID)
+	if err != nil {
+		return err
+	}
+
+	if transfer.FromUserID != userID {
+		return entities.ErrForbidden
+	}
+
+	return service.transferRepository.DecideLinkTransfer(ctx, transfer.ID, entities.TransferStatusCancelled, actorID(ctx, userID))
+}
+
+type ReassignLinksArgs struct {
+	FromEmail string
+	ToEmail   string
+	AdminID   uuid.UUID
+}
+
+// ReassignLinks moves every link a departing user owns to another user at
+// once, skipping the acceptance step. It returns how many were moved.
+func (service *TransferService) ReassignLinks(ctx context.Context, args ReassignLinksArgs) (int, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Reassigning links")
+
+	err := service.utils.authorize(ctx, entities.PermissionReassignLinks)
+	if err != nil {
+		return 0, err
+	}
+
+	fromUser, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(args.FromEmail))
+	if err != nil {
+		return 0, err
+	}
+
+	toUser, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(args.ToEmail))
+	if err != nil {
+		return 0, err
+	}
+
+	if fromUser.ID == toUser.ID {
+		return 0, entities.ErrTransferToOwner
+	}
+
+	reassigned, err := service.transferRepository.ReassignLinks(ctx, repositories.ReassignLinksArgs{
+		FromUserID: fromUser.ID,
+		ToUserID:   toUser.ID,
+		UpdatedBy:  args.AdminID,
+		Reason:     fmt.Sprintf("Reassigned by admin from %s to %s", fromUser.Email, toUser.Email),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLinksReassigned,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   fromUser.ID.String(),
+		Metadata:   map[string]any{"to_user_id": toUser.ID.String(), "links": reassigned},
+	})
+
+	return reassigned, nil
+}
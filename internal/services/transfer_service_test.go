@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+)
+
+func (s *testServices) createLink(t *testing.T, owner uuid.UUID, workspaceID *uuid.UUID) *entities.LinkEntity {
+	t.Helper()
+
+	link, err := s.repos.LinkRepository.CreateLink(context.Background(), repositories.CreateLinkArgs{
+		LinkURL:     "https://example.com/" + uuid.NewString(),
+		ShortedURL:  uuid.NewString()[:8],
+		CreatedBy:   owner,
+		UpdatedBy:   owner,
+		WorkspaceID: workspaceID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return link
+}
+
+func TestLinkTransfers(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	from := s.createUser(t, "from@example.com")
+	to := s.createUser(t, "to@example.com")
+	stranger := s.createUser(t, "stranger@example.com")
+
+	t.Run("moves the link once the recipient accepts", func(t *testing.T) {
+		link := s.createLink(t, from.ID, nil)
+
+		transfers, err := s.TransferService.RequestTransfer(ctx, RequestTransferArgs{UserID: from.ID, LinkIDs: []uuid.UUID{link.ID}, ToEmail: to.Email})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unchanged, err := s.repos.LinkRepository.GetLinkByID(ctx, link.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unchanged.OwnerID != from.ID {
+			t.Fatal("link moved before the transfer was accepted")
+		}
+
+		_, err = s.TransferService.AcceptTransfer(ctx, transfers[0].ID, stranger.ID)
+		if !errors.Is(err, entities.ErrForbidden) {
+			t.Errorf("stranger accept err = %v, want ErrForbidden", err)
+		}
+
+		moved, err := s.TransferService.AcceptTransfer(ctx, transfers[0].ID, to.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.OwnerID != to.ID {
+			t.Errorf("OwnerID = %s, want %s", moved.OwnerID, to.ID)
+		}
+
+		_, err = s.TransferService.AcceptTransfer(ctx, transfers[0].ID, to.ID)
+		if !errors.Is(err, entities.ErrTransferUnavailable) {
+			t.Errorf("second accept err = %v, want ErrTransferUnavailable", err)
+		}
+	})
+
+	t.Run("declined transfers leave the link alone", func(t *testing.T) {
+		link := s.createLink(t, from.ID, nil)
+
+		transfers, err := s.TransferService.RequestTransfer(ctx, RequestTransferArgs{UserID: from.ID, LinkIDs: []uuid.UUID{link.ID}, ToEmail: to.Email})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.TransferService.DeclineTransfer(ctx, transfers[0].ID, to.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unchanged, err := s.repos.LinkRepository.GetLinkByID(ctx, link.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if unchanged.OwnerID != from.ID {
+			t.Errorf("OwnerID = %s, want %s", unchanged.OwnerID, from.ID)
+		}
+	})
+
+	t.Run("only the owner can offer a link", func(t *testing.T) {
+		link := s.createLink(t, from.ID, nil)
+
+		_, err := s.TransferService.RequestTransfer(ctx, RequestTransferArgs{UserID: stranger.ID, LinkIDs: []uuid.UUID{link.ID}, ToEmail: to.Email})
+		if !errors.Is(err, entities.ErrForbidden) {
+			t.Errorf("err = %v, want ErrForbidden", err)
+		}
+	})
+
+	t.Run("into a workspace joined by invite", func(t *testing.T) {
+		owner := s.createUser(t, "workspace-owner@example.com")
+		membership, err := s.WorkspaceService.CreateWorkspace(ctx, CreateWorkspaceArgs{UserID: owner.ID, Name: "Receivers"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.WorkspaceService.InviteMember(ctx, InviteMemberArgs{WorkspaceID: membership.WorkspaceID, ActorID: owner.ID, Email: from.Email, Role: entities.WorkspaceRoleViewer})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.WorkspaceService.AcceptInvite(ctx, s.lastLinkToken(t, from.Email, "/invites/"), from.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		link := s.createLink(t, from.ID, nil)
+		transfers, err := s.TransferService.RequestTransfer(ctx, RequestTransferArgs{UserID: from.ID, LinkIDs: []uuid.UUID{link.ID}, ToWorkspaceID: &membership.WorkspaceID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.TransferService.AcceptTransfer(ctx, transfers[0].ID, from.ID)
+		if !errors.Is(err, entities.ErrForbidden) {
+			t.Errorf("viewer accept err = %v, want ErrForbidden", err)
+		}
+
+		moved, err := s.TransferService.AcceptTransfer(ctx, transfers[0].ID, owner.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if moved.WorkspaceID == nil || *moved.WorkspaceID != membership.WorkspaceID {
+			t.Errorf("WorkspaceID = %v, want %s", moved.WorkspaceID, membership.WorkspaceID)
+		}
+	})
+}
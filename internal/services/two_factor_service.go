@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
+	"github.com/mcorrigan89/url_shortener/internal/totp"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+)
+
+const (
+	// recoveryCodeCount is how many recovery codes a user is given at a time.
+	recoveryCodeCount = 10
+	// recoveryCodeBytes gives each recovery code 40 bits, which the login
+	// rate limit makes impractical to guess.
+	recoveryCodeBytes = 5
+)
+
+// twoFactorRoles are the roles an admin can require two-factor for.
+var twoFactorRoles = []string{entities.RoleModerator, entities.RoleAdmin}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type TwoFactorService struct {
+	utils          ServicesUtils
+	userRepository *repositories.UserRepository
+	// now is the clock codes are checked against.
+	now func() time.Time
+}
+
+func NewTwoFactorService(utils ServicesUtils, userRepo *repositories.UserRepository) *TwoFactorService {
+	return &TwoFactorService{
+		utils:          utils,
+		userRepository: userRepo,
+		now:            time.Now,
+	}
+}
+
+// GetTwoFactor returns the user's enrolment, or nil if they never started
+// one.
+func (service *TwoFactorService) GetTwoFactor(ctx context.Context, userID uuid.UUID) (*entities.TwoFactor, error) {
+	twoFactor, err := service.userRepository.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if err == entities.ErrTwoFactorNotEnabled {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return twoFactor, nil
+}
+
+type TwoFactorEnrolment struct {
+	Secret string
+	// URL is the otpauth URL shown to the user as a QR code.
+	URL string
+}
+
+// StartEnrolment gives the user a new secret for their authenticator. Logins
+// aren't protected until the user confirms it with ConfirmEnrolment.
+func (service *TwoFactorService) StartEnrolment(ctx context.Context, user *entities.User) (*TwoFactorEnrolment, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", user.ID.String()).Msg("Starting two-factor enrolment")
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := service.userRepository.StartTwoFactor(ctx, user.ID, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.enrolment(user, twoFactor), nil
+}
+
+// GetEnrolment returns the enrolment the user started but hasn't confirmed.
+func (service *TwoFactorService) GetEnrolment(ctx context.Context, user *entities.User) (*TwoFactorEnrolment, error) {
+	twoFactor, err := service.userRepository.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		if err == entities.ErrTwoFactorNotEnabled {
+			return nil, entities.ErrTwoFactorNotStarted
+		}
+		return nil, err
+	}
+
+	if twoFactor.Enabled {
+		return nil, entities.ErrTwoFactorAlreadyEnabled
+	}
+
+	return service.enrolment(user, twoFactor), nil
+}
+
+func (service *TwoFactorService) enrolment(user *entities.User, twoFactor *entities.TwoFactor) *TwoFactorEnrolment {
+	return &TwoFactorEnrolment{
+		Secret: twoFactor.Secret(),
+		URL:    totp.URL(service.utils.config.TwoFactor.Issuer, user.Email, twoFactor.Secret()),
+	}
+}
+
+// ConfirmEnrolment turns two-factor on once the user enters a code from their
+// authenticator, and returns their recovery codes. Only hashes of the codes
+// are kept, so this is the one time they can be shown. The user's other
+// sessions are signed out.
+func (service *TwoFactorService) ConfirmEnrolment(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Confirming two-factor enrolment")
+
+	twoFactor, err := service.userRepository.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if err == entities.ErrTwoFactorNotEnabled {
+			return nil, entities.ErrTwoFactorNotStarted
+		}
+		return nil, err
+	}
+
+	if twoFactor.Enabled {
+		return nil, entities.ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(twoFactor.Secret(), code, service.now())
+	if !ok {
+		return nil, entities.ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.userRepository.EnableTwoFactor(ctx, userID, step, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditTwoFactorEnabled,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+	})
+
+	err = service.expireOtherSessions(ctx, userID, "two-factor enabled")
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CompleteLogin finishes a login that is waiting for the second factor. The
+// pending session on ctx is swapped for a full one, so its token never grants
+// more than the code page.
+func (service *TwoFactorService) CompleteLogin(ctx context.Context, code string) (*entities.UserSession, error) {
+	pending := usercontext.ContextGetSession(ctx)
+	if pending == nil || !pending.TwoFactorPending || pending.IsExpired() {
+		return nil, entities.ErrSessionNotFound
+	}
+
+	service.utils.logger.Info().Ctx(ctx).Str("userID", pending.UserID.String()).Msg("Completing two-factor login")
+
+	method, err := service.verify(ctx, pending.UserID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := service.userRepository.CreateUserSession(ctx, newSessionArgs(ctx, pending.UserID))
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create user session")
+		return nil, err
+	}
+
+	err = service.userRepository.ExpireUserSession(ctx, pending.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditTwoFactorVerified,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   pending.UserID.String(),
+		ActorID:    &pending.UserID,
+		Metadata:   map[string]any{"method": method},
+	})
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditSessionCreated,
+		TargetType: entities.AuditTargetSession,
+		TargetID:   session.ID.String(),
+		ActorID:    &pending.UserID,
+		Metadata:   map[string]any{"expires_at": session.ExpiresAt},
+	})
+	service.utils.auditSessionExpired(ctx, pending, "two-factor verified")
+
+	return session, nil
+}
+
+// Disable turns two-factor off after checking a code. Users whose role
+// requires two-factor can't turn it off.
+func (service *TwoFactorService) Disable(ctx context.Context, user *entities.User, code string) error {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", user.ID.String()).Msg("Disabling two-factor")
+
+	required, err := service.Required(ctx, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return entities.ErrTwoFactorRequired
+	}
+
+	_, err = service.verify(ctx, user.ID, code)
+	if err != nil {
+		return err
+	}
+
+	err = service.userRepository.DisableTwoFactor(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditTwoFactorDisabled,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	})
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for a new set after
+// checking a code, and returns the new codes.
+func (service *TwoFactorService) ReplaceRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Replacing recovery codes")
+
+	_, err := service.verify(ctx, userID, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = service.userRepository.ReplaceRecoveryCodes(ctx, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditRecoveryCodesReplaced,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+	})
+
+	return codes, nil
+}
+
+// verify accepts a code from the user's authenticator or one of their unused
+// recovery codes, and returns which it was. Either only works once.
+func (service *TwoFactorService) verify(ctx context.Context, userID uuid.UUID, code string) (string, error) {
+	twoFactor, err := service.userRepository.GetTwoFactor(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if !twoFactor.Enabled {
+		return "", entities.ErrTwoFactorNotEnabled
+	}
+
+	code = normalizeCode(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(twoFactor.Secret(), code, service.now())
+		if !ok || step <= twoFactor.LastUsedStep() {
+			return "", entities.ErrInvalidTwoFactorCode
+		}
+
+		err = service.userRepository.UseTwoFactorStep(ctx, userID, step)
+		if err != nil {
+			return "", err
+		}
+
+		return "totp", nil
+	}
+
+	err = service.userRepository.UseRecoveryCode(ctx, userID, tokens.Hash(code))
+	if err != nil {
+		return "", err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditRecoveryCodeUsed,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+		ActorID:    &userID,
+		Metadata:   map[string]any{"remaining": twoFactor.RecoveryCodesLeft - 1},
+	})
+
+	return "recovery_code", nil
+}
+
+func (service *TwoFactorService) expireOtherSessions(ctx context.Context, userID uuid.UUID, reason string) error {
+	keepSessionID := uuid.Nil
+	if session := usercontext.ContextGetSession(ctx); session != nil {
+		keepSessionID = session.ID
+	}
+
+	sessions, err := service.userRepository.ExpireOtherUserSessions(ctx, userID, keepSessionID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		service.utils.auditSessionExpired(ctx, session, reason)
+	}
+
+	return nil
+}
+
+// Required reports whether the user's role requires two-factor.
+func (service *TwoFactorService) Required(ctx context.Context, user *entities.User) (bool, error) {
+	if !slices.Contains(twoFactorRoles, user.Role) {
+		return false, nil
+	}
+
+	policy, err := service.userRepository.GetRolePolicy(ctx, user.Role)
+	if err != nil {
+		return false, err
+	}
+
+	return policy.RequireTwoFactor, nil
+}
+
+// MissingRequired reports whether the user's role requires two-factor and
+// they haven't turned it on yet.
+func (service *TwoFactorService) MissingRequired(ctx context.Context, user *entities.User) (bool, error) {
+	required, err := service.Required(ctx, user)
+	if err != nil || !required {
+		return false, err
+	}
+
+	twoFactor, err := service.GetTwoFactor(ctx, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return twoFactor == nil || !twoFactor.Enabled, nil
+}
+
+// GetRolePolicies returns the policy for each role two-factor can be required
+// for.
+func (service *TwoFactorService) GetRolePolicies(ctx context.Context) ([]*entities.RolePolicy, error) {
+	err := service.utils.authorize(ctx, entities.PermissionManageRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]*entities.RolePolicy, 0, len(twoFactorRoles))
+	for _, role := range twoFactorRoles {
+		policy, err := service.userRepository.GetRolePolicy(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// SetRequireTwoFactor sets whether users with role must use two-factor. Those
+// who haven't enrolled are sent to enrol before they can use the role.
+func (service *TwoFactorService) SetRequireTwoFactor(ctx context.Context, role string, require bool) (*entities.RolePolicy, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("role", role).Bool("require", require).Msg("Setting two-factor requirement")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(twoFactorRoles, role) {
+		return nil, entities.ErrInvalidRole
+	}
+
+	var updatedBy *uuid.UUID
+	if actor := usercontext.ContextGetUser(ctx); actor != nil {
+		updatedBy = &actor.ID
+	}
+
+	policy, err := service.userRepository.SetRolePolicy(ctx, role, require, updatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditRolePolicyChanged,
+		TargetType: entities.AuditTargetRole,
+		TargetID:   role,
+		Metadata:   map[string]any{"require_two_factor": require},
+	})
+
+	return policy, nil
+}
+
+// generateRecoveryCodes returns a new set of recovery codes, formatted for
+// people, and the hashes to store for them.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, recoveryCodeBytes)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, tokens.Hash(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeCode drops the spaces and dashes people type or paste into codes.
+func normalizeCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/totp"
+	"github.com/mcorrigan89/url_shortener/internal/usercontext"
+)
+
+func TestTwoFactorCodesWorkOnce(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.TwoFactorService.now = func() time.Time { return now }
+
+	user := s.createUser(t, "two-factor@example.com")
+
+	enrolment, err := s.TwoFactorService.StartEnrolment(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := func(at time.Time) string {
+		t.Helper()
+		c, err := totp.Code(enrolment.Secret, totp.Step(at))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	recoveryCodes, err := s.TwoFactorService.ConfirmEnrolment(ctx, user.ID, code(now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// completeLogin tries code against a fresh pending session, as the code
+	// page would after a password login.
+	completeLogin := func(t *testing.T, code string) error {
+		t.Helper()
+		pending, err := s.repos.UserRepository.CreateUserSession(ctx, repositories.CreateUserSessionArgs{
+			UserID:           user.ID,
+			TwoFactorPending: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.TwoFactorService.CompleteLogin(usercontext.ContextSetSession(ctx, pending), code)
+		return err
+	}
+
+	t.Run("step used to enrol is refused", func(t *testing.T) {
+		err := completeLogin(t, code(now))
+		if !errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			t.Errorf("err = %v, want ErrInvalidTwoFactorCode", err)
+		}
+	})
+
+	t.Run("next step works once", func(t *testing.T) {
+		now = now.Add(totp.Period * time.Second)
+
+		err := completeLogin(t, code(now))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = completeLogin(t, code(now))
+		if !errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			t.Errorf("replayed code err = %v, want ErrInvalidTwoFactorCode", err)
+		}
+	})
+
+	t.Run("earlier step within skew is refused after a later one", func(t *testing.T) {
+		err := completeLogin(t, code(now.Add(-totp.Period*time.Second)))
+		if !errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			t.Errorf("err = %v, want ErrInvalidTwoFactorCode", err)
+		}
+	})
+
+	t.Run("recovery code works once", func(t *testing.T) {
+		err := completeLogin(t, recoveryCodes[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = completeLogin(t, recoveryCodes[0])
+		if !errors.Is(err, entities.ErrInvalidTwoFactorCode) {
+			t.Errorf("reused recovery code err = %v, want ErrInvalidTwoFactorCode", err)
+		}
+
+		err = completeLogin(t, recoveryCodes[1])
+		if err != nil {
+			t.Errorf("another recovery code err = %v", err)
+		}
+	})
+
+	t.Run("full sessions can't complete a login", func(t *testing.T) {
+		full := &entities.UserSession{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour), AbsoluteExpiresAt: time.Now().Add(time.Hour)}
+		_, err := s.TwoFactorService.CompleteLogin(usercontext.ContextSetSession(ctx, full), code(now.Add(totp.Period*time.Second)))
+		if !errors.Is(err, entities.ErrSessionNotFound) {
+			t.Errorf("err = %v, want ErrSessionNotFound", err)
+		}
+	})
+}
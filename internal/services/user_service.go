@@ -2,22 +2,44 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/mailer"
 	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
 	"github.com/mcorrigan89/url_shortener/internal/usercontext"
 )
 
+var (
+	ErrAlreadyImpersonating  = errors.New("already impersonating")
+	ErrNotImpersonating      = errors.New("not impersonating")
+	ErrCannotImpersonateSelf = errors.New("cannot impersonate yourself")
+	ErrVerificationLink      = errors.New("verification link is invalid or expired")
+)
+
+// verifyEmailPrefix keeps verification tokens from being accepted as any
+// other kind of signed token.
+const verifyEmailPrefix = "verify-email:"
+
 type UserService struct {
 	utils          ServicesUtils
 	userRepository *repositories.UserRepository
+	mailer         mailer.Mailer
+	signer         *tokens.Signer
 }
 
-func NewUserService(utils ServicesUtils, userRepo *repositories.UserRepository) *UserService {
+func NewUserService(utils ServicesUtils, userRepo *repositories.UserRepository, mailer mailer.Mailer, signer *tokens.Signer) *UserService {
 	return &UserService{
 		utils:          utils,
 		userRepository: userRepo,
+		mailer:         mailer,
+		signer:         signer,
 	}
 }
 
@@ -34,7 +56,7 @@ func (service *UserService) GetUserByID(ctx context.Context, userId uuid.UUID) (
 }
 
 func (service *UserService) GetUserBySessionToken(ctx context.Context, token string) (*entities.User, *entities.UserSession, error) {
-	service.utils.logger.Info().Ctx(ctx).Str("token", token).Msg("Getting user by token")
+	service.utils.logger.Info().Ctx(ctx).Msg("Getting user by session token")
 	user, session, err := service.userRepository.GetUserBySessionToken(ctx, token)
 	if err != nil {
 		if err == entities.ErrUserNotFound {
@@ -56,11 +78,11 @@ type CreateUserArgs struct {
 }
 
 func (service *UserService) CreateUser(ctx context.Context, args CreateUserArgs) (*entities.User, error) {
-	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Creating user")
+	service.utils.logger.Info().Ctx(ctx).Str("email", args.Email).Msg("Creating user")
 	user, err := service.userRepository.CreateUserPassword(ctx, repositories.CreateUserPasswordArgs{
 		GivenName:  args.GivenName,
 		FamilyName: args.FamilyName,
-		Email:      args.Email,
+		Email:      strings.TrimSpace(args.Email),
 		Password:   args.Password,
 	})
 	if err != nil {
@@ -71,33 +93,624 @@ func (service *UserService) CreateUser(ctx context.Context, args CreateUserArgs)
 	return user, nil
 }
 
+// SendVerificationEmail emails the user a signed link that verifies their
+// current address. Changing the address makes earlier links stop working.
+func (service *UserService) SendVerificationEmail(ctx context.Context, user *entities.User) error {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", user.ID.String()).Msg("Sending verification email")
+
+	if user.EmailVerified {
+		return nil
+	}
+
+	ttl := service.utils.config.EmailVerification.TTL
+	token := service.signer.Sign(verifyEmailPrefix+user.ID.String()+":"+user.Email, time.Now().Add(ttl))
+
+	err := service.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Confirm that this is your email address by opening this link within %s:\n%s/verify-email/%s\n\nIf you didn't sign up, you can ignore this email.\n",
+			ttl, service.utils.config.ClientURL, token),
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error sending verification email")
+		return err
+	}
+
+	return nil
+}
+
+// VerifyEmail marks the address a verification link was sent to as verified.
+// Tampered and expired links, and links for an address the user has since
+// changed, return ErrVerificationLink.
+func (service *UserService) VerifyEmail(ctx context.Context, token string) (*entities.User, error) {
+	payload, err := service.signer.Verify(token)
+	if err != nil {
+		return nil, ErrVerificationLink
+	}
+
+	data, ok := strings.CutPrefix(payload, verifyEmailPrefix)
+	if !ok {
+		return nil, ErrVerificationLink
+	}
+
+	rawUserID, email, ok := strings.Cut(data, ":")
+	if !ok {
+		return nil, ErrVerificationLink
+	}
+
+	userID, err := uuid.Parse(rawUserID)
+	if err != nil {
+		return nil, ErrVerificationLink
+	}
+
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Verifying email")
+
+	user, err := service.userRepository.SetUserEmailVerified(ctx, userID, email)
+	if err != nil {
+		if err == entities.ErrUserNotFound {
+			return nil, ErrVerificationLink
+		}
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditEmailVerified,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		ActorID:    &user.ID,
+		Metadata:   map[string]any{"method": "link"},
+	})
+
+	return user, nil
+}
+
+// RequestPasswordReset emails a single use reset link to the account with
+// email. To avoid telling anyone which addresses have accounts, unknown
+// addresses and accounts without a password are not an error.
+func (service *UserService) RequestPasswordReset(ctx context.Context, email string) error {
+	service.utils.logger.Info().Ctx(ctx).Msg("Requesting password reset")
+
+	user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		if err == entities.ErrUserNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if user.Identity(entities.ProviderPassword) == nil {
+		service.utils.logger.Info().Ctx(ctx).Str("userID", user.ID.String()).Msg("Password reset requested for user without a password")
+		return nil
+	}
+
+	ttl := service.utils.config.PasswordReset.TTL
+
+	token, err := service.userRepository.CreatePasswordReset(ctx, user.ID, time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	err = service.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Choose a new password by opening this link within %s. It works once:\n%s/reset-password/%s\n\nIf you didn't ask to reset your password, you can ignore this email.\n",
+			ttl, service.utils.config.ClientURL, token),
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error sending password reset email")
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditPasswordResetRequested,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	})
+
+	return nil
+}
+
+// CheckPasswordReset returns entities.ErrPasswordResetInvalid unless token
+// can still be used.
+func (service *UserService) CheckPasswordReset(ctx context.Context, token string) error {
+	return service.userRepository.CheckPasswordReset(ctx, token)
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out
+// everywhere.
+func (service *UserService) ResetPassword(ctx context.Context, token, password string) error {
+	service.utils.logger.Info().Ctx(ctx).Msg("Resetting password")
+
+	userID, err := service.userRepository.ResetPassword(ctx, token, password)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditPasswordReset,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+		ActorID:    &userID,
+	})
+
+	sessions, err := service.userRepository.ExpireUserSessionsByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		service.utils.auditSessionExpired(ctx, session, "password reset")
+	}
+
+	return nil
+}
+
+type ChangePasswordArgs struct {
+	UserID          uuid.UUID
+	CurrentPassword string
+	NewPassword     string
+}
+
+// ChangePassword replaces the user's password after checking the current one,
+// and signs them out of every session but the one making the change.
+func (service *UserService) ChangePassword(ctx context.Context, args ChangePasswordArgs) error {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", args.UserID.String()).Msg("Changing password")
+
+	user, err := service.userRepository.GetUserByID(ctx, args.UserID)
+	if err != nil {
+		return err
+	}
+
+	err = user.ComparePassword(args.CurrentPassword)
+	if err != nil {
+		if err == entities.ErrIncorrectProvider {
+			return entities.ErrNoPasswordSet
+		}
+		return err
+	}
+
+	err = service.userRepository.SetUserPassword(ctx, user.ID, args.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditPasswordChanged,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	})
+
+	keepSessionID := uuid.Nil
+	if session := usercontext.ContextGetSession(ctx); session != nil {
+		keepSessionID = session.ID
+	}
+
+	sessions, err := service.userRepository.ExpireOtherUserSessions(ctx, user.ID, keepSessionID)
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		service.utils.auditSessionExpired(ctx, session, "password changed")
+	}
+
+	return nil
+}
+
+// RequestLoginLink emails a single use login link to email. The address
+// doesn't need an account yet; one is created when the link is used.
+func (service *UserService) RequestLoginLink(ctx context.Context, email string) error {
+	service.utils.logger.Info().Ctx(ctx).Msg("Requesting login link")
+
+	email = strings.TrimSpace(email)
+	ttl := service.utils.config.MagicLink.TTL
+
+	token, err := service.userRepository.CreateLoginLink(ctx, email, time.Now().Add(ttl))
+	if err != nil {
+		return err
+	}
+
+	err = service.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Log in by opening this link within %s. It works once:\n%s/login/email/%s\n\nIf you didn't ask to log in, you can ignore this email.\n",
+			ttl, service.utils.config.ClientURL, token),
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error sending login link email")
+		return err
+	}
+
+	return nil
+}
+
+// LoginWithLink uses up a login link and signs in the user with the address
+// it was sent to, creating them on their first visit. Opening the link proves
+// the user owns the address, so it is marked verified, and an unverified
+// account with the address is handed over to them.
+func (service *UserService) LoginWithLink(ctx context.Context, token string) (*entities.UserSession, error) {
+	service.utils.logger.Info().Ctx(ctx).Msg("Logging in with login link")
+
+	email, err := service.userRepository.UseLoginLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.userForLoginLink(ctx, email)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get user for login link")
+		return nil, err
+	}
+
+	if !user.EmailVerified {
+		user, err = service.claimUnverifiedUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sessionArgs, err := service.loginSessionArgs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := service.userRepository.CreateUserSession(ctx, sessionArgs)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create user session")
+		return nil, err
+	}
+
+	service.utils.auditLogin(ctx, user.ID, session, "login_link")
+
+	currentSession := usercontext.ContextGetSession(ctx)
+
+	if currentSession != nil {
+		service.userRepository.ExpireUserSession(ctx, currentSession.ID)
+		service.utils.auditSessionExpired(ctx, currentSession, "replaced by login")
+	}
+
+	return session, nil
+}
+
+// claimUnverifiedUser gives an unverified account to the owner of its
+// address. Whoever signed up with it never proved they own the address, so
+// their password, linked accounts, two-factor and sessions are all removed
+// rather than left as a way back in.
+func (service *UserService) claimUnverifiedUser(ctx context.Context, user *entities.User) (*entities.User, error) {
+	claimed, err := service.userRepository.ClaimUnverifiedUser(ctx, user.ID, user.Email)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to claim unverified user")
+		return nil, err
+	}
+
+	providers := make([]string, 0, len(claimed.RemovedIdentities))
+	for _, identity := range claimed.RemovedIdentities {
+		providers = append(providers, identity.Provider)
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditAccountClaimed,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		ActorID:    &user.ID,
+		Metadata:   map[string]any{"method": "login_link", "removed_providers": providers},
+	})
+	for _, session := range claimed.ExpiredSessions {
+		service.utils.auditSessionExpired(ctx, session, "account claimed")
+	}
+
+	return claimed.User, nil
+}
+
+// userForLoginLink finds the user with email, or creates one whose only login
+// is the emailed link.
+func (service *UserService) userForLoginLink(ctx context.Context, email string) (*entities.User, error) {
+	user, err := service.userRepository.GetUserByEmail(ctx, email)
+	if err != entities.ErrUserNotFound {
+		return user, err
+	}
+
+	user, err = service.userRepository.CreateUserOAuth(ctx, repositories.CreateUserOAuthArgs{
+		Email:         email,
+		EmailVerified: true,
+		Provider:      entities.ProviderEmail,
+		ProviderID:    strings.ToLower(email),
+	})
+	// Another link for the same address may have been used at the same time.
+	if err == entities.ErrDuplicateEmail {
+		return service.userRepository.GetUserByEmail(ctx, email)
+	}
+
+	return user, err
+}
+
 func (service *UserService) AuthenticateWithPassword(ctx context.Context, email string, password string) (*entities.UserSession, error) {
 	service.utils.logger.Info().Ctx(ctx).Str("email", email).Msg("Authenticating user with password")
-	user, err := service.userRepository.GetUserByEmail(ctx, email)
+	user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(email))
 	if err != nil {
 		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to get user by email")
+		// Unknown emails fail the same way as wrong passwords so the login
+		// form cannot be used to find out who has an account, and take as
+		// long.
+		if errors.Is(err, entities.ErrUserNotFound) {
+			entities.CompareDummyPassword(password)
+			return nil, entities.ErrInvalidCredentials
+		}
 		return nil, err
 	}
 
 	err = user.ComparePassword(password)
 	if err != nil {
 		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to compare password")
+		if errors.Is(err, entities.ErrIncorrectProvider) {
+			return nil, entities.ErrInvalidCredentials
+		}
 		return nil, err
 	}
 
-	session, err := service.userRepository.CreateUserSession(ctx, repositories.CreateUserSessionArgs{
-		UserID: user.ID,
-	})
+	sessionArgs, err := service.loginSessionArgs(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := service.userRepository.CreateUserSession(ctx, sessionArgs)
 	if err != nil {
 		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create user session")
 		return nil, err
 	}
 
+	service.utils.auditLogin(ctx, user.ID, session, "password")
+
 	currentSession := usercontext.ContextGetSession(ctx)
 
 	if currentSession != nil {
 		service.userRepository.ExpireUserSession(ctx, currentSession.ID)
+		service.utils.auditSessionExpired(ctx, currentSession, "replaced by login")
+	}
+
+	return session, nil
+}
+
+// GetStaff returns every user with a role above a regular user.
+func (service *UserService) GetStaff(ctx context.Context) ([]*entities.User, error) {
+	service.utils.logger.Info().Ctx(ctx).Msg("Getting staff")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.userRepository.GetUsersByRole(ctx, []string{entities.RoleModerator, entities.RoleAdmin})
+}
+
+type SetUserRoleArgs struct {
+	Email string
+	Role  string
+}
+
+func (service *UserService) SetUserRole(ctx context.Context, args SetUserRoleArgs) (*entities.User, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Setting user role")
+
+	err := service.utils.authorize(ctx, entities.PermissionManageRoles)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(entities.Roles, args.Role) {
+		return nil, entities.ErrInvalidRole
+	}
+
+	user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(args.Email))
+	if err != nil {
+		return nil, err
+	}
+
+	// Admins can't demote themselves, so there is always someone left who can
+	// hand out roles.
+	if actor := usercontext.ContextGetUser(ctx); actor != nil && actor.ID == user.ID && args.Role != entities.RoleAdmin {
+		return nil, entities.ErrForbidden
+	}
+
+	previousRole := user.Role
+
+	user, err = service.userRepository.UpdateUserRole(ctx, user.ID, args.Role)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to set user role")
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditRoleChanged,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+		Metadata:   map[string]any{"from": previousRole, "to": user.Role},
+	})
+
+	return user, nil
+}
+
+// StartImpersonation opens a short session as the user with the given email on
+// behalf of the signed in admin. Changes made in it are attributed to the admin.
+func (service *UserService) StartImpersonation(ctx context.Context, email string) (*entities.UserSession, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("email", email).Msg("Starting impersonation")
+
+	err := service.utils.authorize(ctx, entities.PermissionImpersonate)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := usercontext.ContextGetUser(ctx)
+	if currentSession := usercontext.ContextGetSession(ctx); currentSession != nil && currentSession.IsImpersonation() {
+		return nil, ErrAlreadyImpersonating
+	}
+
+	user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return nil, err
 	}
 
+	if user.ID == admin.ID {
+		return nil, ErrCannotImpersonateSelf
+	}
+
+	sessionArgs := newSessionArgs(ctx, user.ID)
+	sessionArgs.ImpersonatorID = &admin.ID
+
+	session, err := service.userRepository.CreateUserSession(ctx, sessionArgs)
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Failed to create impersonation session")
+		return nil, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditImpersonationStarted,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	})
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditSessionCreated,
+		TargetType: entities.AuditTargetSession,
+		TargetID:   session.ID.String(),
+		Metadata:   map[string]any{"user_id": user.ID.String(), "impersonator_id": admin.ID.String()},
+	})
+
 	return session, nil
 }
+
+// StopImpersonation ends the impersonation session on ctx.
+func (service *UserService) StopImpersonation(ctx context.Context) error {
+	session := usercontext.ContextGetSession(ctx)
+	if session == nil || !session.IsImpersonation() {
+		return ErrNotImpersonating
+	}
+
+	service.utils.logger.Info().Ctx(ctx).Str("impersonatorID", session.ImpersonatorID.String()).Msg("Stopping impersonation")
+
+	err := service.userRepository.ExpireUserSession(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditImpersonationStopped,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   session.UserID.String(),
+	})
+	service.utils.auditSessionExpired(ctx, session, "impersonation stopped")
+
+	return nil
+}
+
+// sessionTouchInterval limits how often a session's last seen time is written,
+// so browsing doesn't cost a database write per request.
+const sessionTouchInterval = time.Minute
+
+// newSessionArgs records the device and address a session is opened from.
+func newSessionArgs(ctx context.Context, userID uuid.UUID) repositories.CreateUserSessionArgs {
+	args := repositories.CreateUserSessionArgs{UserID: userID}
+
+	if info := usercontext.ContextGetRequestInfo(ctx); info != nil {
+		args.UserAgent = &info.UserAgent
+		args.IPAddress = &info.IPAddress
+	}
+
+	return args
+}
+
+// loginSessionArgs is newSessionArgs for a login. Users with two-factor
+// enabled get a session that only lets them enter their code.
+func (service *UserService) loginSessionArgs(ctx context.Context, userID uuid.UUID) (repositories.CreateUserSessionArgs, error) {
+	args := newSessionArgs(ctx, userID)
+
+	twoFactor, err := service.userRepository.GetTwoFactor(ctx, userID)
+	if err != nil {
+		if err == entities.ErrTwoFactorNotEnabled {
+			return args, nil
+		}
+		return args, err
+	}
+
+	args.TwoFactorPending = twoFactor.Enabled
+
+	return args, nil
+}
+
+// TouchSession records that session was just used from the current request's
+// address.
+func (service *UserService) TouchSession(ctx context.Context, session *entities.UserSession) error {
+	if time.Since(session.LastSeenAt) < sessionTouchInterval {
+		return nil
+	}
+
+	var ipAddress *string
+	if info := usercontext.ContextGetRequestInfo(ctx); info != nil {
+		ipAddress = &info.IPAddress
+	}
+
+	return service.userRepository.TouchUserSession(ctx, session.ID, ipAddress)
+}
+
+func (service *UserService) GetSessions(ctx context.Context, userID uuid.UUID) ([]*entities.UserSession, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Getting sessions")
+
+	return service.userRepository.GetActiveUserSessions(ctx, userID)
+}
+
+// Logout expires the session the request was made with.
+func (service *UserService) Logout(ctx context.Context) error {
+	session := usercontext.ContextGetSession(ctx)
+	if session == nil {
+		return nil
+	}
+
+	service.utils.logger.Info().Ctx(ctx).Str("sessionID", session.ID.String()).Msg("Logging out")
+
+	err := service.userRepository.ExpireUserSession(ctx, session.ID)
+	if err != nil {
+		return err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLogout,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   session.UserID.String(),
+	})
+	service.utils.auditSessionExpired(ctx, session, "logout")
+
+	return nil
+}
+
+// RevokeSession signs the user out of one of their sessions.
+func (service *UserService) RevokeSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Str("sessionID", sessionID.String()).Msg("Revoking session")
+
+	session, err := service.userRepository.ExpireUserSessionForUser(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	service.utils.auditSessionExpired(ctx, session, "revoked")
+
+	return nil
+}
+
+// SignOutEverywhere expires every session of the user, including the one the
+// request was made with, and returns how many were expired.
+func (service *UserService) SignOutEverywhere(ctx context.Context, userID uuid.UUID) (int, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Signing out everywhere")
+
+	sessions, err := service.userRepository.ExpireUserSessionsByUser(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	service.utils.audit(ctx, auditArgs{
+		Action:     entities.AuditLogout,
+		TargetType: entities.AuditTargetUser,
+		TargetID:   userID.String(),
+		Metadata:   map[string]any{"everywhere": true, "sessions": len(sessions)},
+	})
+	for _, session := range sessions {
+		service.utils.auditSessionExpired(ctx, session, "signed out everywhere")
+	}
+
+	return len(sessions), nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/totp"
+)
+
+func TestLoginLinks(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	requestLink := func(t *testing.T, email string) string {
+		t.Helper()
+		err := s.UserService.RequestLoginLink(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.lastLinkToken(t, email, "/login/email/")
+	}
+
+	t.Run("works once", func(t *testing.T) {
+		token := requestLink(t, "once@example.com")
+
+		session, err := s.UserService.LoginWithLink(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := s.repos.UserRepository.GetUserByID(ctx, session.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !user.EmailVerified || user.Identity(entities.ProviderEmail) == nil {
+			t.Errorf("user = %+v, want a verified email login", user)
+		}
+
+		_, err = s.UserService.LoginWithLink(ctx, token)
+		if !errors.Is(err, entities.ErrLoginLinkInvalid) {
+			t.Errorf("second use err = %v, want ErrLoginLinkInvalid", err)
+		}
+	})
+
+	t.Run("expires", func(t *testing.T) {
+		ttl := s.config.MagicLink.TTL
+		s.config.MagicLink.TTL = -time.Minute
+		t.Cleanup(func() { s.config.MagicLink.TTL = ttl })
+
+		token := requestLink(t, "expired@example.com")
+
+		_, err := s.UserService.LoginWithLink(ctx, token)
+		if !errors.Is(err, entities.ErrLoginLinkInvalid) {
+			t.Errorf("err = %v, want ErrLoginLinkInvalid", err)
+		}
+	})
+
+	t.Run("same email whether or not the account exists", func(t *testing.T) {
+		s.createUser(t, "existing@example.com")
+
+		bodies := make([]string, 0, 2)
+		for _, email := range []string{"existing@example.com", "nobody@example.com"} {
+			token := requestLink(t, email)
+
+			sent := s.mail.Sent()
+			message := sent[len(sent)-1]
+			if message.Subject != "Your login link" {
+				t.Errorf("subject to %s = %q", email, message.Subject)
+			}
+			bodies = append(bodies, strings.Replace(message.Body, token, "TOKEN", 1))
+		}
+
+		if bodies[0] != bodies[1] {
+			t.Errorf("emails differ:\n%s\n---\n%s", bodies[0], bodies[1])
+		}
+	})
+
+	t.Run("takes over an unverified account", func(t *testing.T) {
+		squatted := s.createUser(t, "squatted@example.com")
+
+		squatterSession, err := s.repos.UserRepository.CreateUserSession(ctx, repositories.CreateUserSessionArgs{UserID: squatted.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.repos.UserRepository.StartTwoFactor(ctx, squatted.ID, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.repos.UserRepository.EnableTwoFactor(ctx, squatted.ID, totp.Step(time.Now()), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		session, err := s.UserService.LoginWithLink(ctx, requestLink(t, "squatted@example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if session.UserID != squatted.ID || session.TwoFactorPending {
+			t.Errorf("session = %+v, want a full session for %s", session, squatted.ID)
+		}
+
+		_, err = s.UserService.AuthenticateWithPassword(ctx, "squatted@example.com", "correct horse battery staple")
+		if !errors.Is(err, entities.ErrInvalidCredentials) {
+			t.Errorf("password login err = %v, want ErrInvalidCredentials", err)
+		}
+
+		_, _, err = s.repos.UserRepository.GetUserBySessionToken(ctx, squatterSession.Token)
+		if err == nil {
+			t.Error("session from before the takeover still works")
+		}
+
+		user, err := s.repos.UserRepository.GetUserByID(ctx, squatted.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !user.EmailVerified || len(user.Identities) != 1 || user.Identities[0].Provider != entities.ProviderEmail {
+			t.Errorf("user = %+v, want a verified user whose only login is the email link", user)
+		}
+
+		_, err = s.repos.UserRepository.GetTwoFactor(ctx, squatted.ID)
+		if !errors.Is(err, entities.ErrTwoFactorNotEnabled) {
+			t.Errorf("two-factor err = %v, want ErrTwoFactorNotEnabled", err)
+		}
+	})
+
+	t.Run("keeps a verified account's logins", func(t *testing.T) {
+		verified := s.createUser(t, "verified@example.com")
+		_, err := s.repos.UserRepository.SetUserEmailVerified(ctx, verified.ID, verified.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.UserService.LoginWithLink(ctx, requestLink(t, "verified@example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.UserService.AuthenticateWithPassword(ctx, "verified@example.com", "correct horse battery staple")
+		if err != nil {
+			t.Errorf("password login after link err = %v", err)
+		}
+	})
+}
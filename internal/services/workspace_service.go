@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+	"github.com/mcorrigan89/url_shortener/internal/mailer"
+	"github.com/mcorrigan89/url_shortener/internal/repositories"
+	"github.com/mcorrigan89/url_shortener/internal/tokens"
+)
+
+type WorkspaceService struct {
+	utils               ServicesUtils
+	workspaceRepository *repositories.WorkspaceRepository
+	userRepository      *repositories.UserRepository
+	mailer              mailer.Mailer
+	signer              *tokens.Signer
+}
+
+func NewWorkspaceService(utils ServicesUtils, repos *repositories.Repositories, mailer mailer.Mailer, signer *tokens.Signer) *WorkspaceService {
+	return &WorkspaceService{
+		utils:               utils,
+		workspaceRepository: repos.WorkspaceRepository,
+		userRepository:      repos.UserRepository,
+		mailer:              mailer,
+		signer:              signer,
+	}
+}
+
+type CreateWorkspaceArgs struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+func (service *WorkspaceService) CreateWorkspace(ctx context.Context, args CreateWorkspaceArgs) (*entities.WorkspaceMember, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Creating workspace")
+
+	membership, err := service.workspaceRepository.CreateWorkspace(ctx, repositories.CreateWorkspaceArgs{
+		Name:      strings.TrimSpace(args.Name),
+		CreatedBy: args.UserID,
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error creating workspace")
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+func (service *WorkspaceService) GetWorkspacesByUserID(ctx context.Context, userID uuid.UUID) ([]*entities.WorkspaceMember, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Getting workspaces by user id")
+
+	return service.workspaceRepository.GetWorkspacesByUserID(ctx, userID)
+}
+
+// GetMembership returns the user's membership of the workspace, with the
+// workspace attached. Users who aren't members get entities.ErrForbidden.
+func (service *WorkspaceService) GetMembership(ctx context.Context, workspaceID, userID uuid.UUID) (*entities.WorkspaceMember, error) {
+	member, err := service.workspaceRepository.GetWorkspaceMember(ctx, workspaceID, userID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, entities.ErrForbidden
+		}
+		return nil, err
+	}
+
+	workspace, err := service.workspaceRepository.GetWorkspaceByID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	member.Workspace = workspace
+
+	return member, nil
+}
+
+func (service *WorkspaceService) GetMembers(ctx context.Context, workspaceID, userID uuid.UUID) ([]*entities.WorkspaceMember, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("workspaceID", workspaceID.String()).Msg("Getting workspace members")
+
+	_, err := service.GetMembership(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := service.workspaceRepository.GetWorkspaceMembers(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range members {
+		user, err := service.userRepository.GetUserByID(ctx, member.UserID)
+		if err == nil {
+			member.User = user
+		}
+	}
+
+	return members, nil
+}
+
+type SetMemberArgs struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	Email       string
+	Role        string
+}
+
+// SetMember adds a user to the workspace or changes their role. Only owners
+// can manage members.
+func (service *WorkspaceService) SetMember(ctx context.Context, args SetMemberArgs) (*entities.WorkspaceMember, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Setting workspace member")
+
+	actor, err := service.GetMembership(ctx, args.WorkspaceID, args.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanManageMembers() {
+		return nil, entities.ErrForbidden
+	}
+
+	user, err := service.userRepository.GetUserByEmail(ctx, strings.TrimSpace(args.Email))
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := service.workspaceRepository.SetWorkspaceMember(ctx, repositories.SetWorkspaceMemberArgs{
+		WorkspaceID: args.WorkspaceID,
+		UserID:      user.ID,
+		Role:        args.Role,
+	})
+	if err != nil {
+		return nil, err
+	}
+	member.User = user
+
+	return member, nil
+}
+
+type RemoveMemberArgs struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	UserID      uuid.UUID
+}
+
+// RemoveMember takes a user out of the workspace. Owners can remove anyone and
+// every member can remove themselves. The workspace keeps its links either way.
+func (service *WorkspaceService) RemoveMember(ctx context.Context, args RemoveMemberArgs) error {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Removing workspace member")
+
+	actor, err := service.GetMembership(ctx, args.WorkspaceID, args.ActorID)
+	if err != nil {
+		return err
+	}
+	if args.UserID != args.ActorID && !actor.CanManageMembers() {
+		return entities.ErrForbidden
+	}
+
+	return service.workspaceRepository.RemoveWorkspaceMember(ctx, args.WorkspaceID, args.UserID)
+}
+
+type InviteMemberArgs struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	Email       string
+	Role        string
+}
+
+// InviteMember emails a signed link that adds whoever accepts it to the
+// workspace with the given role. Only owners can invite.
+func (service *WorkspaceService) InviteMember(ctx context.Context, args InviteMemberArgs) (*entities.WorkspaceInvite, error) {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Inviting workspace member")
+
+	actor, err := service.GetMembership(ctx, args.WorkspaceID, args.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanManageMembers() {
+		return nil, entities.ErrForbidden
+	}
+
+	invite, err := service.workspaceRepository.CreateWorkspaceInvite(ctx, repositories.CreateWorkspaceInviteArgs{
+		WorkspaceID: args.WorkspaceID,
+		Email:       strings.ToLower(strings.TrimSpace(args.Email)),
+		Role:        args.Role,
+		InvitedBy:   args.ActorID,
+		ExpiresAt:   time.Now().Add(service.utils.config.Invites.TTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	invite.Workspace = actor.Workspace
+
+	token := service.signer.Sign(invite.ID.String(), invite.ExpiresAt)
+
+	err = service.mailer.Send(ctx, mailer.Message{
+		To:      invite.Email,
+		Subject: fmt.Sprintf("You have been invited to %s", actor.Workspace.Name),
+		Body: fmt.Sprintf("You have been invited to join %s as %s.\n\nAccept the invite before %s:\n%s/invites/%s\n",
+			actor.Workspace.Name, invite.Role, invite.ExpiresAt.Format(time.RFC1123), service.utils.config.ClientURL, token),
+	})
+	if err != nil {
+		service.utils.logger.Err(err).Ctx(ctx).Msg("Error sending workspace invite")
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+func (service *WorkspaceService) GetPendingInvites(ctx context.Context, workspaceID, actorID uuid.UUID) ([]*entities.WorkspaceInvite, error) {
+	actor, err := service.GetMembership(ctx, workspaceID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.CanManageMembers() {
+		return nil, entities.ErrForbidden
+	}
+
+	return service.workspaceRepository.GetPendingWorkspaceInvites(ctx, workspaceID)
+}
+
+type RevokeInviteArgs struct {
+	WorkspaceID uuid.UUID
+	ActorID     uuid.UUID
+	InviteID    uuid.UUID
+}
+
+func (service *WorkspaceService) RevokeInvite(ctx context.Context, args RevokeInviteArgs) error {
+	service.utils.logger.Info().Ctx(ctx).Interface("args", args).Msg("Revoking workspace invite")
+
+	actor, err := service.GetMembership(ctx, args.WorkspaceID, args.ActorID)
+	if err != nil {
+		return err
+	}
+	if !actor.CanManageMembers() {
+		return entities.ErrForbidden
+	}
+
+	return service.workspaceRepository.RevokeWorkspaceInvite(ctx, args.WorkspaceID, args.InviteID)
+}
+
+// GetInvite looks up the pending invite a token was issued for, with its
+// workspace attached. Tampered, expired, revoked and used tokens all return
+// entities.ErrInviteUnavailable.
+func (service *WorkspaceService) GetInvite(ctx context.Context, token string) (*entities.WorkspaceInvite, error) {
+	payload, err := service.signer.Verify(token)
+	if err != nil {
+		return nil, entities.ErrInviteUnavailable
+	}
+
+	inviteID, err := uuid.Parse(payload)
+	if err != nil {
+		return nil, entities.ErrInviteUnavailable
+	}
+
+	invite, err := service.workspaceRepository.GetWorkspaceInviteByID(ctx, inviteID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, entities.ErrInviteUnavailable
+		}
+		return nil, err
+	}
+
+	if !invite.IsPending() {
+		return nil, entities.ErrInviteUnavailable
+	}
+
+	workspace, err := service.workspaceRepository.GetWorkspaceByID(ctx, invite.WorkspaceID)
+	if err != nil {
+		return nil, err
+	}
+	invite.Workspace = workspace
+
+	return invite, nil
+}
+
+// AcceptInvite adds the user to the invite's workspace. The user must be
+// signed in with the email address the invite was sent to.
+func (service *WorkspaceService) AcceptInvite(ctx context.Context, token string, userID uuid.UUID) (*entities.WorkspaceMember, error) {
+	service.utils.logger.Info().Ctx(ctx).Str("userID", userID.String()).Msg("Accepting workspace invite")
+
+	invite, err := service.GetInvite(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := service.userRepository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.EqualFold(user.Email, invite.Email) {
+		return nil, entities.ErrInviteEmailMismatch
+	}
+
+	member, err := service.workspaceRepository.AcceptWorkspaceInvite(ctx, invite, userID)
+	if err != nil {
+		return nil, err
+	}
+	member.Workspace = invite.Workspace
+
+	return member, nil
+}
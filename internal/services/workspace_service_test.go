@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/internal/entities"
+)
+
+func TestWorkspaceInvites(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	owner := s.createUser(t, "owner@example.com")
+	membership, err := s.WorkspaceService.CreateWorkspace(ctx, CreateWorkspaceArgs{UserID: owner.ID, Name: "Team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaceID := membership.WorkspaceID
+
+	invite := func(t *testing.T, email string) string {
+		t.Helper()
+		_, err := s.WorkspaceService.InviteMember(ctx, InviteMemberArgs{
+			WorkspaceID: workspaceID,
+			ActorID:     owner.ID,
+			Email:       email,
+			Role:        entities.WorkspaceRoleEditor,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.lastLinkToken(t, strings.ToLower(strings.TrimSpace(email)), "/invites/")
+	}
+
+	t.Run("accepted once by the invitee", func(t *testing.T) {
+		invitee := s.createUser(t, "invitee@example.com")
+		token := invite(t, "Invitee@Example.com ")
+
+		member, err := s.WorkspaceService.AcceptInvite(ctx, token, invitee.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if member.Role != entities.WorkspaceRoleEditor || member.WorkspaceID != workspaceID {
+			t.Errorf("member = %+v, want editor of %s", member, workspaceID)
+		}
+
+		_, err = s.WorkspaceService.AcceptInvite(ctx, token, invitee.ID)
+		if !errors.Is(err, entities.ErrInviteUnavailable) {
+			t.Errorf("second accept err = %v, want ErrInviteUnavailable", err)
+		}
+	})
+
+	t.Run("refused for a different email", func(t *testing.T) {
+		s.createUser(t, "intended@example.com")
+		other := s.createUser(t, "other@example.com")
+		token := invite(t, "intended@example.com")
+
+		_, err := s.WorkspaceService.AcceptInvite(ctx, token, other.ID)
+		if !errors.Is(err, entities.ErrInviteEmailMismatch) {
+			t.Errorf("err = %v, want ErrInviteEmailMismatch", err)
+		}
+	})
+
+	t.Run("refused once revoked", func(t *testing.T) {
+		invitee := s.createUser(t, "revoked@example.com")
+		token := invite(t, "revoked@example.com")
+
+		pending, err := s.WorkspaceService.GetInvite(ctx, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = s.WorkspaceService.RevokeInvite(ctx, RevokeInviteArgs{WorkspaceID: workspaceID, ActorID: owner.ID, InviteID: pending.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.WorkspaceService.AcceptInvite(ctx, token, invitee.ID)
+		if !errors.Is(err, entities.ErrInviteUnavailable) {
+			t.Errorf("err = %v, want ErrInviteUnavailable", err)
+		}
+	})
+
+	t.Run("refused once expired", func(t *testing.T) {
+		invitee := s.createUser(t, "late@example.com")
+		s.config.Invites.TTL = -time.Minute
+		defer func() { s.config.Invites.TTL = time.Hour }()
+		token := invite(t, "late@example.com")
+
+		_, err := s.WorkspaceService.AcceptInvite(ctx, token, invitee.ID)
+		if !errors.Is(err, entities.ErrInviteUnavailable) {
+			t.Errorf("err = %v, want ErrInviteUnavailable", err)
+		}
+	})
+
+	t.Run("refused when tampered with", func(t *testing.T) {
+		invitee := s.createUser(t, "tampered@example.com")
+		token := invite(t, "tampered@example.com")
+
+		_, err := s.WorkspaceService.AcceptInvite(ctx, token+"x", invitee.ID)
+		if !errors.Is(err, entities.ErrInviteUnavailable) {
+			t.Errorf("err = %v, want ErrInviteUnavailable", err)
+		}
+	})
+
+	t.Run("only owners can invite", func(t *testing.T) {
+		editor := s.createUser(t, "editor@example.com")
+		_, err := s.WorkspaceService.SetMember(ctx, SetMemberArgs{WorkspaceID: workspaceID, ActorID: owner.ID, Email: editor.Email, Role: entities.WorkspaceRoleEditor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sent := len(s.mail.Sent())
+
+		_, err = s.WorkspaceService.InviteMember(ctx, InviteMemberArgs{WorkspaceID: workspaceID, ActorID: editor.ID, Email: "friend@example.com", Role: entities.WorkspaceRoleOwner})
+		if !errors.Is(err, entities.ErrForbidden) {
+			t.Errorf("err = %v, want ErrForbidden", err)
+		}
+		if len(s.mail.Sent()) != sent {
+			t.Error("an email was sent for a refused invite")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+// Package testdb gives tests a freshly migrated Postgres schema of their own.
+// Tests that use it are skipped unless TEST_DATABASE_URL points at a database
+// they may create schemas in.
+package testdb
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/xid"
+)
+
+// migrationLockID serialises schema setup across test packages running at the
+// same time, since extensions are shared by the whole database.
+const migrationLockID = 7_202_601
+
+// New creates a schema, applies every up migration to it and returns a pool
+// whose connections use it. The schema is dropped when the test finishes.
+func New(t testing.TB) *pgxpool.Pool {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	ctx := context.Background()
+	schema := "test_" + xid.New().String()
+
+	admin, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connecting to test database: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec(context.Background(), "DROP SCHEMA IF EXISTS "+schema+" CASCADE")
+		admin.Close(context.Background())
+	})
+
+	config, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parsing TEST_DATABASE_URL: %v", err)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		t.Fatalf("opening test database pool: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	_, err = admin.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+	if err != nil {
+		t.Fatalf("locking test database: %v", err)
+	}
+	defer admin.Exec(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	setup := []string{
+		`CREATE EXTENSION IF NOT EXISTS "uuid-ossp" SCHEMA public`,
+		`CREATE EXTENSION IF NOT EXISTS citext SCHEMA public`,
+		"CREATE SCHEMA " + schema,
+	}
+	for _, sql := range setup {
+		_, err = admin.Exec(ctx, sql)
+		if err != nil {
+			t.Fatalf("preparing test schema: %v", err)
+		}
+	}
+
+	for _, file := range migrations(t) {
+		sql, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading migration: %v", err)
+		}
+
+		_, err = pool.Exec(ctx, string(sql))
+		if err != nil {
+			t.Fatalf("applying %s: %v", filepath.Base(file), err)
+		}
+	}
+
+	return pool
+}
+
+// migrations lists the up migrations in the order they apply.
+func migrations(t testing.TB) []string {
+	t.Helper()
+
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("locating migrations")
+	}
+
+	files, err := filepath.Glob(filepath.Join(filepath.Dir(file), "..", "..", "migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("finding migrations: %v", err)
+	}
+	sort.Strings(files)
+
+	return files
+}
@@ -0,0 +1,286 @@
+package threatfeed
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mcorrigan89/url_shortener/internal/blocklist"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown feed format")
+	ErrEmptyFeed     = errors.New("feed has no usable entries")
+	ErrMostlySkipped = errors.New("feed has more unusable rows than entries")
+)
+
+const (
+	FormatHosts     = "hosts"
+	FormatCSV       = "csv"
+	FormatURLhaus   = "urlhaus"
+	FormatPhishTank = "phishtank"
+)
+
+var Formats = []string{FormatHosts, FormatCSV, FormatURLhaus, FormatPhishTank}
+
+// hostsIgnored are the loopback names every hosts file carries and which must
+// never end up on the block list.
+var hostsIgnored = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"0.0.0.0":               true,
+}
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+type Entry struct {
+	Domain    string
+	FirstSeen *time.Time
+	LastSeen  *time.Time
+}
+
+type Feed struct {
+	Entries []Entry
+	Skipped int
+}
+
+// Check refuses feeds that look like an empty upload or a file in another
+// format, which parse without error but would wipe the source's domains.
+// More unusable rows than entries means the format is almost certainly wrong.
+func (f *Feed) Check() error {
+	if len(f.Entries) == 0 {
+		return fmt.Errorf("%w: %d rows skipped", ErrEmptyFeed, f.Skipped)
+	}
+	if f.Skipped > len(f.Entries) {
+		return fmt.Errorf("%w: %d skipped, %d parsed", ErrMostlySkipped, f.Skipped, len(f.Entries))
+	}
+	return nil
+}
+
+// urlColumns says where to find the URL and dates in a dump of reported URLs.
+type urlColumns struct {
+	url       int
+	firstSeen int
+	lastSeen  int
+}
+
+var (
+	// id,dateadded,url,url_status,last_online,threat,tags,urlhaus_link,reporter
+	urlhausColumns = urlColumns{url: 2, firstSeen: 1, lastSeen: 4}
+	// phish_id,url,phish_detail_url,submission_time,verified,verification_time,online,target
+	phishTankColumns = urlColumns{url: 1, firstSeen: 3, lastSeen: 5}
+)
+
+// Parse reads a threat feed in the given format and returns one entry per
+// normalised domain. Rows that do not contain a usable host are counted in
+// Skipped rather than failing the whole import.
+func Parse(r io.Reader, format string) (*Feed, error) {
+	feed := &feedBuilder{entries: map[string]*Entry{}}
+
+	var err error
+	switch format {
+	case FormatHosts:
+		err = parseHosts(r, feed)
+	case FormatCSV:
+		err = parseCSV(r, feed)
+	case FormatURLhaus:
+		err = parseURLDump(r, feed, urlhausColumns)
+	case FormatPhishTank:
+		err = parseURLDump(r, feed, phishTankColumns)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return feed.build(), nil
+}
+
+// parseHosts accepts both hosts files ("0.0.0.0 evil.com") and plain lists
+// with one domain per line.
+func parseHosts(r io.Reader, feed *feedBuilder) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			if hostsIgnored[strings.ToLower(field)] {
+				continue
+			}
+			feed.add(field, nil, nil)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseCSV reads rows of domain, first seen and last seen, where both dates
+// are optional.
+func parseCSV(r io.Reader, feed *feedBuilder) error {
+	reader := newCSVReader(r)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		domain := strings.TrimSpace(record[0])
+		if domain == "" || strings.EqualFold(domain, "domain") {
+			continue
+		}
+
+		feed.add(domain, column(record, 1), column(record, 2))
+	}
+}
+
+func parseURLDump(r io.Reader, feed *feedBuilder, columns urlColumns) error {
+	reader := newCSVReader(r)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		rawURL := column(record, columns.url)
+		if rawURL == nil {
+			feed.Skipped++
+			continue
+		}
+		if strings.EqualFold(*rawURL, "url") {
+			continue
+		}
+
+		host := hostFromURL(*rawURL)
+		if host == "" {
+			feed.Skipped++
+			continue
+		}
+
+		feed.add(host, column(record, columns.firstSeen), column(record, columns.lastSeen))
+	}
+}
+
+func newCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+func column(record []string, index int) *string {
+	if index >= len(record) {
+		return nil
+	}
+	value := strings.TrimSpace(record[index])
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func hostFromURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return u.Host
+}
+
+func parseDate(value *string) *time.Time {
+	if value == nil {
+		return nil
+	}
+
+	for _, layout := range dateLayouts {
+		t, err := time.Parse(layout, *value)
+		if err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+
+	return nil
+}
+
+type feedBuilder struct {
+	entries map[string]*Entry
+	order   []string
+	Skipped int
+}
+
+// add records host, widening the seen range when the same domain appears on
+// several rows. Single labels such as ids or column names from a file in
+// another format are skipped, as no feed lists a bare top-level name.
+func (b *feedBuilder) add(host string, firstSeen, lastSeen *string) {
+	domain, err := blocklist.NormalizeHost(host)
+	if err != nil || (!strings.Contains(domain, ".") && net.ParseIP(domain) == nil) {
+		b.Skipped++
+		return
+	}
+
+	first := parseDate(firstSeen)
+	last := parseDate(lastSeen)
+	if last == nil {
+		last = first
+	}
+
+	entry, ok := b.entries[domain]
+	if !ok {
+		b.entries[domain] = &Entry{Domain: domain, FirstSeen: first, LastSeen: last}
+		b.order = append(b.order, domain)
+		return
+	}
+
+	if first != nil && (entry.FirstSeen == nil || first.Before(*entry.FirstSeen)) {
+		entry.FirstSeen = first
+	}
+	if last != nil && (entry.LastSeen == nil || last.After(*entry.LastSeen)) {
+		entry.LastSeen = last
+	}
+}
+
+func (b *feedBuilder) build() *Feed {
+	feed := &Feed{Skipped: b.Skipped}
+	for _, domain := range b.order {
+		feed.Entries = append(feed.Entries, *b.entries[domain])
+	}
+	return feed
+}
@@ -0,0 +1,77 @@
+package threatfeed
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	hostsFeed = `# blocked
+0.0.0.0 localhost
+0.0.0.0 evil.com
+0.0.0.0 phish.example.net
+`
+	phishTankFeed = `phish_id,url,phish_detail_url,submission_time,verified,verification_time,online,target
+1,http://evil.com/login,http://www.phishtank.com/phish_detail.php?phish_id=1,2024-05-01T10:00:00+00:00,yes,2024-05-01T11:00:00+00:00,yes,Other
+2,https://phish.example.net/,http://www.phishtank.com/phish_detail.php?phish_id=2,2024-05-02T10:00:00+00:00,yes,2024-05-02T11:00:00+00:00,yes,Other
+3,https://bank.example.org/,http://www.phishtank.com/phish_detail.php?phish_id=3,2024-05-03T10:00:00+00:00,yes,2024-05-03T11:00:00+00:00,yes,Other
+`
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		feed    string
+		want    int
+		wantErr error
+	}{
+		{name: "hosts file", format: FormatHosts, feed: hostsFeed, want: 2},
+		{name: "phishtank dump", format: FormatPhishTank, feed: phishTankFeed, want: 3},
+		{name: "empty hosts file", format: FormatHosts, feed: "", wantErr: ErrEmptyFeed},
+		{name: "empty csv", format: FormatCSV, feed: "", wantErr: ErrEmptyFeed},
+		{name: "empty urlhaus dump", format: FormatURLhaus, feed: "", wantErr: ErrEmptyFeed},
+		{name: "empty phishtank dump", format: FormatPhishTank, feed: "", wantErr: ErrEmptyFeed},
+		{name: "only comments", format: FormatHosts, feed: "# nothing here\n\n", wantErr: ErrEmptyFeed},
+		{name: "phishtank dump as hosts", format: FormatHosts, feed: phishTankFeed, wantErr: ErrEmptyFeed},
+		{name: "phishtank dump as csv", format: FormatCSV, feed: phishTankFeed, wantErr: ErrEmptyFeed},
+		{name: "hosts file as phishtank", format: FormatPhishTank, feed: hostsFeed, wantErr: ErrEmptyFeed},
+		{name: "mostly junk", format: FormatHosts, feed: "evil.com\nnot,a,host\nalso,not,one\n", wantErr: ErrMostlySkipped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := Parse(strings.NewReader(tt.feed), tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = feed.Check()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Check err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && len(feed.Entries) != tt.want {
+				t.Errorf("got %d entries, want %d", len(feed.Entries), tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckEmptyFeedHasNoSkippedRows(t *testing.T) {
+	feed, err := Parse(strings.NewReader(hostsFeed), FormatPhishTank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Skipped == 0 {
+		t.Error("hosts file parsed as phishtank skipped no rows, so allow empty would accept it")
+	}
+
+	feed, err = Parse(strings.NewReader(""), FormatPhishTank)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Skipped != 0 {
+		t.Errorf("empty file skipped %d rows, want 0", feed.Skipped)
+	}
+}
@@ -0,0 +1,28 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// randomTokenBytes gives tokens 256 bits of entropy.
+const randomTokenBytes = 32
+
+// Generate returns a URL-safe random token for secrets that are stored only
+// as their Hash, such as session tokens.
+func Generate() (string, error) {
+	b := make([]byte, randomTokenBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Hash returns the hex encoded SHA-256 of token. Random tokens carry enough
+// entropy that a fast unsalted hash is safe to store and look up by.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
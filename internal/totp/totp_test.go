@@ -0,0 +1,104 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from RFC 6238 appendix B, "12345678901234567890",
+// in base32.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCode checks the RFC 6238 SHA1 test vectors. The RFC lists eight digit
+// codes; six digit codes are their last six digits.
+func TestCode(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	for _, secret := range []string{"", "not base32!"} {
+		_, err := Code(secret, 1)
+		if err != ErrInvalidSecret {
+			t.Errorf("Code(%q) err = %v, want ErrInvalidSecret", secret, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// 1111111111 falls in step 37037037.
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	code := func(step int64) string {
+		c, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", code: code(current), wantStep: current, wantOK: true},
+		{name: "one step behind", code: code(current - 1), wantStep: current - 1, wantOK: true},
+		{name: "one step ahead", code: code(current + 1), wantStep: current + 1, wantOK: true},
+		{name: "two steps behind", code: code(current - 2)},
+		{name: "two steps ahead", code: code(current + 2)},
+		{name: "spaces", code: code(current)[:3] + " " + code(current)[3:], wantStep: current, wantOK: true},
+		{name: "too short", code: code(current)[:5]},
+		{name: "too long", code: code(current) + "0"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate(%q) = %d, %t, want %d, %t", tt.code, step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateStepBoundary(t *testing.T) {
+	// The last second of a step and the first of the next see each other's
+	// codes, but not codes two steps away.
+	end := time.Unix(59, 0)
+	start := time.Unix(60, 0)
+
+	code, err := Code(rfcSecret, Step(end))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := Validate(rfcSecret, code, start); !ok {
+		t.Error("code from the previous step rejected at the start of the next")
+	}
+	if _, ok := Validate(rfcSecret, code, start.Add(Period*time.Second)); ok {
+		t.Error("code accepted two steps later")
+	}
+}
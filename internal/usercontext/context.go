@@ -11,6 +11,13 @@ var AnonymousUser = &entities.User{
 	ID: uuid.Nil,
 }
 
+// SystemUser acts for commands run by operators outside of a web request.
+var SystemUser = &entities.User{
+	ID:    uuid.Nil,
+	Email: "system",
+	Role:  entities.RoleAdmin,
+}
+
 func UserIsAnonymous(u entities.User) bool {
 	return AnonymousUser.ID == u.ID
 }
@@ -19,6 +26,15 @@ type contextKey string
 
 const currentUserContextKey = contextKey("currentUser")
 const currentSessionContextKey = contextKey("currentSession")
+const requestInfoContextKey = contextKey("requestInfo")
+const csrfTokenContextKey = contextKey("csrfToken")
+
+// RequestInfo describes the HTTP request a piece of work is being done for.
+type RequestInfo struct {
+	IPAddress     string
+	UserAgent     string
+	CorrelationID string
+}
 
 func ContextSetUser(ctx context.Context, user *entities.User) context.Context {
 	ctx = context.WithValue(ctx, currentUserContextKey, user)
@@ -47,3 +63,33 @@ func ContextGetSession(ctx context.Context) *entities.UserSession {
 
 	return session
 }
+
+func ContextSetRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	ctx = context.WithValue(ctx, requestInfoContextKey, info)
+	return ctx
+}
+
+func ContextGetRequestInfo(ctx context.Context) *RequestInfo {
+	info, ok := ctx.Value(requestInfoContextKey).(*RequestInfo)
+	if !ok {
+		return nil
+	}
+
+	return info
+}
+
+// ContextSetCSRFToken stores the token forms rendered for this request must
+// post back.
+func ContextSetCSRFToken(ctx context.Context, token string) context.Context {
+	ctx = context.WithValue(ctx, csrfTokenContextKey, token)
+	return ctx
+}
+
+func ContextGetCSRFToken(ctx context.Context) string {
+	token, ok := ctx.Value(csrfTokenContextKey).(string)
+	if !ok {
+		return ""
+	}
+
+	return token
+}
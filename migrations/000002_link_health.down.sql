@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS user_notification_user_idx;
+DROP TABLE IF EXISTS user_notification;
+DROP INDEX IF EXISTS link_health_check_link_idx;
+DROP TABLE IF EXISTS link_health_check;
+ALTER TABLE link_redirect DROP COLUMN IF EXISTS broken;
\ No newline at end of file
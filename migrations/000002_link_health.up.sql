@@ -0,0 +1,27 @@
+ALTER TABLE link_redirect ADD COLUMN IF NOT EXISTS broken BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS link_health_check (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  link_id UUID NOT NULL REFERENCES link_redirect(id) ON DELETE CASCADE,
+  status_code INTEGER,
+  latency_ms INTEGER NOT NULL,
+  redirect_chain TEXT[] NOT NULL DEFAULT '{}',
+  tls_valid BOOLEAN NOT NULL DEFAULT FALSE,
+  healthy BOOLEAN NOT NULL,
+  error TEXT,
+  checked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS link_health_check_link_idx ON link_health_check (link_id, checked_at DESC);
+
+CREATE TABLE IF NOT EXISTS user_notification (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  link_id UUID REFERENCES link_redirect(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  message TEXT NOT NULL,
+  read BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_notification_user_idx ON user_notification (user_id, read);
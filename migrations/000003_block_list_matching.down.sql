@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS blocked_pattern;
+DROP INDEX IF EXISTS blocked_domain_lower_idx;
\ No newline at end of file
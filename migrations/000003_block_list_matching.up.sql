@@ -0,0 +1,9 @@
+CREATE INDEX IF NOT EXISTS blocked_domain_lower_idx ON blocked_domain (lower(domain));
+
+CREATE TABLE IF NOT EXISTS blocked_pattern (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  kind TEXT NOT NULL CHECK (kind IN ('regex', 'path_prefix')),
+  pattern TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (kind, pattern)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE link_redirect_history DROP COLUMN IF EXISTS reason;
+DROP INDEX IF EXISTS link_quarantine_pending_idx;
+DROP TABLE IF EXISTS link_quarantine;
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS link_quarantine (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  link_id UUID NOT NULL REFERENCES link_redirect(id) ON DELETE CASCADE,
+  source TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  decided_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS link_quarantine_pending_idx ON link_quarantine (link_id) WHERE status = 'pending';
+
+ALTER TABLE link_redirect_history ADD COLUMN IF NOT EXISTS reason TEXT;
+
+INSERT INTO link_quarantine (link_id, source, reason)
+SELECT id, 'moderator', 'Quarantined before the review queue existed' FROM link_redirect WHERE quarantined = TRUE;
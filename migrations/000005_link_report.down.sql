@@ -0,0 +1 @@
+DROP TABLE IF EXISTS link_report;
\ No newline at end of file
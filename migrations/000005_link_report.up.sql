@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS link_report (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  link_id UUID NOT NULL REFERENCES link_redirect(id) ON DELETE CASCADE,
+  reporter_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  ip_address TEXT NOT NULL,
+  category TEXT NOT NULL CHECK (category IN ('phishing', 'malware', 'spam', 'other')),
+  comment TEXT,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (link_id, ip_address)
+);
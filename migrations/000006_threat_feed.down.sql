@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS blocked_domain_source_idx;
+
+ALTER TABLE blocked_domain DROP COLUMN IF EXISTS last_seen;
+ALTER TABLE blocked_domain DROP COLUMN IF EXISTS first_seen;
+ALTER TABLE blocked_domain DROP COLUMN IF EXISTS source;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE blocked_domain ADD COLUMN source TEXT NOT NULL DEFAULT 'manual';
+ALTER TABLE blocked_domain ADD COLUMN first_seen TIMESTAMP WITH TIME ZONE;
+ALTER TABLE blocked_domain ADD COLUMN last_seen TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS blocked_domain_source_idx ON blocked_domain (source);
@@ -0,0 +1,9 @@
+ALTER TABLE blocked_domain DROP COLUMN IF EXISTS created_by;
+ALTER TABLE blocked_domain DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE blocked_domain DROP COLUMN IF EXISTS reason;
+
+ALTER TABLE blocked_user DROP COLUMN IF EXISTS created_by;
+ALTER TABLE blocked_user DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE blocked_user DROP COLUMN IF EXISTS reason;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
\ No newline at end of file
@@ -0,0 +1,9 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user' CHECK (role IN ('user', 'admin'));
+
+ALTER TABLE blocked_user ADD COLUMN reason TEXT;
+ALTER TABLE blocked_user ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE blocked_user ADD COLUMN created_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+ALTER TABLE blocked_domain ADD COLUMN reason TEXT;
+ALTER TABLE blocked_domain ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE blocked_domain ADD COLUMN created_by UUID REFERENCES users(id) ON DELETE SET NULL;
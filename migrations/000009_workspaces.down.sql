@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS link_redirect_workspace_idx;
+
+ALTER TABLE link_redirect DROP COLUMN IF EXISTS workspace_id;
+
+DROP TABLE IF EXISTS workspace_member;
+DROP TABLE IF EXISTS workspace;
\ No newline at end of file
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS workspace (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  name TEXT NOT NULL,
+  created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  version integer NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS workspace_member (
+  workspace_id UUID NOT NULL REFERENCES workspace(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS workspace_member_user_idx ON workspace_member (user_id);
+
+ALTER TABLE link_redirect ADD COLUMN workspace_id UUID REFERENCES workspace(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS link_redirect_workspace_idx ON link_redirect (workspace_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS workspace_invite;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS link_transfer;
+
+DROP INDEX IF EXISTS link_redirect_owner_idx;
+
+ALTER TABLE link_redirect DROP COLUMN IF EXISTS owner_id;
\ No newline at end of file
@@ -0,0 +1,24 @@
+ALTER TABLE link_redirect ADD COLUMN owner_id UUID;
+
+UPDATE link_redirect SET owner_id = created_by;
+
+ALTER TABLE link_redirect ALTER COLUMN owner_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS link_redirect_owner_idx ON link_redirect (owner_id);
+
+CREATE TABLE IF NOT EXISTS link_transfer (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  link_id UUID NOT NULL REFERENCES link_redirect(id) ON DELETE CASCADE,
+  from_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  to_user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  to_workspace_id UUID REFERENCES workspace(id) ON DELETE CASCADE,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled')),
+  decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  decided_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  CHECK ((to_user_id IS NULL) <> (to_workspace_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS link_transfer_pending_idx ON link_transfer (link_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS link_transfer_to_user_idx ON link_transfer (to_user_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS link_transfer_to_workspace_idx ON link_transfer (to_workspace_id) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS audit_event_append_only ON audit_event;
+
+DROP FUNCTION IF EXISTS audit_event_append_only();
+
+DROP TABLE IF EXISTS audit_event;
\ No newline at end of file
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS audit_event (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  action TEXT NOT NULL,
+  actor_id UUID,
+  impersonator_id UUID,
+  target_type TEXT,
+  target_id TEXT,
+  ip_address TEXT,
+  user_agent TEXT,
+  correlation_id TEXT,
+  metadata JSONB,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS audit_event_created_at_idx ON audit_event (created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_event_action_idx ON audit_event (action, created_at DESC);
+CREATE INDEX IF NOT EXISTS audit_event_actor_idx ON audit_event (actor_id, created_at DESC);
+
+-- Audit events are append-only. Actors are not foreign keys so events outlive
+-- the users they describe.
+CREATE OR REPLACE FUNCTION audit_event_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_event is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_event_append_only
+BEFORE UPDATE OR DELETE OR TRUNCATE ON audit_event
+FOR EACH STATEMENT EXECUTE FUNCTION audit_event_append_only();
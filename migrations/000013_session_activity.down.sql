@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS user_session_active_idx;
+
+ALTER TABLE user_session DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE user_session DROP COLUMN IF EXISTS ip_address;
+ALTER TABLE user_session DROP COLUMN IF EXISTS user_agent;
\ No newline at end of file
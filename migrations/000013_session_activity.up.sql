@@ -0,0 +1,7 @@
+ALTER TABLE user_session ADD COLUMN user_agent TEXT;
+ALTER TABLE user_session ADD COLUMN ip_address TEXT;
+ALTER TABLE user_session ADD COLUMN last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE user_session SET last_seen_at = created_at WHERE created_at IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS user_session_active_idx ON user_session (user_id) WHERE user_expired = FALSE;
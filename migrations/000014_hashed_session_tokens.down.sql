@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS user_session_token_hash_idx;
+
+ALTER TABLE user_session ADD COLUMN token TEXT;
+UPDATE user_session SET token = token_hash, user_expired = TRUE;
+ALTER TABLE user_session ALTER COLUMN token SET NOT NULL;
+
+ALTER TABLE user_session DROP COLUMN IF EXISTS absolute_expires_at;
+ALTER TABLE user_session DROP COLUMN IF EXISTS token_hash;
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE user_session ADD COLUMN token_hash TEXT;
+ALTER TABLE user_session ADD COLUMN absolute_expires_at TIMESTAMP WITH TIME ZONE;
+
+-- Existing tokens were guessable and are already stored in plain text, so
+-- every session is signed out rather than carried over.
+UPDATE user_session SET
+  token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+  absolute_expires_at = expires_at,
+  user_expired = TRUE;
+
+ALTER TABLE user_session ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE user_session ALTER COLUMN absolute_expires_at SET NOT NULL;
+ALTER TABLE user_session DROP COLUMN token;
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_session_token_hash_idx ON user_session (token_hash);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS user_auth_user_provider_idx;
+DROP INDEX IF EXISTS user_auth_provider_idx;
\ No newline at end of file
@@ -0,0 +1,4 @@
+-- A user may have one login per provider, and each provider account may be
+-- linked to only one user.
+CREATE UNIQUE INDEX IF NOT EXISTS user_auth_provider_idx ON user_auth (provider, provider_id);
+CREATE UNIQUE INDEX IF NOT EXISTS user_auth_user_provider_idx ON user_auth (user_id, provider);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS password_reset;
\ No newline at end of file
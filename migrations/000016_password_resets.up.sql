@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS password_reset (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  -- Only a SHA-256 hash of the token is kept, as for sessions.
+  token_hash TEXT NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS password_reset_token_hash_idx ON password_reset (token_hash);
+CREATE INDEX IF NOT EXISTS password_reset_user_idx ON password_reset (user_id) WHERE used_at IS NULL;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS login_link;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS login_link (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  -- Links are sent to an address, which may not have an account yet.
+  email TEXT NOT NULL,
+  token_hash TEXT NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS login_link_token_hash_idx ON login_link (token_hash);
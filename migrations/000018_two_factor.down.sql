@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS role_policy;
+ALTER TABLE user_session DROP COLUMN IF EXISTS two_factor_pending;
+DROP TABLE IF EXISTS user_recovery_code;
+DROP TABLE IF EXISTS user_two_factor;
\ No newline at end of file
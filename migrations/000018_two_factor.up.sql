@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS user_two_factor (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  -- The TOTP secret has to be readable to check codes, so unlike tokens it
+  -- can't be hashed.
+  secret TEXT NOT NULL,
+  -- NULL until the user proves their authenticator works.
+  enabled_at TIMESTAMP WITH TIME ZONE,
+  -- The last time step a code was accepted for, so codes work once.
+  last_used_step BIGINT NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_code (
+  id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  code_hash TEXT NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_recovery_code_user_hash_idx ON user_recovery_code (user_id, code_hash);
+
+-- Sessions from a login that still needs the second factor.
+ALTER TABLE user_session ADD COLUMN IF NOT EXISTS two_factor_pending BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS role_policy (
+  role TEXT PRIMARY KEY,
+  require_two_factor BOOLEAN NOT NULL DEFAULT FALSE,
+  updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);